@@ -0,0 +1,189 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+// checkTriesCmd represents the check-tries command
+var checkTriesCmd = &cobra.Command{
+	Use:   "check-tries",
+	Short: "Tool for checking the log tries and receipt tries of the new DB against their roots",
+	Long: `Tool for checking, per block range of the new database, that the log trie of every receipt rebuilt from its
+eth.log_cids rows hashes to receipt_cids.log_root, and that the receipt trie of every block rebuilt from the receipt
+trie leaf nodes in public.blocks hashes to header_cids.receipt_root.
+
+Can be configured to work over specific block ranges.
+
+The heights of mismatching blocks are written out to the mismatch directory, to a log_cids file for log tries and to a
+receipt_cids file for receipt tries, in the same format as the gap files, so that they can be fed back into retry-gaps.
+Ranges that could not be checked are written out to both files.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *logrus.WithField("SubCommand", subCommand)
+		for toml, cli := range map[string]string{
+			migration_tools.TOML_MIGRATION_START:                   migration_tools.CLI_MIGRATION_START,
+			migration_tools.TOML_MIGRATION_STOP:                    migration_tools.CLI_MIGRATION_STOP,
+			migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE:       migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE,
+			migration_tools.TOML_MIGRATION_AUTO_RANGE:              migration_tools.CLI_MIGRATION_AUTO_RANGE,
+			migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE: migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE,
+			migration_tools.TOML_LOG_MISMATCH_DIR:                  migration_tools.CLI_LOG_MISMATCH_DIR,
+		} {
+			viper.BindPFlag(toml, cmd.PersistentFlags().Lookup(cli))
+		}
+		checkTries()
+	},
+}
+
+func checkTries() {
+	logWithCommand.Info("----- running trie check -----")
+	conf := migration_tools.NewConfig()
+	logWithCommand.Infof("initializing a new TrieChecker with config params: %+v", conf)
+	checker, err := migration_tools.NewTrieChecker(context.Background(), conf)
+	if err != nil {
+		logWithCommand.Fatalf("failed to initialize a new TrieChecker: %v", err)
+	}
+	defer checker.Close()
+
+	viper.BindEnv(migration_tools.TOML_LOG_MISMATCH_DIR, migration_tools.LOG_MISMATCH_DIR)
+	mismatchDir := viper.GetString(migration_tools.TOML_LOG_MISMATCH_DIR)
+	if err := os.MkdirAll(mismatchDir, 0777); err != nil {
+		logWithCommand.Fatalf("failed to open directory for writing mismatches: %v", err)
+	}
+
+	// the tries are rebuilt from the new DB, so the ranges are detected by the headers in it
+	tableRanges, err := getTableRanges(checker, []migration_tools.TableName{migration_tools.EthHeaders})
+	if err != nil {
+		logWithCommand.Fatalf("failed to load block ranges for processing: %v", err)
+	}
+	ranges := tableRanges[migration_tools.EthHeaders]
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	checkTrieRanges(ctx, checker, ranges, mismatchDir)
+}
+
+// checkTrieRanges checks the block ranges across the configured number of workers, writing the heights of mismatching
+// blocks, and the ranges that failed to be checked, out to a file per table in the mismatch directory
+func checkTrieRanges(ctx context.Context, checker *migration_tools.TrieChecker, blockRanges [][2]uint64, mismatchDir string) {
+	tables := []migration_tools.TableName{migration_tools.EthLogs, migration_tools.EthReceipts}
+	mismatchFiles := make(map[migration_tools.TableName]*os.File, len(tables))
+	timestamp := strconv.Itoa(int(time.Now().Unix()))
+	for _, tableName := range tables {
+		mismatchFilePath := filepath.Join(mismatchDir, string(tableName)+"_"+timestamp)
+		mismatchFile, err := os.OpenFile(mismatchFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			logWithCommand.Errorf("unable to open mismatch file at %s: %v", mismatchFilePath, err)
+			return
+		}
+		defer mismatchFile.Close()
+		mismatchFiles[tableName] = mismatchFile
+	}
+
+	numWorkers := viper.GetInt(migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE)
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	type mismatch struct {
+		tableName migration_tools.TableName
+		rng       [2]uint64
+	}
+	rangeChan := make(chan [2]uint64)
+	mismatchChan := make(chan mismatch)
+	workerWg := new(sync.WaitGroup)
+	for workerNum := 1; workerNum <= numWorkers; workerNum++ {
+		workerWg.Add(1)
+		go func(workerNum int) {
+			defer workerWg.Done()
+			for rng := range rangeChan {
+				mismatches, err := checker.Check(rng)
+				if err != nil {
+					logWithCommand.Errorf("worker %d unable to check the tries of range (%d, %d): %v", workerNum, rng[0], rng[1], err)
+					for _, tableName := range tables {
+						mismatchChan <- mismatch{tableName: tableName, rng: rng}
+					}
+					continue
+				}
+				// a block can have more than one mismatching log trie, but its height is only written out once
+				reported := make(map[mismatch]bool, len(mismatches))
+				for _, trieMismatch := range mismatches {
+					logWithCommand.Warn(trieMismatch.String())
+					height := mismatch{
+						tableName: trieMismatch.TableName,
+						rng:       [2]uint64{trieMismatch.BlockNumber, trieMismatch.BlockNumber},
+					}
+					if !reported[height] {
+						reported[height] = true
+						mismatchChan <- height
+					}
+				}
+				logWithCommand.Infof("range (%d, %d) checked- %d mismatching tries", rng[0], rng[1], len(mismatches))
+			}
+		}(workerNum)
+	}
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for entry := range mismatchChan {
+			mismatchFile := mismatchFiles[entry.tableName]
+			if _, err := mismatchFile.WriteString(fmt.Sprintf("%d, %d\r\n", entry.rng[0], entry.rng[1])); err != nil {
+				logWithCommand.Errorf("error writing mismatch to file at %s; err: %s", mismatchFile.Name(), err.Error())
+			}
+		}
+	}()
+
+	for i, blockRange := range blockRanges {
+		select {
+		case <-ctx.Done():
+			logWithCommand.Infof("quitting trie check\r\nunchecked ranges: %+v", blockRanges[i:])
+		case rangeChan <- blockRange:
+			continue
+		}
+		break
+	}
+	close(rangeChan)
+	workerWg.Wait()
+	close(mismatchChan)
+	<-writerDone
+}
+
+func init() {
+	rootCmd.AddCommand(checkTriesCmd)
+
+	// check-tries flags
+	checkTriesCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_START, 0, "start height")
+	checkTriesCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_STOP, 0, "stop height")
+	checkTriesCmd.PersistentFlags().Int(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE, 1, "number of workers")
+	checkTriesCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_AUTO_RANGE, false, "turn on or off auto range detection and chunking")
+	checkTriesCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, 0, "segment size for auto range detection and chunking")
+	checkTriesCmd.PersistentFlags().String(migration_tools.CLI_LOG_MISMATCH_DIR, "./mismatches/", "directory to write mismatching block heights into")
+}
@@ -0,0 +1,161 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/csv"
+)
+
+// exportCSVCmd represents the export-csv command
+var exportCSVCmd = &cobra.Command{
+	Use:   "export-csv",
+	Short: "Tool for transforming old DB data into new DB csv files",
+	Long: `Tool for reading data from a old database, transforming them into new DB models,
+and writing them out to csv files that can be bulk loaded into a new database with COPY.
+
+Can be configured to work over a subset of the tables, and over specific block ranges.
+
+Each table is written to its own series of files in the output directory, named {table_name}_{unix_timestamp}_{seq}.csv,
+and a new file is started once the current one reaches the configured max size or max number of block ranges.
+Read and write gaps are written out to the gap directories in the same way as the migrate command.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *logrus.WithField("SubCommand", subCommand)
+		for toml, cli := range map[string]string{
+			migration_tools.TOML_MIGRATION_START:                   migration_tools.CLI_MIGRATION_START,
+			migration_tools.TOML_MIGRATION_STOP:                    migration_tools.CLI_MIGRATION_STOP,
+			migration_tools.TOML_MIGRATION_TABLE_NAMES:             migration_tools.CLI_MIGRATION_TABLE_NAMES,
+			migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE:       migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE,
+			migration_tools.TOML_MIGRATION_AUTO_RANGE:              migration_tools.CLI_MIGRATION_AUTO_RANGE,
+			migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE: migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE,
+			migration_tools.TOML_CSV_OUTPUT_DIR:                    migration_tools.CLI_CSV_OUTPUT_DIR,
+			migration_tools.TOML_CSV_MAX_FILE_SIZE:                 migration_tools.CLI_CSV_MAX_FILE_SIZE,
+			migration_tools.TOML_CSV_MAX_RANGES_PER_FILE:           migration_tools.CLI_CSV_MAX_RANGES_PER_FILE,
+			migration_tools.TOML_MIGRATION_CANONICAL_ONLY:          migration_tools.CLI_MIGRATION_CANONICAL_ONLY,
+			migration_tools.TOML_MIGRATION_ADAPTIVE_RANGE:          migration_tools.CLI_MIGRATION_ADAPTIVE_RANGE,
+			migration_tools.TOML_MIGRATION_TARGET_ROWS:             migration_tools.CLI_MIGRATION_TARGET_ROWS,
+			migration_tools.TOML_MIGRATION_TARGET_LATENCY:          migration_tools.CLI_MIGRATION_TARGET_LATENCY,
+		} {
+			viper.BindPFlag(toml, cmd.PersistentFlags().Lookup(cli))
+		}
+		exportCSV()
+	},
+}
+
+func exportCSV() {
+	logWithCommand.Info("----- running csv export -----")
+	conf := migration_tools.NewConfig()
+	conf.ReadOnly = true
+	logWithCommand.Infof("initializing a new read-only Migrator with config params: %+v", conf)
+	migrator, err := migration_tools.NewMigrator(context.Background(), conf)
+	if err != nil {
+		logWithCommand.Fatalf("failed to initialize a new Migrator: %v", err)
+	}
+
+	tables, err := getTableNames()
+	if err != nil {
+		logWithCommand.Fatalf("failed to generate set of TableNames for processing: %v", err)
+	}
+
+	if err := getGapDirs(); err != nil {
+		logWithCommand.Fatalf("failed to open directories for writing read and write gaps: %v", err)
+	}
+	reportWriter, err := useReportWriter(migrator, false)
+	if err != nil {
+		logWithCommand.Fatalf("failed to open report file: %v", err)
+	}
+
+	tableRanges, err := getTableRanges(migrator, tables)
+	if err != nil {
+		logWithCommand.Fatalf("failed to load block ranges for processing: %v", err)
+	}
+
+	viper.BindEnv(migration_tools.TOML_CSV_OUTPUT_DIR, migration_tools.CSV_OUTPUT_DIR)
+	viper.BindEnv(migration_tools.TOML_CSV_MAX_FILE_SIZE, migration_tools.CSV_MAX_FILE_SIZE)
+	viper.BindEnv(migration_tools.TOML_CSV_MAX_RANGES_PER_FILE, migration_tools.CSV_MAX_RANGES_PER_FILE)
+	outputDir := viper.GetString(migration_tools.TOML_CSV_OUTPUT_DIR)
+	maxFileSize := viper.GetUint64(migration_tools.TOML_CSV_MAX_FILE_SIZE)
+	maxRangesPerFile := viper.GetUint64(migration_tools.TOML_CSV_MAX_RANGES_PER_FILE)
+	if outputDir == "" {
+		logWithCommand.Fatal("csv export needs to be configured with an output directory")
+	}
+
+	csvWriters := make(map[migration_tools.TableName]*csv.RotatingWriter, len(tables))
+	for _, table := range tables {
+		csvWriters[table], err = migration_tools.NewTableCSVWriter(table, outputDir, maxFileSize, maxRangesPerFile)
+		if err != nil {
+			logWithCommand.Fatalf("failed to initialize csv writer for table %s: %v", table, err)
+		}
+	}
+
+	segmenter, err := getSegmenter(migrator, tableRanges)
+	if err != nil {
+		logWithCommand.Fatalf("failed to initialize adaptive range sizing: %v", err)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	wg := new(sync.WaitGroup)
+	for _, table := range tables {
+		csvWriter := csvWriters[table]
+		process := func(ctx context.Context, wg *sync.WaitGroup, tableName migration_tools.TableName,
+			blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error) {
+			return migrator.TransformToCSV(ctx, csvWriter, wg, tableName, blockRanges)
+		}
+		processTable(ctx, wg, migrator, process, segmenter, table, tableRanges[table])
+	}
+	wg.Wait()
+	if err := migrator.Close(); err != nil {
+		logWithCommand.Errorf("failed to close Migrator: %v", err)
+	}
+	if err := reportWriter.Close(); err != nil {
+		logWithCommand.Errorf("failed to close report file: %v", err)
+	}
+
+	for table, csvWriter := range csvWriters {
+		if err := csvWriter.Close(); err != nil {
+			logWithCommand.Errorf("failed to close csv writer for table %s: %v", table, err)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(exportCSVCmd)
+
+	// export-csv flags
+	exportCSVCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_START, 0, "start height")
+	exportCSVCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_STOP, 0, "stop height")
+	exportCSVCmd.PersistentFlags().StringArray(migration_tools.CLI_MIGRATION_TABLE_NAMES, nil, "list of table names to export")
+	exportCSVCmd.PersistentFlags().Int(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE, 1, "number of workers per table")
+	exportCSVCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_AUTO_RANGE, false, "turn on or off auto range detection and chunking")
+	exportCSVCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, 0, "segment size for auto range detection and chunking")
+	exportCSVCmd.PersistentFlags().String(migration_tools.CLI_CSV_OUTPUT_DIR, "", "directory to write the csv files into")
+	exportCSVCmd.PersistentFlags().Uint64(migration_tools.CLI_CSV_MAX_FILE_SIZE, 0, "size in bytes at which a new csv file is started; 0 disables size based rotation")
+	exportCSVCmd.PersistentFlags().Uint64(migration_tools.CLI_CSV_MAX_RANGES_PER_FILE, 0, "number of block ranges after which a new csv file is started; 0 disables range based rotation")
+	exportCSVCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_ADAPTIVE_RANGE, false, "size block ranges by the row volume of each table instead of by a fixed number of blocks")
+	exportCSVCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_TARGET_ROWS, 100000, "number of rows targeted per block range with adaptive range sizing")
+	exportCSVCmd.PersistentFlags().Duration(migration_tools.CLI_MIGRATION_TARGET_LATENCY, 0, "processing time targeted per block range with adaptive range sizing; 0 turns off the latency correction")
+	exportCSVCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_CANONICAL_ONLY, false, "only export the rows of canonical blocks, reporting the non-canonical blocks that are skipped")
+}
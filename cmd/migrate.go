@@ -20,19 +20,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
 
-	"github.com/ethereum/go-ethereum/statediff/indexer/database/sql/postgres"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/sql"
 )
 
 // migrateCmd represents the migrate command
@@ -69,26 +69,67 @@ func migrate() {
 	if err := getGapDirs(); err != nil {
 		logWithCommand.Fatalf("failed to open directories for writing read and write gaps: %v", err)
 	}
+	if conf.DryRun {
+		if err := useDryRunGapDirs(); err != nil {
+			logWithCommand.Fatalf("failed to open directories for writing dry run gaps: %v", err)
+		}
+	}
+	reportWriter, err := useReportWriter(migrator, conf.DryRun)
+	if err != nil {
+		logWithCommand.Fatalf("failed to open report file: %v", err)
+	}
 
-	ranges, err := getRanges(conf.ReadDB)
+	tableRanges, err := getTableRanges(migrator, tables)
 	if err != nil {
 		logWithCommand.Fatalf("failed to load block ranges for processing: %v", err)
 	}
 
-	wg := new(sync.WaitGroup)
-	go func() {
-		for _, table := range tables {
-			migrateTable(wg, migrator, table, ranges)
+	if conf.DryRun {
+		// a dry run has no new DB to look up the committed ranges in
+		logWithCommand.Info("dry run is on, nothing will be written to the new database")
+	} else {
+		tableRanges, err = getPendingRanges(migrator, tableRanges)
+		if err != nil {
+			logWithCommand.Fatalf("failed to load pending block ranges for processing: %v", err)
 		}
-	}()
+	}
 
-	go func() {
-		shutdown := make(chan os.Signal, 1)
-		signal.Notify(shutdown, os.Interrupt)
-		<-shutdown
-		migrator.Close()
-	}()
+	viper.BindEnv(migration_tools.TOML_MIGRATION_ADAPTIVE_RANGE, migration_tools.MIGRATION_ADAPTIVE_RANGE)
+	viper.BindEnv(migration_tools.TOML_MIGRATION_DEPENDENCY_ORDER, migration_tools.MIGRATION_DEPENDENCY_ORDER)
+	if viper.GetBool(migration_tools.TOML_MIGRATION_ADAPTIVE_RANGE) && viper.GetBool(migration_tools.TOML_MIGRATION_DEPENDENCY_ORDER) {
+		logWithCommand.Fatal("adaptive range sizing can not be combined with dependency ordering, " +
+			"since the block ranges of each table are sized independently")
+	}
+
+	tables, err = scheduleTables(migrator, tableRanges)
+	if err != nil {
+		logWithCommand.Fatalf("failed to schedule tables by their dependencies: %v", err)
+	}
+
+	segmenter, err := getSegmenter(migrator, tableRanges)
+	if err != nil {
+		logWithCommand.Fatalf("failed to initialize adaptive range sizing: %v", err)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	wg := new(sync.WaitGroup)
+	for _, table := range tables {
+		migrateTable(ctx, wg, migrator, segmenter, table, tableRanges[table])
+	}
 	wg.Wait()
+	if report := migrator.DryRunReport(); report != nil {
+		logWithCommand.Info("----- dry run summary -----")
+		if err := report.WriteSummary(os.Stdout); err != nil {
+			logWithCommand.Errorf("failed to write dry run summary: %v", err)
+		}
+	}
+	if err := migrator.Close(); err != nil {
+		logWithCommand.Errorf("failed to close Migrator: %v", err)
+	}
+	if err := reportWriter.Close(); err != nil {
+		logWithCommand.Errorf("failed to close report file: %v", err)
+	}
 }
 
 var (
@@ -114,8 +155,52 @@ func getGapDirs() error {
 	return nil
 }
 
-func migrateTable(wg *sync.WaitGroup, migrator migration_tools.Migrator,
-	tableName migration_tools.TableName, blockRanges [][2]uint64) {
+// useDryRunGapDirs points the gap directories to a dryRun subdirectory of each,
+// so that the gaps found by a dry run are not picked up by retry-gaps
+func useDryRunGapDirs() error {
+	readGapsDir = filepath.Join(readGapsDir, "dryRun")
+	writeGapsDir = filepath.Join(writeGapsDir, "dryRun")
+	if err := os.MkdirAll(readGapsDir, 0777); err != nil {
+		return err
+	}
+	return os.MkdirAll(writeGapsDir, 0777)
+}
+
+// useReportWriter opens a new report file for the subcommand in the report directory, and sets the Migrator
+// to write an entry out to it for every block range that fails or is found missing
+// the report of a dry run is written to a dryRun subdirectory, like its gaps
+func useReportWriter(migrator migration_tools.Migrator, dryRun bool) (*migration_tools.ReportWriter, error) {
+	viper.BindEnv(migration_tools.TOML_LOG_REPORT_DIR, migration_tools.LOG_REPORT_DIR)
+	reportDir := viper.GetString(migration_tools.TOML_LOG_REPORT_DIR)
+	if reportDir == "" {
+		return nil, nil
+	}
+	if dryRun {
+		reportDir = filepath.Join(reportDir, "dryRun")
+	}
+	reportWriter, err := migration_tools.NewReportFile(reportDir, subCommand)
+	if err != nil {
+		return nil, err
+	}
+	migrator.UseReportWriter(reportWriter)
+	return reportWriter, nil
+}
+
+// processFunc is the signature shared by Migrator.Migrate and Migrator.TransformToCSV
+type processFunc func(ctx context.Context, wg *sync.WaitGroup, tableName migration_tools.TableName,
+	blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error)
+
+func migrateTable(ctx context.Context, wg *sync.WaitGroup, migrator migration_tools.Migrator,
+	segmenter *migration_tools.AdaptiveSegmenter, tableName migration_tools.TableName, blockRanges [][2]uint64) {
+	processTable(ctx, wg, migrator, migrator.Migrate, segmenter, tableName, blockRanges)
+}
+
+// processTable sends the block ranges for the table through the provided process,
+// writing the read and write gaps it emits out to the gap directories
+// if a segmenter is provided, the block ranges are merged and split back up into ranges sized by it as they are sent
+// the process for the table is shut down once all of its block ranges have been sent, or once ctx is done
+func processTable(ctx context.Context, wg *sync.WaitGroup, migrator migration_tools.Migrator, process processFunc,
+	segmenter *migration_tools.AdaptiveSegmenter, tableName migration_tools.TableName, blockRanges [][2]uint64) {
 
 	now := time.Now().Unix()
 	readGapFilePath := filepath.Join(readGapsDir, string(tableName)+"_"+strconv.Itoa(int(now)))
@@ -132,26 +217,43 @@ func migrateTable(wg *sync.WaitGroup, migrator migration_tools.Migrator,
 	}
 
 	rangeChan := make(chan [2]uint64)
-	readGapsChan, writeGapsChan, doneChan, quitChan, errChan := migrator.Migrate(wg, tableName, rangeChan)
+	readGapsChan, writeGapsChan, doneChan, errChan := process(ctx, wg, tableName, rangeChan)
+
+	adaptive := segmenter != nil && tableName != migration_tools.PublicNodes
+	if adaptive {
+		blockRanges = migration_tools.MergeRanges(blockRanges)
+	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for i, blockRange := range blockRanges {
+		defer close(rangeChan)
+		for i := 0; i < len(blockRanges); {
+			blockRange := blockRanges[i]
+			if adaptive {
+				blockRange = segmenter.Next(tableName, blockRange[0], blockRange[1])
+			}
 			select {
+			case <-ctx.Done():
+				logWithCommand.Infof("closing sendRanges subprocess: %v\r\nunsent ranges: %+v", ctx.Err(), blockRanges[i:])
+				return
 			case <-doneChan:
 				logWithCommand.Infof("closing sendRanges subprocess\r\nunsent ranges: %+v", blockRanges[i:])
 				return
-			default:
-				rangeChan <- blockRange
+			case rangeChan <- blockRange:
 			}
 			if tableName == migration_tools.PublicNodes {
 				// public nodes will be migrated in one batch, since it is not segmented by block height
 				break
 			}
+			if blockRange[1] < blockRanges[i][1] {
+				// the rest of the range is sized with what the workers have observed in the meantime
+				blockRanges[i][0] = blockRange[1] + 1
+				continue
+			}
+			i++
 		}
 		logWithCommand.Infof("finished sending block ranges for table %s\r\nshutting down migration process for table %s", tableName, tableName)
-		close(quitChan)
 	}()
 
 	// handle writing out gaps
@@ -211,17 +313,63 @@ func getTableNames() ([]migration_tools.TableName, error) {
 	return tableNames, nil
 }
 
-func getRanges(readConf postgres.Config) ([][2]uint64, error) {
+// rangeDetector detects the block ranges to process for a table within bounds, through a connection it already holds
+// it is satisfied by Migrator, Verifier, and TrieChecker
+type rangeDetector interface {
+	DetectRanges(tableName migration_tools.TableName, bounds [2]uint64) ([][2]uint64, error)
+}
+
+// getTableRanges returns the block ranges to process for each table
+// with auto range detection on, these are the ranges the detector finds for each table, e.g. the ranges a Migrator finds
+// a table has rows at in the old DB but not yet in the new DB, within the configured ranges if any, segmented by the
+// segment size; otherwise every table is processed over the configured ranges
+func getTableRanges(detector rangeDetector,
+	tables []migration_tools.TableName) (map[migration_tools.TableName][][2]uint64, error) {
 	viper.BindEnv(migration_tools.TOML_MIGRATION_AUTO_RANGE, migration_tools.MIGRATION_AUTO_RANGE)
 	viper.BindEnv(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, migration_tools.MIGRATION_AUTO_RANGE_SEGMENT_SIZE)
-	if viper.GetBool(migration_tools.TOML_MIGRATION_AUTO_RANGE) && viper.IsSet(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE) {
-		segmentSize := viper.GetUint64(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE)
-		if segmentSize == 0 {
-			return nil, errors.New("auto range detection and segmenting is on, but segment size is set to 0")
+	tableRanges := make(map[migration_tools.TableName][][2]uint64, len(tables))
+	if !viper.GetBool(migration_tools.TOML_MIGRATION_AUTO_RANGE) || !viper.IsSet(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE) {
+		ranges, err := getConfiguredRanges()
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range tables {
+			tableRanges[table] = ranges
+		}
+		return tableRanges, nil
+	}
+	segmentSize := viper.GetUint64(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE)
+	if segmentSize == 0 {
+		return nil, errors.New("auto range detection and segmenting is on, but segment size is set to 0")
+	}
+	logWithCommand.Infof("auto range detection and segmenting is on, with segment size of %d", segmentSize)
+	bounds, err := getConfiguredRanges()
+	if err != nil {
+		// without any configured ranges, every height is searched
+		bounds = [][2]uint64{{0, math.MaxInt64}}
+	}
+	bounds = migration_tools.MergeRanges(bounds)
+	for _, table := range tables {
+		var detected [][2]uint64
+		for _, bound := range bounds {
+			boundDetected, err := detector.DetectRanges(table, bound)
+			if err != nil {
+				return nil, err
+			}
+			detected = append(detected, boundDetected...)
 		}
-		logWithCommand.Infof("auto range detection and segmenting is on, with segment size of %d", segmentSize)
-		return migration_tools.DetectAndSegmentRangeByChunkSize(readConf, segmentSize)
+		segments := make([][2]uint64, 0, len(detected))
+		for _, rng := range detected {
+			segments = append(segments, migration_tools.SegmentRangeByChunkSize(segmentSize, rng[0], rng[1])...)
+		}
+		logWithCommand.Infof("detected %d block ranges for table %s, in %d segments", len(detected), table, len(segments))
+		tableRanges[table] = segments
 	}
+	return tableRanges, nil
+}
+
+// getConfiguredRanges returns the block ranges configured through the ranges, start, and stop options
+func getConfiguredRanges() ([][2]uint64, error) {
 	viper.BindEnv(migration_tools.TOML_MIGRATION_START, migration_tools.MIGRATION_START)
 	viper.BindEnv(migration_tools.TOML_MIGRATION_STOP, migration_tools.MIGRATION_STOP)
 	var blockRanges [][2]uint64
@@ -237,6 +385,81 @@ func getRanges(readConf postgres.Config) ([][2]uint64, error) {
 	return blockRanges, nil
 }
 
+// getSegmenter returns an AdaptiveSegmenter sampled across the pending block ranges of every table,
+// and sets the Migrator to report the completed ranges to it, if adaptive range sizing is on
+func getSegmenter(migrator migration_tools.Migrator,
+	tableRanges map[migration_tools.TableName][][2]uint64) (*migration_tools.AdaptiveSegmenter, error) {
+	viper.BindEnv(migration_tools.TOML_MIGRATION_ADAPTIVE_RANGE, migration_tools.MIGRATION_ADAPTIVE_RANGE)
+	viper.BindEnv(migration_tools.TOML_MIGRATION_TARGET_ROWS, migration_tools.MIGRATION_TARGET_ROWS)
+	viper.BindEnv(migration_tools.TOML_MIGRATION_TARGET_LATENCY, migration_tools.MIGRATION_TARGET_LATENCY)
+	viper.BindEnv(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, migration_tools.MIGRATION_AUTO_RANGE_SEGMENT_SIZE)
+	if !viper.GetBool(migration_tools.TOML_MIGRATION_ADAPTIVE_RANGE) {
+		return nil, nil
+	}
+	targetRows := viper.GetUint64(migration_tools.TOML_MIGRATION_TARGET_ROWS)
+	targetLatency := viper.GetDuration(migration_tools.TOML_MIGRATION_TARGET_LATENCY)
+	maxSegmentSize := viper.GetUint64(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE)
+	segmenter, err := migration_tools.NewAdaptiveSegmenter(targetRows, targetLatency, maxSegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	for table, ranges := range tableRanges {
+		if err := migrator.SampleRanges(segmenter, table, migration_tools.MergeRanges(ranges)); err != nil {
+			return nil, err
+		}
+	}
+	logWithCommand.Infof("adaptive range sizing is on, targeting %d rows per range (latency target: %s, max segment size: %d)",
+		targetRows, targetLatency, maxSegmentSize)
+	migrator.UseRangeObserver(segmenter)
+	return segmenter, nil
+}
+
+// getPendingRanges returns the block ranges to process for each table
+// if resume is on, the ranges already checkpointed as committed for a table are removed from that table's set
+func getPendingRanges(migrator migration_tools.Migrator,
+	tableRanges map[migration_tools.TableName][][2]uint64) (map[migration_tools.TableName][][2]uint64, error) {
+	viper.BindEnv(migration_tools.TOML_MIGRATION_RESUME, migration_tools.MIGRATION_RESUME)
+	if !viper.GetBool(migration_tools.TOML_MIGRATION_RESUME) {
+		return tableRanges, nil
+	}
+	pendingRanges := make(map[migration_tools.TableName][][2]uint64, len(tableRanges))
+	for table, ranges := range tableRanges {
+		committed, err := migrator.CommittedRanges(table)
+		if err != nil {
+			return nil, err
+		}
+		pendingRanges[table] = migration_tools.SubtractRanges(ranges, committed)
+		logWithCommand.Infof("resuming table %s with %d pending block ranges", table, len(pendingRanges[table]))
+	}
+	return pendingRanges, nil
+}
+
+// scheduleTables returns the tables to migrate, ordered by their dependencies
+// if dependency ordering is on, the Migrator is also set to only migrate a block range for a table
+// once that range has been committed for every table it depends on
+func scheduleTables(migrator migration_tools.Migrator,
+	tableRanges map[migration_tools.TableName][][2]uint64) ([]migration_tools.TableName, error) {
+	tables := make([]migration_tools.TableName, 0, len(tableRanges))
+	for table := range tableRanges {
+		tables = append(tables, table)
+	}
+	tables, err := migration_tools.SortTablesByDependency(tables)
+	if err != nil {
+		return nil, err
+	}
+	viper.BindEnv(migration_tools.TOML_MIGRATION_DEPENDENCY_ORDER, migration_tools.MIGRATION_DEPENDENCY_ORDER)
+	if !viper.GetBool(migration_tools.TOML_MIGRATION_DEPENDENCY_ORDER) {
+		return tables, nil
+	}
+	scheduler, err := migration_tools.NewScheduler(tableRanges)
+	if err != nil {
+		return nil, err
+	}
+	migrator.UseScheduler(scheduler)
+	logWithCommand.Infof("dependency ordering is on, tables will be migrated in the order: %v", tables)
+	return tables, nil
+}
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 
@@ -247,6 +470,18 @@ func init() {
 	migrateCmd.PersistentFlags().Int(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE, 1, "number of workers per table")
 	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_AUTO_RANGE, false, "turn on or off auto range detection and chunking")
 	migrateCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, 0, "segment size for auto range detection and chunking")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_CHECKPOINT, false, "turn on or off recording committed block ranges in the migration_tools schema of the new database")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_RESUME, false, "skip block ranges that have already been checkpointed as committed")
+	migrateCmd.PersistentFlags().String(migration_tools.CLI_MIGRATION_CONFLICT_POLICY, string(sql.ConflictError), "how writes handle primary key conflicts (error, do-nothing, update)")
+	migrateCmd.PersistentFlags().String(migration_tools.CLI_MIGRATION_WRITE_MODE, string(sql.WriteModeInsert), "how v3 models are written (insert, copy)")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_DEPENDENCY_ORDER, false, "only migrate a block range for a table once it has been committed for the tables it references")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_CANONICAL_ONLY, false, "only migrate the rows of canonical blocks, reporting the non-canonical blocks that are skipped")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_DRY_RUN, false, "read and transform the block ranges without writing anything to the new database, and print a summary")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_VALIDATE_IPLDS, false, "check that the IPLDs referenced by the transformed rows are in public.blocks and hash to their cids before writing the rows")
+	migrateCmd.PersistentFlags().String(migration_tools.CLI_MIGRATION_VALIDATE_IPLDS_SOURCE, string(migration_tools.IPLDSourceOld), "database whose public.blocks the IPLDs are validated against (old, new)")
+	migrateCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_ADAPTIVE_RANGE, false, "size block ranges by the row volume of each table instead of by a fixed number of blocks")
+	migrateCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_TARGET_ROWS, 100000, "number of rows targeted per block range with adaptive range sizing")
+	migrateCmd.PersistentFlags().Duration(migration_tools.CLI_MIGRATION_TARGET_LATENCY, 0, "processing time targeted per block range with adaptive range sizing; 0 turns off the latency correction")
 
 	// migrator TOML bindings
 	viper.BindPFlag(migration_tools.TOML_MIGRATION_START, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_START))
@@ -255,4 +490,16 @@ func init() {
 	viper.BindPFlag(migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE, migrateCmd.PersistentFlags().Lookup(migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE))
 	viper.BindPFlag(migration_tools.TOML_MIGRATION_AUTO_RANGE, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_AUTO_RANGE))
 	viper.BindPFlag(migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_CHECKPOINT, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_CHECKPOINT))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_RESUME, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_RESUME))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_CONFLICT_POLICY, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_CONFLICT_POLICY))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_WRITE_MODE, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_WRITE_MODE))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_DEPENDENCY_ORDER, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_DEPENDENCY_ORDER))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_CANONICAL_ONLY, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_CANONICAL_ONLY))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_DRY_RUN, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_DRY_RUN))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_VALIDATE_IPLDS, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_VALIDATE_IPLDS))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_VALIDATE_IPLDS_SOURCE, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_VALIDATE_IPLDS_SOURCE))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_ADAPTIVE_RANGE, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_ADAPTIVE_RANGE))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_TARGET_ROWS, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_TARGET_ROWS))
+	viper.BindPFlag(migration_tools.TOML_MIGRATION_TARGET_LATENCY, migrateCmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_TARGET_LATENCY))
 }
@@ -0,0 +1,94 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Tools for working with the JSON Lines gap and error reports",
+	Long: `Tools for working with the JSON Lines reports written out to the report directory by the migrate, retry-gaps,
+export-csv, and transfer commands.
+
+Each line of a report is a block range (or page range, for transfer) that failed or was found missing,
+with its table, gap kind (read, transform, write, transfer), error message, worker id, and timestamp.`,
+}
+
+// reportSummarizeCmd represents the report summarize command
+var reportSummarizeCmd = &cobra.Command{
+	Use:   "summarize [report files or directories...]",
+	Short: "Aggregate report files into totals per table",
+	Long: `Aggregates the entries of every report file in the report directory, or of the report files and directories
+passed as arguments, into totals per table across runs.
+
+For each gap kind it prints the number of entries and the number of distinct blocks they cover,
+so that a range reported again by a later run is only counted once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *logrus.WithField("SubCommand", subCommand)
+		summarizeReports(args)
+	},
+}
+
+func summarizeReports(args []string) {
+	if len(args) == 0 {
+		viper.BindEnv(migration_tools.TOML_LOG_REPORT_DIR, migration_tools.LOG_REPORT_DIR)
+		args = []string{viper.GetString(migration_tools.TOML_LOG_REPORT_DIR)}
+	}
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			logWithCommand.Fatalf("unable to open report path %s: %v", arg, err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		files, err := migration_tools.ReportFiles(arg)
+		if err != nil {
+			logWithCommand.Fatalf("unable to read report directory %s: %v", arg, err)
+		}
+		paths = append(paths, files...)
+	}
+	if len(paths) == 0 {
+		logWithCommand.Info("no report files found to summarize")
+		return
+	}
+	summaries, err := migration_tools.SummarizeReports(paths)
+	if err != nil {
+		logWithCommand.Fatalf("failed to summarize report files: %v", err)
+	}
+	logWithCommand.Infof("summarized %d report files", len(paths))
+	if err := migration_tools.WriteReportSummaries(os.Stdout, summaries); err != nil {
+		logWithCommand.Fatalf("failed to write report summary: %v", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.AddCommand(reportSummarizeCmd)
+}
@@ -0,0 +1,172 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+// retryGapsCmd represents the retry-gaps command
+var retryGapsCmd = &cobra.Command{
+	Use:   "retry-gaps",
+	Short: "Tool for re-migrating the block ranges recorded in read and write gap files",
+	Long: `Tool for reading the block ranges written out to the read and write gap directories by previous
+migrations, deduplicating them per table, and migrating them again. Overlapping ranges are merged, while adjacent
+ranges are retried apart so that they keep the size they were segmented by.
+
+Can be configured to work over a subset of the tables; by default it retries the gaps of every table found in the gap files.
+
+Gap files that have been retried are renamed with a .retried suffix, and any ranges that still fail
+are written out to fresh gap files.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *logrus.WithField("SubCommand", subCommand)
+		viper.BindPFlag(migration_tools.TOML_MIGRATION_TABLE_NAMES, cmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_TABLE_NAMES))
+		viper.BindPFlag(migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE, cmd.PersistentFlags().Lookup(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE))
+		retryGaps()
+	},
+}
+
+func retryGaps() {
+	logWithCommand.Info("----- retrying gaps -----")
+	if err := getGapDirs(); err != nil {
+		logWithCommand.Fatalf("failed to open directories for reading and writing read and write gaps: %v", err)
+	}
+
+	tableRanges, gapFiles, err := getGapRanges()
+	if err != nil {
+		logWithCommand.Fatalf("failed to load gap files: %v", err)
+	}
+	if len(tableRanges) == 0 {
+		logWithCommand.Info("no gaps found to retry")
+		return
+	}
+
+	conf := migration_tools.NewConfig()
+	logWithCommand.Infof("initializing a new Migrator with config params: %+v", conf)
+	migrator, err := migration_tools.NewMigrator(context.Background(), conf)
+	if err != nil {
+		logWithCommand.Fatalf("failed to initialize a new Migrator: %v", err)
+	}
+	reportWriter, err := useReportWriter(migrator, false)
+	if err != nil {
+		logWithCommand.Fatalf("failed to open report file: %v", err)
+	}
+
+	tables, err := scheduleTables(migrator, tableRanges)
+	if err != nil {
+		logWithCommand.Fatalf("failed to schedule tables by their dependencies: %v", err)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	wg := new(sync.WaitGroup)
+	for _, table := range tables {
+		logWithCommand.Infof("retrying %d gap ranges for table %s", len(tableRanges[table]), table)
+		migrateTable(ctx, wg, migrator, nil, table, tableRanges[table])
+	}
+	wg.Wait()
+	if err := migrator.Close(); err != nil {
+		logWithCommand.Errorf("failed to close Migrator: %v", err)
+	}
+	if err := reportWriter.Close(); err != nil {
+		logWithCommand.Errorf("failed to close report file: %v", err)
+	}
+
+	if ctx.Err() != nil {
+		logWithCommand.Info("retry interrupted, leaving gap files in place")
+		return
+	}
+	for _, path := range gapFiles {
+		if err := os.Rename(path, path+migration_tools.RetriedGapFileSuffix); err != nil {
+			logWithCommand.Errorf("unable to mark gap file %s as retried: %v", path, err)
+		}
+	}
+}
+
+// getGapRanges loads and deduplicates the gaps for the configured tables from the read and write gap directories,
+// and from the verify mismatch directory if it exists
+// if no tables are configured, the gaps for every table found in the directories are loaded
+func getGapRanges() (map[migration_tools.TableName][][2]uint64, []string, error) {
+	var tables []migration_tools.TableName
+	if len(viper.GetStringSlice(migration_tools.TOML_MIGRATION_TABLE_NAMES)) != 0 {
+		var err error
+		tables, err = getTableNames()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	selected := func(table migration_tools.TableName) bool {
+		if len(tables) == 0 {
+			return true
+		}
+		for _, t := range tables {
+			if t == table {
+				return true
+			}
+		}
+		return false
+	}
+
+	tableRanges := make(map[migration_tools.TableName][][2]uint64)
+	gapFiles := make([]string, 0)
+	dirs := []string{readGapsDir, writeGapsDir}
+	viper.BindEnv(migration_tools.TOML_LOG_MISMATCH_DIR, migration_tools.LOG_MISMATCH_DIR)
+	if mismatchDir := viper.GetString(migration_tools.TOML_LOG_MISMATCH_DIR); mismatchDir != "" {
+		if _, err := os.Stat(mismatchDir); err == nil {
+			dirs = append(dirs, mismatchDir)
+		}
+	}
+	for _, dir := range dirs {
+		gaps, files, err := migration_tools.ReadGapDir(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for table, ranges := range gaps {
+			if !selected(table) {
+				continue
+			}
+			tableRanges[table] = append(tableRanges[table], ranges...)
+			gapFiles = append(gapFiles, files[table]...)
+		}
+	}
+	for table, ranges := range tableRanges {
+		deduped := migration_tools.DedupeRanges(ranges)
+		if len(deduped) == 0 {
+			delete(tableRanges, table)
+			continue
+		}
+		tableRanges[table] = deduped
+	}
+	return tableRanges, gapFiles, nil
+}
+
+func init() {
+	rootCmd.AddCommand(retryGapsCmd)
+
+	// retry-gaps flags
+	retryGapsCmd.PersistentFlags().StringArray(migration_tools.CLI_MIGRATION_TABLE_NAMES, nil, "list of table names to retry gaps for; defaults to every table found in the gap files")
+	retryGapsCmd.PersistentFlags().Int(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE, 1, "number of workers per table")
+}
@@ -17,8 +17,10 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -26,6 +28,7 @@ import (
 	"github.com/spf13/viper"
 
 	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/metrics"
 )
 
 var (
@@ -65,6 +68,23 @@ func initFuncs(cmd *cobra.Command, args []string) {
 	if err := logLevel(); err != nil {
 		log.Fatal("Could not set log level: ", err)
 	}
+
+	viper.BindEnv(migration_tools.TOML_METRICS_ENABLED, migration_tools.METRICS_ENABLED)
+	viper.BindEnv(migration_tools.TOML_METRICS_ADDRESS, migration_tools.METRICS_ADDRESS)
+	if viper.GetBool(migration_tools.TOML_METRICS_ENABLED) {
+		metrics.StartServer(viper.GetString(migration_tools.TOML_METRICS_ADDRESS))
+	}
+}
+
+// interruptContext returns a context that is cancelled on the first interrupt signal,
+// after which a second interrupt signal terminates the process as usual
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
 }
 
 func logLevel() error {
@@ -92,9 +112,15 @@ func init() {
 	rootCmd.PersistentFlags().String(migration_tools.CLI_LOG_READ_GAPS_DIR, "./readGaps/", "directory to write out read gaps to")
 	rootCmd.PersistentFlags().String(migration_tools.CLI_LOG_WRITE_GAPS_DIR, "./writeGaps/", "directory to write out write gaps to")
 	rootCmd.PersistentFlags().String(migration_tools.CLI_LOG_TRANSFER_GAPS_DIR, "./transferGaps/", "directory to write out transfer gaps to")
+	rootCmd.PersistentFlags().String(migration_tools.CLI_LOG_NON_CANONICAL_DIR, "./nonCanonical/", "directory to write out the non-canonical blocks skipped in canonical-only mode to")
+	rootCmd.PersistentFlags().String(migration_tools.CLI_LOG_REPORT_DIR, "./reports/", "directory to write out the JSON Lines gap and error reports to")
 	rootCmd.PersistentFlags().String(migration_tools.CLI_LOGRUS_LEVEL, log.InfoLevel.String(), "log level (trace, debug, info, warn, error, fatal, panic)")
 	rootCmd.PersistentFlags().String(migration_tools.CLI_LOGRUS_FILE, "", "file path for logging")
 
+	// metrics flags
+	rootCmd.PersistentFlags().Bool(migration_tools.CLI_METRICS_ENABLED, false, "turn on or off the prometheus metrics server")
+	rootCmd.PersistentFlags().String(migration_tools.CLI_METRICS_ADDRESS, "127.0.0.1:8090", "address for the prometheus metrics server to listen on")
+
 	// old db flags
 	rootCmd.PersistentFlags().String(migration_tools.CLI_OLD_DATABASE_NAME, "vulcanize_old", "name for the old database")
 	rootCmd.PersistentFlags().String(migration_tools.CLI_OLD_DATABASE_HOSTNAME, "localhost", "hostname for the old database")
@@ -118,9 +144,15 @@ func init() {
 	// log TOML bindings
 	viper.BindPFlag(migration_tools.TOML_LOG_READ_GAPS_DIR, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_LOG_READ_GAPS_DIR))
 	viper.BindPFlag(migration_tools.TOML_LOG_WRITE_GAPS_DIR, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_LOG_WRITE_GAPS_DIR))
+	viper.BindPFlag(migration_tools.TOML_LOG_NON_CANONICAL_DIR, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_LOG_NON_CANONICAL_DIR))
+	viper.BindPFlag(migration_tools.TOML_LOG_REPORT_DIR, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_LOG_REPORT_DIR))
 	viper.BindPFlag(migration_tools.TOML_LOGRUS_LEVEL, rootCmd.PersistentFlags().Lookup("log-level"))
 	viper.BindPFlag(migration_tools.TOML_LOGRUS_FILE, rootCmd.PersistentFlags().Lookup("log-file"))
 
+	// metrics TOML bindings
+	viper.BindPFlag(migration_tools.TOML_METRICS_ENABLED, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_METRICS_ENABLED))
+	viper.BindPFlag(migration_tools.TOML_METRICS_ADDRESS, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_METRICS_ADDRESS))
+
 	// old db TOML bindings
 	viper.BindPFlag(migration_tools.TOML_OLD_DATABASE_NAME, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_OLD_DATABASE_NAME))
 	viper.BindPFlag(migration_tools.TOML_OLD_DATABASE_HOSTNAME, rootCmd.PersistentFlags().Lookup(migration_tools.CLI_OLD_DATABASE_HOSTNAME))
@@ -20,7 +20,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"strconv"
 	"sync"
@@ -32,6 +31,7 @@ import (
 
 	migration_tools "github.com/vulcanize/migration-tools/pkg"
 	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/sql"
 )
 
 // transferCmd represents the transfer command
@@ -42,10 +42,24 @@ var transferCmd = &cobra.Command{
 in a manner that is able to handle potential unique constraints conflict
 (e.g. calling out to a postgres_fdw procedure).
 
-Enables batching and proper logging of errors and progress during the process.`,
+Enables batching and proper logging of errors and progress during the process.
+
+Page segments are transferred by the configured number of workers concurrently, and with checkpointing on each
+transferred segment is recorded in the new database so that a restarted transfer skips it. A failed segment is
+retried with an exponential backoff before it is written out to the transfer gap directory.
+
+In the direct transfer mode no postgres_fdw foreign table is needed: the pages of public.blocks are read from the
+old database and written to the new database with the configured write mode (insert or copy).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		subCommand = cmd.CalledAs()
 		logWithCommand = *logrus.WithField("SubCommand", subCommand)
+		for toml, cli := range map[string]string{
+			migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE: migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE,
+			migration_tools.TOML_MIGRATION_CHECKPOINT:        migration_tools.CLI_MIGRATION_CHECKPOINT,
+			migration_tools.TOML_MIGRATION_WRITE_MODE:        migration_tools.CLI_MIGRATION_WRITE_MODE,
+		} {
+			viper.BindPFlag(toml, cmd.PersistentFlags().Lookup(cli))
+		}
 		transfer()
 	},
 }
@@ -62,25 +76,31 @@ func transfer() {
 	if err := getTransferGapDir(); err != nil {
 		logWithCommand.Fatalf("failed to open directory for writing transfer gaps: %v", err)
 	}
+	reportWriter, err := useReportWriter(transferor, false)
+	if err != nil {
+		logWithCommand.Fatalf("failed to open report file: %v", err)
+	}
 
+	ctx, cancel := interruptContext()
+	defer cancel()
 	wg := new(sync.WaitGroup)
 	viper.BindEnv(migration_tools.TOML_TRANSFER_TABLE_NAME, migration_tools.TRANSFER_TABLE_NAME)
 	viper.BindEnv(migration_tools.TOML_TRANSFER_SEGMENT_SIZE, migration_tools.TRANSFER_SEGMENT_SIZE)
 	viper.BindEnv(migration_tools.TOML_TRANSFER_SEGMENT_OFFSET, migration_tools.TRANSFER_SEGMENT_OFFSET)
 	viper.BindEnv(migration_tools.TOML_TRANSFER_MAX_PAGE, migration_tools.TRANSFER_MAX_PAGE)
-	transferTable(wg, transferor,
+	transferTable(ctx, wg, transferor,
 		viper.GetString(migration_tools.TOML_TRANSFER_TABLE_NAME),
 		viper.GetUint64(migration_tools.TOML_TRANSFER_SEGMENT_SIZE),
 		viper.GetUint64(migration_tools.TOML_TRANSFER_SEGMENT_OFFSET),
 		viper.GetUint64(migration_tools.TOML_TRANSFER_MAX_PAGE))
 
-	go func() {
-		shutdown := make(chan os.Signal, 1)
-		signal.Notify(shutdown, os.Interrupt)
-		<-shutdown
-		transferor.Close()
-	}()
 	wg.Wait()
+	if err := transferor.Close(); err != nil {
+		logWithCommand.Errorf("failed to close Transferor: %v", err)
+	}
+	if err := reportWriter.Close(); err != nil {
+		logWithCommand.Errorf("failed to close report file: %v", err)
+	}
 }
 
 var transferGapDir string
@@ -96,7 +116,7 @@ func getTransferGapDir() error {
 	return nil
 }
 
-func transferTable(wg *sync.WaitGroup, transferor migration_tools.Migrator, tableName string,
+func transferTable(ctx context.Context, wg *sync.WaitGroup, transferor migration_tools.Migrator, tableName string,
 	segmentSize, segmentOffset, maxPage uint64) {
 	now := time.Now().Unix()
 	transferGapFilePath := filepath.Join(transferGapDir, string(tableName)+"_"+strconv.Itoa(int(now)))
@@ -106,7 +126,7 @@ func transferTable(wg *sync.WaitGroup, transferor migration_tools.Migrator, tabl
 		logWithCommand.Fatalf("unable to open transfer gap file at %s", transferGapFilePath)
 	}
 
-	gapChan, doneChan, errChan, err := transferor.Transfer(wg, tableName, segmentSize, segmentOffset, maxPage)
+	gapChan, doneChan, errChan, err := transferor.Transfer(ctx, wg, tableName, segmentSize, segmentOffset, maxPage)
 	if err != nil {
 		logWithCommand.Fatalf("transfer initialization failed: %v", err)
 	}
@@ -152,10 +172,19 @@ func init() {
 	transferCmd.PersistentFlags().String(migration_tools.CLI_TRANSFER_TABLE_NAME, public_blocks.DefaultV2FDWTableName, "postgres_fdw table name in the new database")
 	transferCmd.PersistentFlags().Uint64(migration_tools.CLI_TRANSFER_MAX_PAGE, 0, "configure the max page; if left 0 then MAX(ctid) is queried from the DB (which can take a long time)")
 	transferCmd.PersistentFlags().Uint64(migration_tools.CLI_TRANSFER_SEGMENT_OFFSET, 0, "starting offset for the number of segments we process (for picking up where a previous process stopped)")
+	transferCmd.PersistentFlags().Int(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE, 1, "number of workers transferring page segments concurrently")
+	transferCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_CHECKPOINT, false, "turn on or off recording transferred page segments in the migration_tools schema of the new database, and skipping them on restart")
+	transferCmd.PersistentFlags().Int(migration_tools.CLI_TRANSFER_RETRIES, 3, "number of times a failed page segment is retried before it is written out as a transfer gap")
+	transferCmd.PersistentFlags().String(migration_tools.CLI_TRANSFER_MODE, string(public_blocks.TransferModeFDW), "how public.blocks is transferred (fdw, direct)")
+	transferCmd.PersistentFlags().String(migration_tools.CLI_MIGRATION_WRITE_MODE, string(sql.WriteModeInsert), "how the rows are written in the direct transfer mode (insert, copy)")
+	transferCmd.PersistentFlags().Duration(migration_tools.CLI_TRANSFER_RETRY_BACKOFF, time.Second, "wait before the first retry of a failed page segment, doubling with every retry")
 
 	// transferor TOML bindings
 	viper.BindPFlag(migration_tools.TOML_TRANSFER_SEGMENT_SIZE, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_SEGMENT_SIZE))
 	viper.BindPFlag(migration_tools.TOML_TRANSFER_TABLE_NAME, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_TABLE_NAME))
 	viper.BindPFlag(migration_tools.TOML_TRANSFER_MAX_PAGE, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_MAX_PAGE))
 	viper.BindPFlag(migration_tools.TOML_TRANSFER_SEGMENT_OFFSET, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_SEGMENT_OFFSET))
+	viper.BindPFlag(migration_tools.TOML_TRANSFER_RETRIES, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_RETRIES))
+	viper.BindPFlag(migration_tools.TOML_TRANSFER_MODE, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_MODE))
+	viper.BindPFlag(migration_tools.TOML_TRANSFER_RETRY_BACKOFF, transferCmd.PersistentFlags().Lookup(migration_tools.CLI_TRANSFER_RETRY_BACKOFF))
 }
@@ -0,0 +1,183 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Tool for verifying the contents of the new DB against the old DB",
+	Long: `Tool for comparing, per table and block range, the row counts and an order independent hash of the key columns
+(block hash, tx hash, CID, mh_key, etc) of the old database with those of the new database.
+
+Can be configured to work over a subset of the tables, and over specific block ranges.
+
+Mismatching ranges, and ranges that could not be verified, are written out to the mismatch directory
+in the same format as the gap files, so that they can be fed back into retry-gaps.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *logrus.WithField("SubCommand", subCommand)
+		for toml, cli := range map[string]string{
+			migration_tools.TOML_MIGRATION_START:                   migration_tools.CLI_MIGRATION_START,
+			migration_tools.TOML_MIGRATION_STOP:                    migration_tools.CLI_MIGRATION_STOP,
+			migration_tools.TOML_MIGRATION_TABLE_NAMES:             migration_tools.CLI_MIGRATION_TABLE_NAMES,
+			migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE:       migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE,
+			migration_tools.TOML_MIGRATION_AUTO_RANGE:              migration_tools.CLI_MIGRATION_AUTO_RANGE,
+			migration_tools.TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE: migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE,
+			migration_tools.TOML_LOG_MISMATCH_DIR:                  migration_tools.CLI_LOG_MISMATCH_DIR,
+			migration_tools.TOML_MIGRATION_CANONICAL_ONLY:          migration_tools.CLI_MIGRATION_CANONICAL_ONLY,
+		} {
+			viper.BindPFlag(toml, cmd.PersistentFlags().Lookup(cli))
+		}
+		verify()
+	},
+}
+
+func verify() {
+	logWithCommand.Info("----- running verification -----")
+	conf := migration_tools.NewConfig()
+	logWithCommand.Infof("initializing a new Verifier with config params: %+v", conf)
+	verifier, err := migration_tools.NewVerifier(context.Background(), conf)
+	if err != nil {
+		logWithCommand.Fatalf("failed to initialize a new Verifier: %v", err)
+	}
+	defer verifier.Close()
+
+	tables, err := getTableNames()
+	if err != nil {
+		logWithCommand.Fatalf("failed to generate set of TableNames for processing: %v", err)
+	}
+
+	viper.BindEnv(migration_tools.TOML_LOG_MISMATCH_DIR, migration_tools.LOG_MISMATCH_DIR)
+	mismatchDir := viper.GetString(migration_tools.TOML_LOG_MISMATCH_DIR)
+	if err := os.MkdirAll(mismatchDir, 0777); err != nil {
+		logWithCommand.Fatalf("failed to open directory for writing mismatches: %v", err)
+	}
+
+	tableRanges, err := getTableRanges(verifier, tables)
+	if err != nil {
+		logWithCommand.Fatalf("failed to load block ranges for processing: %v", err)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	wg := new(sync.WaitGroup)
+	for _, table := range tables {
+		wg.Add(1)
+		go func(table migration_tools.TableName) {
+			defer wg.Done()
+			verifyTable(ctx, verifier, table, tableRanges[table], mismatchDir)
+		}(table)
+	}
+	wg.Wait()
+}
+
+// verifyTable verifies the block ranges for the table across the configured number of workers,
+// writing mismatching ranges and ranges that failed to verify out to a file in the mismatch directory
+func verifyTable(ctx context.Context, verifier *migration_tools.Verifier, tableName migration_tools.TableName,
+	blockRanges [][2]uint64, mismatchDir string) {
+	mismatchFilePath := filepath.Join(mismatchDir, string(tableName)+"_"+strconv.Itoa(int(time.Now().Unix())))
+	mismatchFile, err := os.OpenFile(mismatchFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		logWithCommand.Errorf("unable to open mismatch file at %s: %v", mismatchFilePath, err)
+		return
+	}
+	defer mismatchFile.Close()
+
+	numWorkers := viper.GetInt(migration_tools.TOML_MIGRATION_WORKERS_PER_TABLE)
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	rangeChan := make(chan [2]uint64)
+	mismatchChan := make(chan [2]uint64)
+	workerWg := new(sync.WaitGroup)
+	for workerNum := 1; workerNum <= numWorkers; workerNum++ {
+		workerWg.Add(1)
+		go func(workerNum int) {
+			defer workerWg.Done()
+			for rng := range rangeChan {
+				result, err := verifier.Verify(tableName, rng)
+				if err != nil {
+					logWithCommand.Errorf("table %s worker %d unable to verify range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+					mismatchChan <- rng
+					continue
+				}
+				if !result.Match() {
+					logWithCommand.Warnf("table %s range (%d, %d) mismatch: v2 count %d hash %s, v3 count %d hash %s", tableName,
+						rng[0], rng[1], result.V2Count, result.V2Hash, result.V3Count, result.V3Hash)
+					mismatchChan <- rng
+					continue
+				}
+				logWithCommand.Infof("table %s range (%d, %d) verified- %d records", tableName, rng[0], rng[1], result.V3Count)
+			}
+		}(workerNum)
+	}
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for mismatch := range mismatchChan {
+			if _, err := mismatchFile.WriteString(fmt.Sprintf("%d, %d\r\n", mismatch[0], mismatch[1])); err != nil {
+				logWithCommand.Errorf("error writing mismatch to file at %s; err: %s", mismatchFilePath, err.Error())
+			}
+		}
+	}()
+
+	for i, blockRange := range blockRanges {
+		select {
+		case <-ctx.Done():
+			logWithCommand.Infof("quitting verification of table %s\r\nunverified ranges: %+v", tableName, blockRanges[i:])
+		case rangeChan <- blockRange:
+			continue
+		}
+		break
+	}
+	close(rangeChan)
+	workerWg.Wait()
+	close(mismatchChan)
+	<-writerDone
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	// verify flags
+	verifyCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_START, 0, "start height")
+	verifyCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_STOP, 0, "stop height")
+	verifyCmd.PersistentFlags().StringArray(migration_tools.CLI_MIGRATION_TABLE_NAMES, nil, "list of table names to verify")
+	verifyCmd.PersistentFlags().Int(migration_tools.CLI_MIGRATION_WORKERS_PER_TABLE, 1, "number of workers per table")
+	verifyCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_AUTO_RANGE, false, "turn on or off auto range detection and chunking")
+	verifyCmd.PersistentFlags().Uint64(migration_tools.CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE, 0, "segment size for auto range detection and chunking")
+	verifyCmd.PersistentFlags().String(migration_tools.CLI_LOG_MISMATCH_DIR, "./mismatches/", "directory to write mismatching block ranges into")
+	verifyCmd.PersistentFlags().Bool(migration_tools.CLI_MIGRATION_CANONICAL_ONLY, false, "only compare the rows of canonical blocks, as migrated in canonical-only mode")
+}
@@ -129,20 +129,19 @@ var _ = Describe("Benchmark log repair", Serial, func() {
 			fmt.Printf("----------running benchmark %d----------\r\n", i)
 			start = time.Now()
 			rangeChan := make(chan [2]uint64)
-			readGapsChan, writeGapsChan, doneChan, quitChan, errChan := migrator.Migrate(wg, migration_tools.EthLogsRepair, rangeChan)
+			readGapsChan, writeGapsChan, doneChan, errChan := migrator.Migrate(context.Background(), wg, migration_tools.EthLogsRepair, rangeChan)
 
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
+				defer close(rangeChan)
 				for _, blockRange := range blockRanges {
 					select {
 					case <-doneChan:
 						return
-					default:
-						rangeChan <- blockRange
+					case rangeChan <- blockRange:
 					}
 				}
-				close(quitChan)
 			}()
 			wg.Add(1)
 			go func() {
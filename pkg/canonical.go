@@ -0,0 +1,201 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+// canonicalLookahead is the number of blocks past the end of a range that are read to pick the canonical header
+// at the end of the range, by following the parent_hash links down from them
+const canonicalLookahead = 64
+
+// headerLinkModel is the db model for the parent links of v2 eth.header_cids
+type headerLinkModel struct {
+	BlockNumber    uint64 `db:"block_number"`
+	BlockHash      string `db:"block_hash"`
+	ParentHash     string `db:"parent_hash"`
+	TimesValidated int64  `db:"times_validated"`
+}
+
+// Canonicalizer picks the canonical header at each height of the v2 DB
+// the canonical headers are found by walking the parent_hash links down from the most validated header at the
+// highest height read; where the links are broken, by a gap or a missing parent, the most validated header is picked
+type Canonicalizer struct {
+	sync.Mutex
+	db     *sqlx.DB
+	report io.Writer
+	// reportLock serializes the writes to report, which need not be safe for concurrent use
+	reportLock sync.Mutex
+	// resolved are the merged ranges of heights whose canonical header has been picked
+	resolved [][2]uint64
+	// nonCanonical holds the hashes of the non-canonical headers of the resolved heights that have any
+	nonCanonical map[uint64][]string
+}
+
+// NewCanonicalizer returns a new Canonicalizer for the provided v2 DB
+// the height and hash of every non-canonical header found are written to report, if it is not nil
+func NewCanonicalizer(db *sqlx.DB, report io.Writer) *Canonicalizer {
+	return &Canonicalizer{
+		db:           db,
+		report:       report,
+		nonCanonical: make(map[uint64][]string),
+	}
+}
+
+// NonCanonical returns the hashes of the non-canonical headers within the block range
+// the canonical header is picked once per height, the first time a range including it is resolved, so that every table
+// leaves out the same headers and each non-canonical header is only reported once, no matter how the ranges of the
+// tables line up; only the heights with non-canonical headers are kept beyond the ranges resolved
+// NonCanonical is safe for concurrent use
+func (c *Canonicalizer) NonCanonical(rng [2]uint64) ([]string, error) {
+	c.Lock()
+	pending := SubtractRanges([][2]uint64{rng}, c.resolved)
+	if len(pending) == 0 {
+		hashes := c.cachedNonCanonical(rng)
+		c.Unlock()
+		return hashes, nil
+	}
+	c.Unlock()
+
+	var headers []headerLinkModel
+	if err := c.db.Select(&headers, string(sql.PgReadHeaderLinksStr), rng[0], rng[1]+canonicalLookahead); err != nil {
+		return nil, err
+	}
+	picked := pickNonCanonical(headers, rng)
+
+	// heights resolved by another call in the meantime keep the headers picked then
+	c.Lock()
+	pending = SubtractRanges([][2]uint64{rng}, c.resolved)
+	newlyNonCanonical := make([]headerLinkModel, 0, len(picked))
+	for _, header := range picked {
+		if withinRanges(pending, header.BlockNumber) {
+			c.nonCanonical[header.BlockNumber] = append(c.nonCanonical[header.BlockNumber], header.BlockHash)
+			newlyNonCanonical = append(newlyNonCanonical, header)
+		}
+	}
+	c.resolved = MergeRanges(append(c.resolved, rng))
+	hashes := c.cachedNonCanonical(rng)
+	c.Unlock()
+
+	if c.report != nil {
+		c.reportLock.Lock()
+		defer c.reportLock.Unlock()
+		for _, header := range newlyNonCanonical {
+			if _, err := fmt.Fprintf(c.report, "%d, %s\r\n", header.BlockNumber, header.BlockHash); err != nil {
+				return nil, fmt.Errorf("unable to report non-canonical header %s: %v", header.BlockHash, err)
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// cachedNonCanonical returns the hashes of the non-canonical headers within the resolved block range
+// it has to be called with the lock held
+func (c *Canonicalizer) cachedNonCanonical(rng [2]uint64) []string {
+	hashes := make([]string, 0)
+	// reorgs are rare, so the heights that have any are usually far fewer than the heights of the range
+	for height, heightHashes := range c.nonCanonical {
+		if rng[0] <= height && height <= rng[1] {
+			hashes = append(hashes, heightHashes...)
+		}
+	}
+	return hashes
+}
+
+// withinRanges returns whether the height is within any of the ranges
+func withinRanges(ranges [][2]uint64, height uint64) bool {
+	for _, rng := range ranges {
+		if rng[0] <= height && height <= rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// pickNonCanonical returns the non-canonical headers within the range
+// the headers have to be ordered by descending height, and by descending times validated within each height
+func pickNonCanonical(headers []headerLinkModel, rng [2]uint64) []headerLinkModel {
+	nonCanonical := make([]headerLinkModel, 0)
+	var parentHash string
+	var parentHeight uint64
+	for i := 0; i < len(headers); {
+		height := headers[i].BlockNumber
+		j := i
+		for j < len(headers) && headers[j].BlockNumber == height {
+			j++
+		}
+		canonical := i
+		if parentHash != "" && parentHeight == height {
+			for k := i; k < j; k++ {
+				if headers[k].BlockHash == parentHash {
+					canonical = k
+					break
+				}
+			}
+		}
+		for k := i; k < j; k++ {
+			if k != canonical && rng[0] <= height && height <= rng[1] {
+				nonCanonical = append(nonCanonical, headers[k])
+			}
+		}
+		parentHash = headers[canonical].ParentHash
+		parentHeight = height - 1
+		i = j
+	}
+	return nonCanonical
+}
+
+// newNonCanonicalReport creates a new file in the provided directory to report the non-canonical headers to
+func newNonCanonicalReport(dir string) (*os.File, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("unable to create non-canonical report dir %s: %v", dir, err)
+	}
+	reportPath := filepath.Join(dir, "non_canonical_"+strconv.Itoa(int(time.Now().Unix())))
+	return os.OpenFile(reportPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// readTableRange reads the v2 models of the table within the block range into models
+// if a Canonicalizer is provided, the rows belonging to non-canonical blocks are left out
+func readTableRange(reader *Reader, canonicalizer *Canonicalizer, tableName TableName, rng [2]uint64, models interface{}) error {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok {
+		return fmt.Errorf("unsupported table name: %s", tableName)
+	}
+	if canonicalizer == nil || !spec.CanonicalFiltered {
+		return reader.Read(rng, spec.ReadQuery, models)
+	}
+	excludingPgStr, err := spec.ReadQuery.ExcludingBlocks()
+	if err != nil {
+		return err
+	}
+	nonCanonical, err := canonicalizer.NonCanonical(rng)
+	if err != nil {
+		return fmt.Errorf("unable to pick the canonical headers: %v", err)
+	}
+	return reader.ReadExcluding(rng, excludingPgStr, nonCanonical, models)
+}
@@ -0,0 +1,147 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"bytes"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+var _ = Describe("sql.ReadPgStr", func() {
+	It("excludes blocks by hash from every statement that filters headers by block range", func() {
+		for _, pgStr := range []sql.ReadPgStr{
+			sql.PgReadEthHeadersStr,
+			sql.PgReadEthUnclesStr,
+			sql.PgReadEthTransactionsStr,
+			sql.PgReadAccessListElementsStr,
+			sql.PgReadEthReceiptsStr,
+			sql.PgReadEthLogsStr,
+			sql.PgReadEthStateStr,
+			sql.PgReadEthAccountsStr,
+			sql.PgReadEthStorageStr,
+		} {
+			excluding, err := pgStr.ExcludingBlocks()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(excluding)).To(ContainSubstring("block_number BETWEEN $1 AND $2 AND NOT (header_cids.block_hash = ANY($3))"))
+		}
+	})
+
+	It("rejects statements that do not filter headers by block range", func() {
+		_, err := sql.PgReadNodesStr.ExcludingBlocks()
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Canonicalizer", Serial, Label("test"), func() {
+	var v2DB *sqlx.DB
+
+	// writeHeader writes a v2 header with the provided hash and parent hash at the provided height
+	writeHeader := func(blockNumber uint64, blockHash, parentHash string, timesValidated int) {
+		_, err := v2DB.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			"mhKey"+blockHash, []byte{})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = v2DB.Exec(`INSERT INTO eth.header_cids (block_number, block_hash, parent_hash, cid, mh_key, td, node_id,
+			reward, state_root, tx_root, receipt_root, uncle_root, bloom, timestamp, times_validated)
+			SELECT $1, $2, $3, $4, $5, 1, nodes.id, 0, '', '', '', '', '', 0, $6 FROM public.nodes LIMIT 1`,
+			blockNumber, blockHash, parentHash, "cid"+blockHash, "mhKey"+blockHash, timesValidated)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		v2DB, err = sqlx.Connect("postgres", v2DBConfig.DbConnectionString())
+		Expect(err).ToNot(HaveOccurred())
+		prepV2SQLXDB(v2DB)
+		_, err = v2DB.Exec(`INSERT INTO public.nodes (client_name, genesis_block, network_id, node_id) VALUES ('', '', '', '')`)
+		Expect(err).ToNot(HaveOccurred())
+
+		// a two block reorg at heights 11 and 12, where the uncled branch has been validated more often
+		writeHeader(10, "0x10", "0x09", 1)
+		writeHeader(11, "0x11", "0x10", 1)
+		writeHeader(11, "0x11b", "0x10", 2)
+		writeHeader(12, "0x12", "0x11", 1)
+		writeHeader(12, "0x12b", "0x11b", 2)
+		writeHeader(13, "0x13", "0x12", 1)
+	})
+	AfterEach(func() {
+		tearDownV2SQLXDB(v2DB)
+		Expect(v2DB.Close()).To(Succeed())
+	})
+
+	It("picks the non-canonical headers by following the parent links down from above the range", func() {
+		report := new(bytes.Buffer)
+		canonicalizer := migration_tools.NewCanonicalizer(v2DB, report)
+		nonCanonical, err := canonicalizer.NonCanonical([2]uint64{10, 12})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nonCanonical).To(ConsistOf("0x11b", "0x12b"))
+		Expect(report.String()).To(Equal("12, 0x12b\r\n11, 0x11b\r\n"))
+
+		// the range is only resolved and reported once
+		nonCanonical, err = canonicalizer.NonCanonical([2]uint64{10, 12})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nonCanonical).To(ConsistOf("0x11b", "0x12b"))
+		Expect(report.String()).To(Equal("12, 0x12b\r\n11, 0x11b\r\n"))
+	})
+
+	It("picks and reports the headers at each height once, however the ranges of the tables line up", func() {
+		report := new(bytes.Buffer)
+		canonicalizer := migration_tools.NewCanonicalizer(v2DB, report)
+		nonCanonical, err := canonicalizer.NonCanonical([2]uint64{10, 11})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nonCanonical).To(ConsistOf("0x11b"))
+
+		nonCanonical, err = canonicalizer.NonCanonical([2]uint64{11, 13})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nonCanonical).To(ConsistOf("0x11b", "0x12b"))
+		nonCanonical, err = canonicalizer.NonCanonical([2]uint64{12, 12})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(nonCanonical).To(ConsistOf("0x12b"))
+		Expect(report.String()).To(Equal("11, 0x11b\r\n12, 0x12b\r\n"))
+	})
+
+	It("leaves the rows of the non-canonical headers out of reads", func() {
+		canonicalizer := migration_tools.NewCanonicalizer(v2DB, nil)
+		nonCanonical, err := canonicalizer.NonCanonical([2]uint64{10, 13})
+		Expect(err).ToNot(HaveOccurred())
+		excludingPgStr, err := sql.PgReadHeaderLinksStr.ExcludingBlocks()
+		Expect(err).ToNot(HaveOccurred())
+
+		var headers []struct {
+			BlockNumber    uint64 `db:"block_number"`
+			BlockHash      string `db:"block_hash"`
+			ParentHash     string `db:"parent_hash"`
+			TimesValidated int64  `db:"times_validated"`
+		}
+		Expect(migration_tools.NewReader(v2DB).ReadExcluding([2]uint64{10, 13}, excludingPgStr, nonCanonical, &headers)).To(Succeed())
+		hashes := make([]string, len(headers))
+		for i, header := range headers {
+			hashes[i] = header.BlockHash
+		}
+		Expect(hashes).To(Equal([]string{"0x13", "0x12", "0x11", "0x10"}))
+
+		// no excluded hashes reads every row
+		headers = nil
+		Expect(migration_tools.NewReader(v2DB).ReadExcluding([2]uint64{10, 13}, excludingPgStr, nil, &headers)).To(Succeed())
+		Expect(headers).To(HaveLen(6))
+	})
+})
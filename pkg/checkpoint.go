@@ -0,0 +1,95 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/migration-tools/pkg/util"
+)
+
+const (
+	pgCreateCheckpointSchemaStr = `CREATE SCHEMA IF NOT EXISTS migration_tools`
+	pgCreateCheckpointTableStr  = `CREATE TABLE IF NOT EXISTS migration_tools.progress (
+									table_name TEXT NOT NULL,
+									start_block BIGINT NOT NULL,
+									stop_block BIGINT NOT NULL,
+									committed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+									PRIMARY KEY (table_name, start_block, stop_block))`
+	pgWriteCheckpointStr = `INSERT INTO migration_tools.progress (table_name, start_block, stop_block)
+							VALUES ($1, $2, $3)
+							ON CONFLICT (table_name, start_block, stop_block) DO UPDATE SET committed_at = NOW()`
+	pgReadCheckpointsStr = `SELECT start_block, stop_block FROM migration_tools.progress
+							WHERE table_name = $1
+							ORDER BY start_block ASC`
+)
+
+// PublicBlocksCheckpoint is the name the transferred page ranges of public.blocks are checkpointed under, whether they
+// are transferred through a foreign table or directly from the old DB
+const PublicBlocksCheckpoint TableName = "public.blocks"
+
+// checkpointModel is the db model for migration_tools.progress
+type checkpointModel struct {
+	Start uint64 `db:"start_block"`
+	Stop  uint64 `db:"stop_block"`
+}
+
+// Checkpointer records the block ranges that have been committed to the new DB for each table
+// it persists these records in the migration_tools.progress table of the new DB
+type Checkpointer struct {
+	db *sqlx.DB
+}
+
+// NewCheckpointer returns a new Checkpointer, creating the migration_tools.progress table if it does not yet exist
+func NewCheckpointer(db *sqlx.DB) (*Checkpointer, error) {
+	if _, err := db.Exec(pgCreateCheckpointSchemaStr); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(pgCreateCheckpointTableStr); err != nil {
+		return nil, err
+	}
+	return &Checkpointer{db: db}, nil
+}
+
+// Commit records that the provided block range has been committed for the provided table
+// Commit is safe for concurrent use, as the only shared state is the concurrent safe *sqlx.DB
+func (c *Checkpointer) Commit(tableName TableName, blockRange [2]uint64) error {
+	_, err := c.db.Exec(pgWriteCheckpointStr, string(tableName), blockRange[0], blockRange[1])
+	return err
+}
+
+// Hook returns a util.TxHook that records the block range as committed for the table within the write transaction,
+// so the checkpoint is committed if and only if the rows of the range are
+func (c *Checkpointer) Hook(tableName TableName, blockRange [2]uint64) util.TxHook {
+	return func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(pgWriteCheckpointStr, string(tableName), blockRange[0], blockRange[1])
+		return err
+	}
+}
+
+// Committed returns the merged set of block ranges that have been committed for the provided table
+func (c *Checkpointer) Committed(tableName TableName) ([][2]uint64, error) {
+	var checkpoints []checkpointModel
+	if err := c.db.Select(&checkpoints, pgReadCheckpointsStr, string(tableName)); err != nil {
+		return nil, err
+	}
+	committed := make([][2]uint64, len(checkpoints))
+	for i, checkpoint := range checkpoints {
+		committed[i] = [2]uint64{checkpoint.Start, checkpoint.Stop}
+	}
+	return MergeRanges(committed), nil
+}
@@ -0,0 +1,85 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+var _ = Describe("Checkpointer", Serial, Label("test"), func() {
+	var checkpointer *migration_tools.Checkpointer
+	BeforeEach(func() {
+		sqlxDB, err = sqlx.Connect("postgres", v3DBConfig.DbConnectionString())
+		Expect(err).ToNot(HaveOccurred())
+		checkpointer, err = migration_tools.NewCheckpointer(sqlxDB)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = sqlxDB.Exec(`DELETE FROM migration_tools.progress`)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		_, err = sqlxDB.Exec(`DELETE FROM migration_tools.progress`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sqlxDB.Close()).To(Succeed())
+	})
+
+	It("returns the merged committed ranges for a table", Serial, func() {
+		Expect(checkpointer.Commit(migration_tools.EthHeaders, [2]uint64{0, 99})).To(Succeed())
+		Expect(checkpointer.Commit(migration_tools.EthHeaders, [2]uint64{100, 199})).To(Succeed())
+		Expect(checkpointer.Commit(migration_tools.EthHeaders, [2]uint64{300, 399})).To(Succeed())
+		Expect(checkpointer.Commit(migration_tools.EthHeaders, [2]uint64{300, 399})).To(Succeed())
+		Expect(checkpointer.Commit(migration_tools.EthStorage, [2]uint64{0, 999})).To(Succeed())
+
+		committed, err := checkpointer.Committed(migration_tools.EthHeaders)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(committed).To(Equal([][2]uint64{{0, 199}, {300, 399}}))
+
+		committed, err = checkpointer.Committed(migration_tools.EthUncles)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(committed).To(BeEmpty())
+	})
+
+	It("checkpoints a range within the transaction it is written in", Serial, func() {
+		models := []public_blocks.IPLDModel{{Key: "/blocks/checkpoint-hook-test", Data: []byte{1}}}
+		defer sqlxDB.Exec(`DELETE FROM public.blocks WHERE key = $1`, models[0].Key)
+		writer := sql.NewWriter(sqlxDB)
+
+		failingHook := func(tx *sqlx.Tx) error {
+			Expect(checkpointer.Hook(migration_tools.EthHeaders, [2]uint64{0, 99})(tx)).To(Succeed())
+			return errors.New("mock hook error")
+		}
+		Expect(writer.WriteWithHook(sql.PgWriteIPLDsStr, models, failingHook)).ToNot(Succeed())
+		committed, err := checkpointer.Committed(migration_tools.EthHeaders)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(committed).To(BeEmpty())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.blocks WHERE key = $1`, models[0].Key)).To(Succeed())
+		Expect(count).To(BeZero())
+
+		Expect(writer.WriteWithHook(sql.PgWriteIPLDsStr, models, checkpointer.Hook(migration_tools.EthHeaders, [2]uint64{0, 99}))).To(Succeed())
+		committed, err = checkpointer.Committed(migration_tools.EthHeaders)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(committed).To(Equal([][2]uint64{{0, 99}}))
+	})
+})
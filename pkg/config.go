@@ -17,8 +17,14 @@
 package migration_tools
 
 import (
+	"time"
+
 	"github.com/ethereum/go-ethereum/statediff/indexer/database/sql/postgres"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/sql"
 )
 
 // Config struct holds the configuration params for a Migrator
@@ -26,11 +32,73 @@ type Config struct {
 	ReadDB          postgres.Config
 	WriteDB         postgres.Config
 	WorkersPerTable int
+	Checkpoint      bool
+
+	// ReadOnly Migrators only connect to the old DB, for transforming its contents into v3 csv files
+	ReadOnly bool
+
+	// DryRun Migrators only connect to the old DB, reading and transforming its contents without writing them anywhere
+	DryRun bool
+
+	// ValidateIPLDs Migrators check that the IPLD referenced by every cid and mh_key of the transformed models is in
+	// public.blocks of the ValidateIPLDsSource DB and hashes to its cid, before writing the models
+	ValidateIPLDs       bool
+	ValidateIPLDsSource IPLDSource
+
+	// CanonicalOnly Migrators leave out the rows of non-canonical blocks, reporting their hashes to a file in NonCanonicalDir
+	CanonicalOnly   bool
+	NonCanonicalDir string
+
+	// ConflictPolicy is the default policy for handling primary key conflicts on write
+	// ConflictPolicies overrides it for specific tables
+	ConflictPolicy   sql.ConflictPolicy
+	ConflictPolicies map[TableName]sql.ConflictPolicy
+
+	// WriteMode is the default mode for writing v3 models, either multi-row INSERT or COPY FROM STDIN
+	// WriteModes overrides it for specific tables
+	WriteMode  sql.WriteMode
+	WriteModes map[TableName]sql.WriteMode
+
+	// TransferRetries is the number of times a failed public.blocks page segment is retried before it is reported as a gap
+	// TransferRetryBackoff is the wait before the first retry, doubling with every retry after it
+	TransferRetries      int
+	TransferRetryBackoff time.Duration
+
+	// TransferMode is how public.blocks is transferred, either through a postgres_fdw foreign table in the new DB,
+	// or by reading its pages from the old DB directly and writing them with WriteMode
+	TransferMode public_blocks.TransferMode
+}
+
+// TableConflictPolicy returns the conflict policy configured for the provided table
+func (c *Config) TableConflictPolicy(tableName TableName) sql.ConflictPolicy {
+	if policy, ok := c.ConflictPolicies[tableName]; ok {
+		return policy
+	}
+	return c.ConflictPolicy
+}
+
+// TableWriteMode returns the write mode configured for the provided table
+func (c *Config) TableWriteMode(tableName TableName) sql.WriteMode {
+	if mode, ok := c.WriteModes[tableName]; ok {
+		return mode
+	}
+	return c.WriteMode
 }
 
 // NewConfig returns a new Config
 func NewConfig() *Config {
 	viper.BindEnv(TOML_MIGRATION_WORKERS_PER_TABLE, MIGRATION_WORKERS_PER_TABLE)
+	viper.BindEnv(TOML_MIGRATION_CHECKPOINT, MIGRATION_CHECKPOINT)
+	viper.BindEnv(TOML_MIGRATION_CONFLICT_POLICY, MIGRATION_CONFLICT_POLICY)
+	viper.BindEnv(TOML_MIGRATION_WRITE_MODE, MIGRATION_WRITE_MODE)
+	viper.BindEnv(TOML_MIGRATION_CANONICAL_ONLY, MIGRATION_CANONICAL_ONLY)
+	viper.BindEnv(TOML_MIGRATION_DRY_RUN, MIGRATION_DRY_RUN)
+	viper.BindEnv(TOML_MIGRATION_VALIDATE_IPLDS, MIGRATION_VALIDATE_IPLDS)
+	viper.BindEnv(TOML_MIGRATION_VALIDATE_IPLDS_SOURCE, MIGRATION_VALIDATE_IPLDS_SOURCE)
+	viper.BindEnv(TOML_LOG_NON_CANONICAL_DIR, LOG_NON_CANONICAL_DIR)
+	viper.BindEnv(TOML_TRANSFER_RETRIES, TRANSFER_RETRIES)
+	viper.BindEnv(TOML_TRANSFER_RETRY_BACKOFF, TRANSFER_RETRY_BACKOFF)
+	viper.BindEnv(TOML_TRANSFER_MODE, TRANSFER_MODE)
 
 	viper.BindEnv(TOML_OLD_DATABASE_NAME, OLD_DATABASE_NAME)
 	viper.BindEnv(TOML_OLD_DATABASE_PASSWORD, OLD_DATABASE_PASSWORD)
@@ -52,8 +120,42 @@ func NewConfig() *Config {
 	viper.BindEnv(TOML_NEW_DATABASE_MAX_CONN_LIFETIME, NEW_DATABASE_MAX_CONN_LIFETIME)
 	viper.BindEnv(TOML_NEW_DATABASE_MAX_IDLE_CONNECTIONS, NEW_DATABASE_MAX_IDLE_CONNECTIONS)
 
+	conflictPolicies := make(map[TableName]sql.ConflictPolicy)
+	for tableNameStr, policy := range viper.GetStringMapString(TOML_MIGRATION_CONFLICT_POLICIES) {
+		tableName, err := NewTableNameFromString(tableNameStr)
+		if err != nil {
+			logrus.Warnf("ignoring conflict policy for unrecognized table: %v", err)
+			continue
+		}
+		conflictPolicies[tableName] = sql.ConflictPolicy(policy)
+	}
+	writeModes := make(map[TableName]sql.WriteMode)
+	for tableNameStr, mode := range viper.GetStringMapString(TOML_MIGRATION_WRITE_MODES) {
+		tableName, err := NewTableNameFromString(tableNameStr)
+		if err != nil {
+			logrus.Warnf("ignoring write mode for unrecognized table: %v", err)
+			continue
+		}
+		writeModes[tableName] = sql.WriteMode(mode)
+	}
+
 	return &Config{
-		WorkersPerTable: viper.GetInt(TOML_MIGRATION_WORKERS_PER_TABLE),
+		WorkersPerTable:  viper.GetInt(TOML_MIGRATION_WORKERS_PER_TABLE),
+		Checkpoint:       viper.GetBool(TOML_MIGRATION_CHECKPOINT),
+		ConflictPolicy:   sql.ConflictPolicy(viper.GetString(TOML_MIGRATION_CONFLICT_POLICY)),
+		ConflictPolicies: conflictPolicies,
+		WriteMode:        sql.WriteMode(viper.GetString(TOML_MIGRATION_WRITE_MODE)),
+		WriteModes:       writeModes,
+		CanonicalOnly:    viper.GetBool(TOML_MIGRATION_CANONICAL_ONLY),
+		DryRun:           viper.GetBool(TOML_MIGRATION_DRY_RUN),
+		ValidateIPLDs:    viper.GetBool(TOML_MIGRATION_VALIDATE_IPLDS),
+		NonCanonicalDir:  viper.GetString(TOML_LOG_NON_CANONICAL_DIR),
+
+		ValidateIPLDsSource: IPLDSource(viper.GetString(TOML_MIGRATION_VALIDATE_IPLDS_SOURCE)),
+
+		TransferRetries:      viper.GetInt(TOML_TRANSFER_RETRIES),
+		TransferRetryBackoff: viper.GetDuration(TOML_TRANSFER_RETRY_BACKOFF),
+		TransferMode:         public_blocks.TransferMode(viper.GetString(TOML_TRANSFER_MODE)),
 		ReadDB: postgres.Config{
 			Username:        viper.GetString(TOML_OLD_DATABASE_USER),
 			Password:        viper.GetString(TOML_OLD_DATABASE_PASSWORD),
@@ -0,0 +1,92 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package csv
+
+import (
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Null is the representation of a NULL value in the csv files
+// the files load with: COPY {table} ({columns}) FROM '{file}' WITH (FORMAT csv, HEADER, NULL '\N')
+const Null = `\N`
+
+// WriteHeader writes the column header for the provided statement to dst
+func WriteHeader(dst io.Writer, pgStr WriteCSVStr) error {
+	return WriteRecords(dst, pgStr, [][]string{pgStr.Columns()})
+}
+
+// WriteRecords writes the records to dst as RFC 4180 csv, checking that each one holds a field for every column of the statement
+func WriteRecords(dst io.Writer, pgStr WriteCSVStr, records [][]string) error {
+	numColumns := len(pgStr.Columns())
+	w := csv.NewWriter(dst)
+	for _, record := range records {
+		if len(record) != numColumns {
+			return fmt.Errorf("expected a record with %d fields for columns (%s), got %d", numColumns, pgStr, len(record))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// Bytea encodes the bytes in the PostgreSQL bytea hex format, nil bytes are encoded as NULL
+func Bytea(b []byte) string {
+	if b == nil {
+		return Null
+	}
+	return `\x` + hex.EncodeToString(b)
+}
+
+// Int encodes a signed integer
+func Int(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+// Uint encodes an unsigned integer
+func Uint(u uint64) string {
+	return strconv.FormatUint(u, 10)
+}
+
+// Bool encodes a boolean
+func Bool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+// Valuer encodes a driver.Valuer, such as a pq.StringArray, using its PostgreSQL text representation
+func Valuer(v driver.Valuer) (string, error) {
+	value, err := v.Value()
+	if err != nil {
+		return "", err
+	}
+	switch value := value.(type) {
+	case nil:
+		return Null, nil
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
@@ -0,0 +1,126 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package csv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WriterConstructor func sig for constructing a Writer for a specific table on top of the provided destination
+type WriterConstructor func(dst io.WriteCloser) Writer
+
+// RotatingWriter writes the models for a single table to a series of csv files in a directory, each starting with the column header
+// a new file is started once the current one reaches the max size in bytes or the max number of writes (block ranges)
+// RotatingWriter is safe for concurrent use, so that it can be shared by all the workers of a table
+type RotatingWriter struct {
+	sync.Mutex
+	dir, name   string
+	constructor WriterConstructor
+	maxBytes    uint64
+	maxWrites   uint64
+
+	file   *os.File
+	seq    int
+	bytes  uint64
+	writes uint64
+}
+
+// NewRotatingWriter returns a new RotatingWriter writing files named "{name}_{unix_timestamp}_{seq}.csv" into dir
+// a max of 0 disables rotation on that measure
+func NewRotatingWriter(dir, name string, constructor WriterConstructor, maxBytes, maxWrites uint64) (*RotatingWriter, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &RotatingWriter{
+		dir:         dir,
+		name:        name,
+		constructor: constructor,
+		maxBytes:    maxBytes,
+		maxWrites:   maxWrites,
+	}, nil
+}
+
+// Write satisfies Writer
+// the models are formatted into memory first, so that a failed write leaves no partial range in the output file
+func (rw *RotatingWriter) Write(pgStr WriteCSVStr, models interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := rw.constructor(nopCloser{buf}).Write(pgStr, models); err != nil {
+		return err
+	}
+	rw.Lock()
+	defer rw.Unlock()
+	if rw.file == nil || rw.full() {
+		if err := rw.rotate(pgStr); err != nil {
+			return err
+		}
+	}
+	n, err := rw.file.Write(buf.Bytes())
+	rw.bytes += uint64(n)
+	rw.writes++
+	return err
+}
+
+func (rw *RotatingWriter) full() bool {
+	return (rw.maxBytes > 0 && rw.bytes >= rw.maxBytes) || (rw.maxWrites > 0 && rw.writes >= rw.maxWrites)
+}
+
+func (rw *RotatingWriter) rotate(pgStr WriteCSVStr) error {
+	if rw.file != nil {
+		if err := rw.file.Close(); err != nil {
+			return err
+		}
+	}
+	rw.seq++
+	path := filepath.Join(rw.dir, fmt.Sprintf("%s_%d_%d.csv", rw.name, time.Now().Unix(), rw.seq))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		rw.file = nil
+		return err
+	}
+	rw.file, rw.bytes, rw.writes = file, 0, 0
+	header := new(bytes.Buffer)
+	if err := WriteHeader(header, pgStr); err != nil {
+		return err
+	}
+	n, err := rw.file.Write(header.Bytes())
+	rw.bytes += uint64(n)
+	return err
+}
+
+// Close satisfies io.Closer
+func (rw *RotatingWriter) Close() error {
+	rw.Lock()
+	defer rw.Unlock()
+	if rw.file == nil {
+		return nil
+	}
+	err := rw.file.Close()
+	rw.file = nil
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -16,29 +16,38 @@
 
 package csv
 
+import "strings"
+
 // WriteCSVStr provides explicit typing for write csv statements
+// a write csv statement is the comma separated column header of the file, the table writers emit their fields in this order
 type WriteCSVStr string
 
+// Columns returns the columns of the csv statement, in order
+func (s WriteCSVStr) Columns() []string {
+	return strings.Split(string(s), ",")
+}
+
 const (
-	CSVWriteIPLDsStr WriteCSVStr = "%s, \\x%x\n"
+	CSVWriteIPLDsStr WriteCSVStr = "key,data"
 
-	CSVWriteEthUnclesStr WriteCSVStr = "%s, %s, %s, %s, %s, %s\n"
+	CSVWriteEthUnclesStr WriteCSVStr = "header_id,block_hash,parent_hash,cid,mh_key,reward"
 
-	CSVWriteEthTransactionsStr WriteCSVStr = "%s, %s, %s, %s, %s, %d, %s, \\x%x, %d, %s\n"
+	CSVWriteEthTransactionsStr WriteCSVStr = "header_id,index,tx_hash,cid,mh_key,dst,src,tx_data,tx_type,value"
 
-	CSVWriteEthStorageStr WriteCSVStr = "%s, \\x%x, %s, %s, \\x%x, %d, %t, %s\n"
+	CSVWriteEthStorageStr WriteCSVStr = "header_id,state_path,storage_path,storage_leaf_key,node_type,cid,mh_key,diff"
 
-	CSVWriteEthStateStr WriteCSVStr = "%s, %s, %s, \\x%x, %d, %t, %s\n"
+	CSVWriteEthStateStr WriteCSVStr = "header_id,state_path,state_leaf_key,node_type,cid,mh_key,diff"
 
-	CSVWriteNodesStr WriteCSVStr = "%s, %s, %s, %s, %d\n"
+	CSVWriteNodesStr WriteCSVStr = "client_name,genesis_block,network_id,node_id,chain_id"
 
-	CSVWriteEthReceiptsStr WriteCSVStr = "%s, %s, %s, %s, %s, %s, %d, %s\n"
+	CSVWriteEthReceiptsStr WriteCSVStr = "tx_id,leaf_cid,leaf_mh_key,post_status,post_state,contract,contract_hash,log_root"
 
-	CSVWriteEthLogsStr WriteCSVStr = "%s, %s, %s, %s, %d, %s, %s, %s, %s, \\x%x\n"
+	CSVWriteEthLogsStr WriteCSVStr = "rct_id,leaf_cid,leaf_mh_key,address,index,log_data,topic0,topic1,topic2,topic3"
 
-	CSVWriteEthHeadersStr WriteCSVStr = "%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, \\x%x, %d, %s, %d, %s\n"
+	CSVWriteEthHeadersStr WriteCSVStr = "block_number,block_hash,parent_hash,cid,mh_key,td,node_id,reward,state_root," +
+		"uncle_root,tx_root,receipt_root,bloom,timestamp,times_validated,coinbase"
 
-	CSVWriteEthAccountsStr WriteCSVStr = "%s, \\x%x, %s, %d, \\x%x, '%s\n"
+	CSVWriteEthAccountsStr WriteCSVStr = "header_id,state_path,balance,nonce,code_hash,storage_root"
 
-	CSVWriteAccessListElementsStr WriteCSVStr = "%s, %d, %s, %s\n"
+	CSVWriteAccessListElementsStr WriteCSVStr = "tx_id,index,address,storage_keys"
 )
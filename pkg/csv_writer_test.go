@@ -0,0 +1,136 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/csv"
+	"github.com/vulcanize/migration-tools/pkg/eth_accounts"
+	"github.com/vulcanize/migration-tools/pkg/public_nodes"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+var _ = Describe("csv encoding", Serial, func() {
+	It("uses the columns of the v3 write statements as headers", Serial, func() {
+		for csvStr, pgStr := range map[csv.WriteCSVStr]sql.WritePgStr{
+			csv.CSVWriteNodesStr:              sql.PgWriteNodesStr,
+			csv.CSVWriteEthHeadersStr:         sql.PgWriteEthHeadersStr,
+			csv.CSVWriteEthUnclesStr:          sql.PgWriteEthUnclesStr,
+			csv.CSVWriteEthTransactionsStr:    sql.PgWriteEthTransactionsStr,
+			csv.CSVWriteAccessListElementsStr: sql.PgWriteAccessListElementsStr,
+			csv.CSVWriteEthReceiptsStr:        sql.PgWriteEthReceiptsStr,
+			csv.CSVWriteEthLogsStr:            sql.PgWriteEthLogsStr,
+			csv.CSVWriteEthStateStr:           sql.PgWriteEthStateStr,
+			csv.CSVWriteEthAccountsStr:        sql.PgWriteEthAccountsStr,
+			csv.CSVWriteEthStorageStr:         sql.PgWriteEthStorageStr,
+			csv.CSVWriteIPLDsStr:              sql.PgWriteIPLDsStr,
+		} {
+			Expect(csvStr.Columns()).To(Equal(pgStr.Columns()))
+		}
+	})
+
+	It("quotes fields, hex encodes bytea, and writes nil bytes as NULL", Serial, func() {
+		buf := new(strings.Builder)
+		writer := eth_accounts.NewWriter(nopWriteCloser{buf})
+		Expect(writer.Write(csv.CSVWriteEthAccountsStr, []eth_accounts.AccountModelV3{
+			{HeaderID: "0xabc", StatePath: []byte{0x0a, 0xff}, Balance: "1,000", Nonce: 2, CodeHash: nil, StorageRoot: `say "hi"`},
+		})).To(Succeed())
+		Expect(buf.String()).To(Equal("0xabc,\\x0aff,\"1,000\",2,\\N,\"say \"\"hi\"\"\"\n"))
+	})
+})
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+var _ = Describe("csv.RotatingWriter", Serial, func() {
+	var (
+		outputDir string
+		models    = []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+		}
+	)
+	BeforeEach(func() {
+		outputDir, err = os.MkdirTemp("", "csv")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(outputDir)).To(Succeed())
+	})
+
+	outputFiles := func() []string {
+		files, err := filepath.Glob(filepath.Join(outputDir, string(migration_tools.PublicNodes)+"_*.csv"))
+		Expect(err).ToNot(HaveOccurred())
+		return files
+	}
+
+	It("writes every range to a single file when rotation is disabled", Serial, func() {
+		writer, err := migration_tools.NewTableCSVWriter(migration_tools.PublicNodes, outputDir, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 3; i++ {
+			Expect(writer.Write(csv.CSVWriteNodesStr, models)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+		files := outputFiles()
+		Expect(files).To(HaveLen(1))
+		data, err := os.ReadFile(files[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal(
+			"client_name,genesis_block,network_id,node_id,chain_id\n" +
+				"mockName,mockGenesisBlock,1,mockNodeID1,1\n" +
+				"mockName,mockGenesisBlock,1,mockNodeID1,1\n" +
+				"mockName,mockGenesisBlock,1,mockNodeID1,1\n"))
+	})
+
+	It("rotates files by the number of ranges written", Serial, func() {
+		writer, err := migration_tools.NewTableCSVWriter(migration_tools.PublicNodes, outputDir, 0, 2)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 5; i++ {
+			Expect(writer.Write(csv.CSVWriteNodesStr, models)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+		Expect(outputFiles()).To(HaveLen(3))
+	})
+
+	It("rotates files by size", Serial, func() {
+		writer, err := migration_tools.NewTableCSVWriter(migration_tools.PublicNodes, outputDir, 1, 0)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 4; i++ {
+			Expect(writer.Write(csv.CSVWriteNodesStr, models)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+		Expect(outputFiles()).To(HaveLen(4))
+	})
+
+	It("writes nothing for a range that fails", Serial, func() {
+		writer, err := migration_tools.NewTableCSVWriter(migration_tools.PublicNodes, outputDir, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Write(csv.CSVWriteNodesStr, []string{"not a node model"})).ToNot(Succeed())
+		Expect(writer.Close()).To(Succeed())
+		Expect(outputFiles()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,155 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/vulcanize/migration-tools/pkg/metrics"
+)
+
+// mhKeyColumns are the pairs of cid and mh_key columns of the v3 models, whose mh_key has to be derived from the cid
+var mhKeyColumns = [][2]string{
+	{"cid", "mh_key"},
+	{"leaf_cid", "leaf_mh_key"},
+}
+
+// DryRunStats holds what a dry run found for a table
+type DryRunStats struct {
+	TableName       TableName
+	Ranges          int
+	ReadErrors      int
+	TransformErrors int
+	ValidateErrors  int
+	RowsRead        int
+	RowsTransformed int
+	Gaps            int
+	GapBlocks       uint64
+	Duplicates      int
+	MhKeyMismatches int
+}
+
+// DryRunReport collects the DryRunStats of every table processed by a dry run Migrator
+// DryRunReport is safe for concurrent use, and its methods are no-ops on a nil DryRunReport
+type DryRunReport struct {
+	sync.Mutex
+	tables map[TableName]*DryRunStats
+}
+
+// NewDryRunReport returns a new, empty, DryRunReport
+func NewDryRunReport() *DryRunReport {
+	return &DryRunReport{tables: make(map[TableName]*DryRunStats)}
+}
+
+// table returns the stats of the table, the lock has to be held
+func (r *DryRunReport) table(tableName TableName) *DryRunStats {
+	stats, ok := r.tables[tableName]
+	if !ok {
+		stats = &DryRunStats{TableName: tableName}
+		r.tables[tableName] = stats
+	}
+	return stats
+}
+
+// failed records a range of the table that failed at the provided stage
+func (r *DryRunReport) failed(tableName TableName, stage string, rowsRead int) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	stats := r.table(tableName)
+	stats.RowsRead += rowsRead
+	switch stage {
+	case metrics.StageRead:
+		stats.ReadErrors++
+	case metrics.StageTransform:
+		stats.TransformErrors++
+	case metrics.StageValidate:
+		stats.ValidateErrors++
+	}
+}
+
+// completed records a range of the table that was read and transformed, along with the gaps and the number of heights
+// with rows for more than one block hash found within it
+func (r *DryRunReport) completed(tableName TableName, rowsRead, rowsTransformed int, gaps [][2]uint64, duplicates, mhKeyMismatches int) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+	stats := r.table(tableName)
+	stats.Ranges++
+	stats.RowsRead += rowsRead
+	stats.RowsTransformed += rowsTransformed
+	stats.Duplicates += duplicates
+	stats.MhKeyMismatches += mhKeyMismatches
+	for _, gap := range gaps {
+		stats.Gaps++
+		stats.GapBlocks += gap[1] - gap[0] + 1
+	}
+}
+
+// Stats returns the stats of every table, ordered by table name
+func (r *DryRunReport) Stats() []DryRunStats {
+	if r == nil {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	stats := make([]DryRunStats, 0, len(r.tables))
+	for _, tableStats := range r.tables {
+		stats = append(stats, *tableStats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].TableName < stats[j].TableName })
+	return stats
+}
+
+// WriteSummary writes the stats of every table out to w as a table
+func (r *DryRunReport) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "table\tranges\tread errors\ttransform errors\tvalidate errors\trows read\trows transformed\tgaps\tgap blocks\tduplicate heights\tmh_key mismatches\t")
+	for _, stats := range r.Stats() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n", stats.TableName, stats.Ranges, stats.ReadErrors,
+			stats.TransformErrors, stats.ValidateErrors, stats.RowsRead, stats.RowsTransformed, stats.Gaps, stats.GapBlocks,
+			stats.Duplicates, stats.MhKeyMismatches)
+	}
+	return tw.Flush()
+}
+
+// countMhKeyMismatches counts the v3 models in the provided slice whose mh_key is not the one derived from their cid
+func countMhKeyMismatches(mapper *reflectx.Mapper, models interface{}) (int, error) {
+	refs, err := ipldReferences(mapper, models)
+	if err != nil {
+		return 0, err
+	}
+	mismatches := 0
+	for _, ref := range refs {
+		c, err := cid.Decode(ref.CID)
+		if err != nil || mhKeyFromCID(c) != ref.MhKey {
+			mismatches++
+		}
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,43 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+var _ = Describe("DryRunReport", func() {
+	It("has no stats when the Migrator is not a dry run", func() {
+		var report *migration_tools.DryRunReport
+		Expect(report.Stats()).To(BeNil())
+	})
+
+	It("writes a summary with a header row", func() {
+		summary := new(bytes.Buffer)
+		Expect(migration_tools.NewDryRunReport().WriteSummary(summary)).To(Succeed())
+		lines := strings.Split(strings.TrimSuffix(summary.String(), "\n"), "\n")
+		Expect(lines).To(HaveLen(1))
+		Expect(lines[0]).To(ContainSubstring("duplicate heights"))
+		Expect(lines[0]).To(ContainSubstring("mh_key mismatches"))
+	})
+})
@@ -23,6 +23,9 @@ const (
 	LOG_READ_GAPS_DIR     = "LOG_READ_GAPS_DIR"
 	LOG_WRITE_GAPS_DIR    = "LOG_WRITE_GAPS_DIR"
 	LOG_TRANSFER_GAPS_DIR = "LOG_TRANSFER_GAPS_DIR"
+	LOG_MISMATCH_DIR      = "LOG_MISMATCH_DIR"
+	LOG_NON_CANONICAL_DIR = "LOG_NON_CANONICAL_DIR"
+	LOG_REPORT_DIR        = "LOG_REPORT_DIR"
 
 	MIGRATION_START                   = "MIGRATION_START"
 	MIGRATION_STOP                    = "MIGRATION_STOP"
@@ -30,11 +33,33 @@ const (
 	MIGRATION_WORKERS_PER_TABLE       = "MIGRATION_WORKERS_PER_TABLE"
 	MIGRATION_AUTO_RANGE              = "MIGRATION_AUTO_RANGE"
 	MIGRATION_AUTO_RANGE_SEGMENT_SIZE = "MIGRATION_AUTO_RANGE_SEGMENT_SIZE"
+	MIGRATION_CHECKPOINT              = "MIGRATION_CHECKPOINT"
+	MIGRATION_RESUME                  = "MIGRATION_RESUME"
+	MIGRATION_CONFLICT_POLICY         = "MIGRATION_CONFLICT_POLICY"
+	MIGRATION_WRITE_MODE              = "MIGRATION_WRITE_MODE"
+	MIGRATION_DEPENDENCY_ORDER        = "MIGRATION_DEPENDENCY_ORDER"
+	MIGRATION_CANONICAL_ONLY          = "MIGRATION_CANONICAL_ONLY"
+	MIGRATION_ADAPTIVE_RANGE          = "MIGRATION_ADAPTIVE_RANGE"
+	MIGRATION_TARGET_ROWS             = "MIGRATION_TARGET_ROWS"
+	MIGRATION_TARGET_LATENCY          = "MIGRATION_TARGET_LATENCY"
+	MIGRATION_DRY_RUN                 = "MIGRATION_DRY_RUN"
+	MIGRATION_VALIDATE_IPLDS          = "MIGRATION_VALIDATE_IPLDS"
+	MIGRATION_VALIDATE_IPLDS_SOURCE   = "MIGRATION_VALIDATE_IPLDS_SOURCE"
+
+	METRICS_ENABLED = "METRICS_ENABLED"
+	METRICS_ADDRESS = "METRICS_ADDRESS"
+
+	CSV_OUTPUT_DIR          = "CSV_OUTPUT_DIR"
+	CSV_MAX_FILE_SIZE       = "CSV_MAX_FILE_SIZE"
+	CSV_MAX_RANGES_PER_FILE = "CSV_MAX_RANGES_PER_FILE"
 
 	TRANSFER_TABLE_NAME     = "TRANSFER_TABLE_NAME"
 	TRANSFER_SEGMENT_SIZE   = "TRANSFER_SEGMENT_SIZE"
 	TRANSFER_SEGMENT_OFFSET = "TRANSFER_SEGMENT_OFFSET"
 	TRANSFER_MAX_PAGE       = "TRANSFER_MAX_PAGE"
+	TRANSFER_RETRIES        = "TRANSFER_RETRIES"
+	TRANSFER_RETRY_BACKOFF  = "TRANSFER_RETRY_BACKOFF"
+	TRANSFER_MODE           = "TRANSFER_MODE"
 
 	OLD_DATABASE_NAME                 = "OLD_DATABASE_NAME"
 	OLD_DATABASE_HOSTNAME             = "OLD_DATABASE_HOSTNAME"
@@ -62,6 +87,9 @@ const (
 	TOML_LOG_READ_GAPS_DIR     = "log.readGapsDir"
 	TOML_LOG_WRITE_GAPS_DIR    = "log.writeGapsDir"
 	TOML_LOG_TRANSFER_GAPS_DIR = "log.transferGapDir"
+	TOML_LOG_MISMATCH_DIR      = "log.mismatchDir"
+	TOML_LOG_NON_CANONICAL_DIR = "log.nonCanonicalDir"
+	TOML_LOG_REPORT_DIR        = "log.reportDir"
 
 	TOML_MIGRATION_RANGES                  = "migrator.ranges"
 	TOML_MIGRATION_START                   = "migrator.start"
@@ -70,11 +98,35 @@ const (
 	TOML_MIGRATION_WORKERS_PER_TABLE       = "migrator.workersPerTable"
 	TOML_MIGRATION_AUTO_RANGE              = "migrator.autoRange"
 	TOML_MIGRATION_AUTO_RANGE_SEGMENT_SIZE = "migrator.segmentSize"
+	TOML_MIGRATION_CHECKPOINT              = "migrator.checkpoint"
+	TOML_MIGRATION_RESUME                  = "migrator.resume"
+	TOML_MIGRATION_CONFLICT_POLICY         = "migrator.conflictPolicy"
+	TOML_MIGRATION_CONFLICT_POLICIES       = "migrator.conflictPolicies"
+	TOML_MIGRATION_WRITE_MODE              = "migrator.writeMode"
+	TOML_MIGRATION_WRITE_MODES             = "migrator.writeModes"
+	TOML_MIGRATION_DEPENDENCY_ORDER        = "migrator.dependencyOrder"
+	TOML_MIGRATION_CANONICAL_ONLY          = "migrator.canonicalOnly"
+	TOML_MIGRATION_ADAPTIVE_RANGE          = "migrator.adaptiveRange"
+	TOML_MIGRATION_TARGET_ROWS             = "migrator.targetRows"
+	TOML_MIGRATION_TARGET_LATENCY          = "migrator.targetLatency"
+	TOML_MIGRATION_DRY_RUN                 = "migrator.dryRun"
+	TOML_MIGRATION_VALIDATE_IPLDS          = "migrator.validateIPLDs"
+	TOML_MIGRATION_VALIDATE_IPLDS_SOURCE   = "migrator.validateIPLDsSource"
+
+	TOML_METRICS_ENABLED = "metrics.enabled"
+	TOML_METRICS_ADDRESS = "metrics.address"
+
+	TOML_CSV_OUTPUT_DIR          = "csv.outputDir"
+	TOML_CSV_MAX_FILE_SIZE       = "csv.maxFileSize"
+	TOML_CSV_MAX_RANGES_PER_FILE = "csv.maxRangesPerFile"
 
 	TOML_TRANSFER_TABLE_NAME     = "migrator.transferTableName"
 	TOML_TRANSFER_SEGMENT_SIZE   = "migrator.pagesPerTx"
 	TOML_TRANSFER_SEGMENT_OFFSET = "migrator.segmentOffset"
 	TOML_TRANSFER_MAX_PAGE       = "migrator.maxPage"
+	TOML_TRANSFER_RETRIES        = "migrator.transferRetries"
+	TOML_TRANSFER_RETRY_BACKOFF  = "migrator.transferRetryBackoff"
+	TOML_TRANSFER_MODE           = "migrator.transferMode"
 
 	TOML_OLD_DATABASE_NAME                 = "old.databaseName"
 	TOML_OLD_DATABASE_HOSTNAME             = "old.databaseHostName"
@@ -102,6 +154,9 @@ const (
 	CLI_LOG_READ_GAPS_DIR     = "read-gaps-dir"
 	CLI_LOG_WRITE_GAPS_DIR    = "write-gaps-dir"
 	CLI_LOG_TRANSFER_GAPS_DIR = "transfer-gap-dir"
+	CLI_LOG_MISMATCH_DIR      = "mismatch-dir"
+	CLI_LOG_NON_CANONICAL_DIR = "non-canonical-dir"
+	CLI_LOG_REPORT_DIR        = "report-dir"
 
 	CLI_MIGRATION_START                   = "start-height"
 	CLI_MIGRATION_STOP                    = "stop-height"
@@ -109,11 +164,33 @@ const (
 	CLI_MIGRATION_WORKERS_PER_TABLE       = "workers-per-table"
 	CLI_MIGRATION_AUTO_RANGE              = "auto-range"
 	CLI_MIGRATION_AUTO_RANGE_SEGMENT_SIZE = "migration-segment-size"
+	CLI_MIGRATION_CHECKPOINT              = "checkpoint"
+	CLI_MIGRATION_RESUME                  = "resume"
+	CLI_MIGRATION_CONFLICT_POLICY         = "conflict-policy"
+	CLI_MIGRATION_WRITE_MODE              = "write-mode"
+	CLI_MIGRATION_DEPENDENCY_ORDER        = "dependency-order"
+	CLI_MIGRATION_CANONICAL_ONLY          = "canonical-only"
+	CLI_MIGRATION_ADAPTIVE_RANGE          = "adaptive-range"
+	CLI_MIGRATION_TARGET_ROWS             = "target-rows"
+	CLI_MIGRATION_TARGET_LATENCY          = "target-latency"
+	CLI_MIGRATION_DRY_RUN                 = "dry-run"
+	CLI_MIGRATION_VALIDATE_IPLDS          = "validate-iplds"
+	CLI_MIGRATION_VALIDATE_IPLDS_SOURCE   = "validate-iplds-source"
+
+	CLI_METRICS_ENABLED = "metrics"
+	CLI_METRICS_ADDRESS = "metrics-address"
+
+	CLI_CSV_OUTPUT_DIR          = "csv-output-dir"
+	CLI_CSV_MAX_FILE_SIZE       = "csv-max-file-size"
+	CLI_CSV_MAX_RANGES_PER_FILE = "csv-max-ranges-per-file"
 
 	CLI_TRANSFER_TABLE_NAME     = "transfer-table-name"
 	CLI_TRANSFER_SEGMENT_SIZE   = "transfer-segment-size"
 	CLI_TRANSFER_SEGMENT_OFFSET = "transfer-segment-offset"
 	CLI_TRANSFER_MAX_PAGE       = "transfer-max-page"
+	CLI_TRANSFER_RETRIES        = "transfer-retries"
+	CLI_TRANSFER_RETRY_BACKOFF  = "transfer-retry-backoff"
+	CLI_TRANSFER_MODE           = "transfer-mode"
 
 	CLI_OLD_DATABASE_NAME                 = "old-db-name"
 	CLI_OLD_DATABASE_HOSTNAME             = "old-db-hostname"
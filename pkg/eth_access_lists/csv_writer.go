@@ -23,13 +23,17 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]AccessListElementModelV3), models)
 	}
-	for _, alModel := range alModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), alModel.TxID, alModel.Index, alModel.Address,
-			alModel.StorageKeys); err != nil {
+	records := make([][]string, len(alModels))
+	for i, alModel := range alModels {
+		storageKeys, err := csv.Valuer(alModel.StorageKeys)
+		if err != nil {
 			return err
 		}
+		records[i] = []string{
+			alModel.TxID, csv.Int(alModel.Index), alModel.Address, storageKeys,
+		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
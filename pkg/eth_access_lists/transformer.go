@@ -17,9 +17,8 @@
 package eth_access_lists
 
 import (
-	"fmt"
-
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
 )
 
 // Transformer struct for transforming v2 DB eth.access_list_elements models into v3 DB models
@@ -28,17 +27,18 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for eth.access_list_elements
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for eth.access_list_elements
+func NewTypedTransformer() typed.Transformer[AccessListElementModelV2WithMeta, AccessListElementModelV3] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.access_list_elements
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v2Models, ok := models.(*[]AccessListElementModelV2WithMeta)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]AccessListElementModelV2WithMeta), models)
-	}
-	v3Models := make([]AccessListElementModelV3, len(*v2Models))
-	for i, model := range *v2Models {
+// Transform satisfies typed.Transformer for eth.access_list_elements
+func (t *Transformer) Transform(v2Models []AccessListElementModelV2WithMeta, expectedRange [2]uint64) ([]AccessListElementModelV3, [][2]uint64, error) {
+	v3Models := make([]AccessListElementModelV3, len(v2Models))
+	for i, model := range v2Models {
 		v3Models[i] = AccessListElementModelV3{
 			Index:       model.Index,
 			TxID:        model.TxHash,
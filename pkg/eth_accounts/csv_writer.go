@@ -23,13 +23,14 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]AccountModelV3), models)
 	}
-	for _, aModel := range aModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), aModel.HeaderID, aModel.StatePath, aModel.Balance, aModel.Nonce,
-			aModel.CodeHash, aModel.StorageRoot); err != nil {
-			return err
+	records := make([][]string, len(aModels))
+	for i, aModel := range aModels {
+		records[i] = []string{
+			aModel.HeaderID, csv.Bytea(aModel.StatePath), aModel.Balance, csv.Uint(aModel.Nonce),
+			csv.Bytea(aModel.CodeHash), aModel.StorageRoot,
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
@@ -21,6 +21,8 @@ import (
 	"strconv"
 
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
+	"github.com/vulcanize/migration-tools/pkg/util"
 )
 
 // Transformer struct for transforming v2 DB eth.state_accounts models into v3 DB models
@@ -29,30 +31,31 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for eth.state_accounts
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for eth.state_accounts
+func NewTypedTransformer() typed.Transformer[AccountModelV2WithMeta, AccountModelV3] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.state_accounts
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v2Models, ok := models.(*[]AccountModelV2WithMeta)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]AccountModelV2WithMeta), models)
-	}
-	v3Models := make([]AccountModelV3, len(*v2Models))
-	expectedHeight := expectedRange[0]
-	missingHeights := make([][2]uint64, 0)
-	for i, model := range *v2Models {
+// Transform satisfies typed.Transformer for eth.state_accounts
+func (t *Transformer) Transform(v2Models []AccountModelV2WithMeta, expectedRange [2]uint64) ([]AccountModelV3, [][2]uint64, error) {
+	v3Models, gaps, _, err := t.TransformWithDuplicates(v2Models, expectedRange)
+	return v3Models, gaps, err
+}
+
+// TransformWithDuplicates satisfies typed.DuplicateFinder for eth.state_accounts
+func (t *Transformer) TransformWithDuplicates(v2Models []AccountModelV2WithMeta, expectedRange [2]uint64) ([]AccountModelV3, [][2]uint64, []uint64, error) {
+	v3Models := make([]AccountModelV3, len(v2Models))
+	gapDetector := util.NewGapDetector(expectedRange)
+	for i, model := range v2Models {
 		height, err := strconv.ParseUint(model.BlockNumber, 10, 64)
 		if err != nil {
-			return nil, [][2]uint64{expectedRange}, fmt.Errorf("EthAccount transformer unable to parse blocknumber %s", model.BlockHash)
+			return nil, [][2]uint64{expectedRange}, nil, fmt.Errorf("EthAccount transformer unable to parse blocknumber %s", model.BlockHash)
 		}
-		// if the expected height doesn't match the actual current block height, we have a gap between the two
-		if expectedHeight < height {
-			missingHeights = append(missingHeights, [2]uint64{expectedHeight, height - 1})
-			expectedHeight = height
-		} else if height < expectedHeight {
-			return nil, [][2]uint64{expectedRange}, fmt.Errorf("it should not be possible for the current"+
-				"expected height (%d) to be greater than the actual current height (%d)", expectedHeight, height)
+		if err := gapDetector.Add(height, model.BlockHash); err != nil {
+			return nil, [][2]uint64{expectedRange}, nil, err
 		}
 		v3Models[i] = AccountModelV3{
 			HeaderID:    model.BlockHash,
@@ -62,11 +65,6 @@ func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (in
 			CodeHash:    model.CodeHash,
 			StorageRoot: model.StorageRoot,
 		}
-		expectedHeight++
-	}
-	// if the last processed height isn't the last block in the range, we have a gap at the end of the range
-	if expectedHeight-1 != expectedRange[1] {
-		missingHeights = append(missingHeights, [2]uint64{expectedHeight, expectedRange[1]})
 	}
-	return v3Models, missingHeights, nil
+	return v3Models, gapDetector.Gaps(), gapDetector.Duplicates(), nil
 }
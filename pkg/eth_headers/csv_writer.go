@@ -23,14 +23,16 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]HeaderModelV3), models)
 	}
-	for _, hModel := range hModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), hModel.BlockNumber, hModel.BlockHash, hModel.ParentHash, hModel.CID,
-			hModel.TotalDifficulty, hModel.NodeID, hModel.Reward, hModel.StateRoot, hModel.TxRoot, hModel.RctRoot, hModel.UncleRoot,
-			hModel.Bloom, hModel.Timestamp, hModel.MhKey, hModel.TimesValidated, hModel.Coinbase); err != nil {
-			return err
+	records := make([][]string, len(hModels))
+	for i, hModel := range hModels {
+		records[i] = []string{
+			hModel.BlockNumber, hModel.BlockHash, hModel.ParentHash, hModel.CID, hModel.MhKey,
+			hModel.TotalDifficulty, hModel.NodeID, hModel.Reward, hModel.StateRoot, hModel.UncleRoot,
+			hModel.TxRoot, hModel.RctRoot, csv.Bytea(hModel.Bloom), csv.Uint(hModel.Timestamp),
+			csv.Int(hModel.TimesValidated), hModel.Coinbase,
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
@@ -17,12 +17,14 @@
 package eth_headers
 
 import (
-	"fmt"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
+
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
+	"github.com/vulcanize/migration-tools/pkg/util"
 )
 
 // Transformer struct for transforming v2 DB eth.header_cids models to v3 DB models
@@ -31,34 +33,35 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for eth.header_cids
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for eth.header_cids
+func NewTypedTransformer() typed.Transformer[HeaderModelV2WithMeta, HeaderModelV3] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.header_cids
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v2Models, ok := models.(*[]HeaderModelV2WithMeta)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]HeaderModelV2WithMeta), models)
-	}
-	v3Models := make([]HeaderModelV3, len(*v2Models))
-	expectedHeight := expectedRange[0]
-	missingHeights := make([][2]uint64, 0)
-	for i, model := range *v2Models {
+// Transform satisfies typed.Transformer for eth.header_cids
+func (t *Transformer) Transform(v2Models []HeaderModelV2WithMeta, expectedRange [2]uint64) ([]HeaderModelV3, [][2]uint64, error) {
+	v3Models, gaps, _, err := t.TransformWithDuplicates(v2Models, expectedRange)
+	return v3Models, gaps, err
+}
+
+// TransformWithDuplicates satisfies typed.DuplicateFinder for eth.header_cids
+func (t *Transformer) TransformWithDuplicates(v2Models []HeaderModelV2WithMeta, expectedRange [2]uint64) ([]HeaderModelV3, [][2]uint64, []uint64, error) {
+	v3Models := make([]HeaderModelV3, len(v2Models))
+	gapDetector := util.NewGapDetector(expectedRange)
+	for i, model := range v2Models {
 		height, err := strconv.ParseUint(model.BlockNumber, 10, 64)
 		if err != nil {
-			return nil, [][2]uint64{expectedRange}, err
+			return nil, [][2]uint64{expectedRange}, nil, err
 		}
-		// if the expected height doesn't match the actual current block height, we have a gap between the two
-		if expectedHeight < height {
-			missingHeights = append(missingHeights, [2]uint64{expectedHeight, height - 1})
-			expectedHeight = height
-		} else if height < expectedHeight {
-			return nil, [][2]uint64{expectedRange}, fmt.Errorf("it should not be possible for the current"+
-				"expected height (%d) to be greater than the actual current height (%d)", expectedHeight, height)
+		if err := gapDetector.Add(height, model.BlockHash); err != nil {
+			return nil, [][2]uint64{expectedRange}, nil, err
 		}
 		header := new(types.Header)
 		if err := rlp.DecodeBytes(model.IPLD, header); err != nil {
-			return nil, [][2]uint64{expectedRange}, err
+			return nil, [][2]uint64{expectedRange}, nil, err
 		}
 		v3Models[i] = HeaderModelV3{
 			BlockNumber:     model.BlockNumber,
@@ -78,11 +81,6 @@ func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (in
 			TimesValidated:  model.TimesValidated,
 			Coinbase:        header.Coinbase.String(),
 		}
-		expectedHeight++
-	}
-	// if the last processed height isn't the last block in the range, we have a gap at the end of the range
-	if expectedHeight-1 != expectedRange[1] {
-		missingHeights = append(missingHeights, [2]uint64{expectedHeight, expectedRange[1]})
 	}
-	return v3Models, missingHeights, nil
+	return v3Models, gapDetector.Gaps(), gapDetector.Duplicates(), nil
 }
@@ -23,13 +23,15 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]LogModelV3), models)
 	}
-	for _, lModel := range lModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), lModel.LeafCID, lModel.LeafMhKey, lModel.ReceiptID,
-			lModel.Address, lModel.Index, lModel.Topic0, lModel.Topic1, lModel.Topic2, lModel.Topic3, lModel.Data); err != nil {
-			return err
+	records := make([][]string, len(lModels))
+	for i, lModel := range lModels {
+		records[i] = []string{
+			lModel.ReceiptID, lModel.LeafCID, lModel.LeafMhKey, lModel.Address,
+			csv.Int(lModel.Index), csv.Bytea(lModel.Data), lModel.Topic0, lModel.Topic1,
+			lModel.Topic2, lModel.Topic3,
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
@@ -0,0 +1,36 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_logs
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// NewLog returns the consensus fields of the log indexed by the v3 eth.log_cids model
+func NewLog(model LogModelV3) *types.Log {
+	log := new(types.Log)
+	log.Address = common.HexToAddress(model.Address)
+	log.Data = model.Data
+	log.Topics = make([]common.Hash, 0, 4)
+	for _, topic := range []string{model.Topic0, model.Topic1, model.Topic2, model.Topic3} {
+		if topic != "" {
+			log.Topics = append(log.Topics, common.HexToHash(topic))
+		}
+	}
+	return log
+}
@@ -19,7 +19,6 @@ package repair
 import (
 	"fmt"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/statediff/indexer/ipld"
@@ -30,6 +29,7 @@ import (
 	"github.com/vulcanize/migration-tools/pkg/eth_logs"
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
 	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/typed"
 )
 
 // Transformer struct for repairing log_cids models in the v3 DB
@@ -38,33 +38,19 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for repairing log_cids
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for repairing log_cids
+func NewTypedTransformer() typed.Transformer[eth_logs.LogModelV3, public_blocks.IPLDModel] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.log_cids
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v3Models, ok := models.(*[]eth_logs.LogModelV3)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]eth_logs.LogModelV3), models)
-	}
-	missingIPLDs := make([]public_blocks.IPLDModel, len(*v3Models))
-	for i, model := range *v3Models {
-		log := new(types.Log)
-		log.Address = common.HexToAddress(model.Address)
-		log.Data = model.Data
-		log.Topics = make([]common.Hash, 0, 4)
-		if model.Topic0 != "" {
-			log.Topics = append(log.Topics, common.HexToHash(model.Topic0))
-		}
-		if model.Topic1 != "" {
-			log.Topics = append(log.Topics, common.HexToHash(model.Topic1))
-		}
-		if model.Topic2 != "" {
-			log.Topics = append(log.Topics, common.HexToHash(model.Topic2))
-		}
-		if model.Topic3 != "" {
-			log.Topics = append(log.Topics, common.HexToHash(model.Topic3))
-		}
+// Transform satisfies typed.Transformer for eth.log_cids
+func (t *Transformer) Transform(v3Models []eth_logs.LogModelV3, expectedRange [2]uint64) ([]public_blocks.IPLDModel, [][2]uint64, error) {
+	missingIPLDs := make([]public_blocks.IPLDModel, len(v3Models))
+	for i, model := range v3Models {
+		log := eth_logs.NewLog(model)
 		data, key, err := rlpAndBlockStoreKey(log)
 		if err != nil {
 			return nil, nil, err
@@ -17,9 +17,8 @@
 package eth_logs
 
 import (
-	"fmt"
-
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
 )
 
 // Transformer struct for transforming v2 DB eth.log_cids models to v3 DB models
@@ -28,17 +27,18 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for eth.log_cids
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for eth.log_cids
+func NewTypedTransformer() typed.Transformer[LogModelV2WithMeta, LogModelV3] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.log_cids
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v2Models, ok := models.(*[]LogModelV2WithMeta)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]LogModelV2WithMeta), models)
-	}
-	v3Models := make([]LogModelV3, len(*v2Models))
-	for i, model := range *v2Models {
+// Transform satisfies typed.Transformer for eth.log_cids
+func (t *Transformer) Transform(v2Models []LogModelV2WithMeta, expectedRange [2]uint64) ([]LogModelV3, [][2]uint64, error) {
+	v3Models := make([]LogModelV3, len(v2Models))
+	for i, model := range v2Models {
 		v3Models[i] = LogModelV3{
 			ReceiptID: model.TxHash,
 			LeafCID:   model.LeafCID,
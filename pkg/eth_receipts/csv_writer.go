@@ -23,13 +23,14 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]ReceiptModelV3), models)
 	}
-	for _, rModel := range rModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), rModel.TxID, rModel.LeafCID, rModel.Contract,
-			rModel.ContractHash, rModel.LeafMhKey, rModel.PostState, rModel.PostStatus, rModel.LogRoot); err != nil {
-			return err
+	records := make([][]string, len(rModels))
+	for i, rModel := range rModels {
+		records[i] = []string{
+			rModel.TxID, rModel.LeafCID, rModel.LeafMhKey, csv.Uint(rModel.PostStatus),
+			rModel.PostState, rModel.Contract, rModel.ContractHash, rModel.LogRoot,
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
@@ -23,13 +23,14 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]StateModelV3), models)
 	}
-	for _, sModel := range sModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), sModel.HeaderID, sModel.StateKey, sModel.CID, sModel.Path,
-			sModel.NodeType, sModel.Diff, sModel.MhKey); err != nil {
-			return err
+	records := make([][]string, len(sModels))
+	for i, sModel := range sModels {
+		records[i] = []string{
+			sModel.HeaderID, csv.Bytea(sModel.Path), sModel.StateKey,
+			csv.Int(int64(sModel.NodeType)), sModel.CID, sModel.MhKey, csv.Bool(sModel.Diff),
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
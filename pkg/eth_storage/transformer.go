@@ -17,9 +17,8 @@
 package eth_storage
 
 import (
-	"fmt"
-
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
 )
 
 // Transformer struct for transforming v2 DB eth.storage_cids models to v2 DB models
@@ -28,17 +27,18 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for eth.storage_cids
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for eth.storage_cids
+func NewTypedTransformer() typed.Transformer[StorageModelV2WithMeta, StorageModelV3] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.storage_cids
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v2Models, ok := models.(*[]StorageModelV2WithMeta)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]StorageModelV2WithMeta), models)
-	}
-	v3Models := make([]StorageModelV3, len(*v2Models))
-	for i, model := range *v2Models {
+// Transform satisfies typed.Transformer for eth.storage_cids
+func (t *Transformer) Transform(v2Models []StorageModelV2WithMeta, expectedRange [2]uint64) ([]StorageModelV3, [][2]uint64, error) {
+	v3Models := make([]StorageModelV3, len(v2Models))
+	for i, model := range v2Models {
 		v3Models[i] = StorageModelV3{
 			HeaderID:   model.BlockHash,
 			StatePath:  model.StatePath,
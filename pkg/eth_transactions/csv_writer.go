@@ -23,13 +23,15 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]TransactionModelV3), models)
 	}
-	for _, tModel := range tModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), tModel.HeaderID, tModel.TxHash, tModel.CID, tModel.Dst,
-			tModel.Src, tModel.Index, tModel.MhKey, tModel.Data, tModel.Type, tModel.Value); err != nil {
-			return err
+	records := make([][]string, len(tModels))
+	for i, tModel := range tModels {
+		records[i] = []string{
+			tModel.HeaderID, csv.Int(tModel.Index), tModel.TxHash, tModel.CID, tModel.MhKey,
+			tModel.Dst, tModel.Src, csv.Bytea(tModel.Data), csv.Uint(uint64(tModel.Type)),
+			tModel.Value,
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
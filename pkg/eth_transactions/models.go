@@ -51,3 +51,10 @@ type TransactionModelV3 struct {
 	Type     uint8  `db:"tx_type"`
 	Value    string `db:"value"`
 }
+
+// TransactionModelV3WithRoot is the db model for eth.transaction_cids for v3 DB
+// with the tx_root of its header, which the transaction IPLD can be resolved through
+type TransactionModelV3WithRoot struct {
+	TxRoot string `db:"tx_root"`
+	TransactionModelV3
+}
@@ -0,0 +1,100 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package repair
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff/indexer/ipld"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/multiformats/go-multihash"
+
+	"github.com/vulcanize/migration-tools/pkg/eth_transactions"
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/typed"
+)
+
+// Transformer struct for repairing transaction_cids models in the v3 DB
+// the missing transaction IPLDs are resolved out of the transaction trie of their header, whose leaves hold the
+// consensus encoding of each transaction
+type Transformer struct {
+	fetcher interfaces.IPLDFetcher
+}
+
+// NewTransformer satisfies interfaces.TransformerConstructor for repairing transaction_cids
+func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for repairing transaction_cids
+func NewTypedTransformer() typed.Transformer[eth_transactions.TransactionModelV3WithRoot, public_blocks.IPLDModel] {
+	return &Transformer{}
+}
+
+// UseFetcher sets the fetcher the transaction trie nodes are read with; the Transformer returned by NewTransformer
+// satisfies interfaces.FetchingTransformer through it
+func (t *Transformer) UseFetcher(fetcher interfaces.IPLDFetcher) {
+	t.fetcher = fetcher
+}
+
+// Transform satisfies typed.Transformer for eth.transaction_cids
+func (t *Transformer) Transform(v3Models []eth_transactions.TransactionModelV3WithRoot, expectedRange [2]uint64) ([]public_blocks.IPLDModel, [][2]uint64, error) {
+	if t.fetcher == nil {
+		return nil, [][2]uint64{expectedRange}, fmt.Errorf("transaction_cids repair requires an IPLD fetcher")
+	}
+	// the nodes near the root of a trie are shared by every transaction of the block, so they are only fetched once
+	nodeReader := public_blocks.NewTrieNodeReader(t.fetcher)
+	missingIPLDs := make([]public_blocks.IPLDModel, len(v3Models))
+	for i, model := range v3Models {
+		trieKey, err := rlp.EncodeToBytes(uint64(model.Index))
+		if err != nil {
+			return nil, [][2]uint64{expectedRange}, err
+		}
+		data, err := nodeReader.Resolve(common.HexToHash(model.TxRoot), trieKey)
+		if err != nil {
+			return nil, [][2]uint64{expectedRange}, fmt.Errorf("unable to resolve transaction %s: %v", model.TxHash, err)
+		}
+		if txHash := crypto.Keccak256Hash(data).Hex(); txHash != model.TxHash {
+			return nil, [][2]uint64{expectedRange}, fmt.Errorf("transaction_cids record tx hash (%s) does not match derived tx hash (%s)", model.TxHash, txHash)
+		}
+		key, err := blockStoreKey(data)
+		if err != nil {
+			return nil, [][2]uint64{expectedRange}, err
+		}
+		if model.MhKey != key {
+			return nil, [][2]uint64{expectedRange}, fmt.Errorf("transaction_cids record mh key (%s) does not match derived mh key (%s)", model.MhKey, key)
+		}
+		missingIPLDs[i] = public_blocks.IPLDModel{
+			Key:  key,
+			Data: data,
+		}
+	}
+	return missingIPLDs, nil, nil
+}
+
+func blockStoreKey(txRaw []byte) (string, error) {
+	c, err := ipld.RawdataToCid(ipld.MEthTx, txRaw, multihash.KECCAK_256)
+	if err != nil {
+		return "", err
+	}
+	return blockstore.BlockPrefix.String() + dshelp.MultihashToDsKey(c.Hash()).String(), nil
+}
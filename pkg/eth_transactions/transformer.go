@@ -17,10 +17,9 @@
 package eth_transactions
 
 import (
-	"fmt"
-
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
 )
 
 // Transformer struct for transforming v2 DB eth.transaction_cids models to v3 DB models
@@ -29,17 +28,18 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for eth.transaction_cids
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for eth.transaction_cids
+func NewTypedTransformer() typed.Transformer[TransactionModelV2WithMeta, TransactionModelV3] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for eth.transaction_cids
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	v2Models, ok := models.(*[]TransactionModelV2WithMeta)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]TransactionModelV2WithMeta), models)
-	}
-	v3Models := make([]TransactionModelV3, len(*v2Models))
-	for i, model := range *v2Models {
+// Transform satisfies typed.Transformer for eth.transaction_cids
+func (t *Transformer) Transform(v2Models []TransactionModelV2WithMeta, expectedRange [2]uint64) ([]TransactionModelV3, [][2]uint64, error) {
+	v3Models := make([]TransactionModelV3, len(v2Models))
+	for i, model := range v2Models {
 		tx := new(types.Transaction)
 		if err := tx.UnmarshalBinary(model.IPLD); err != nil {
 			return nil, [][2]uint64{expectedRange}, err
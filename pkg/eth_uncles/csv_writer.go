@@ -23,13 +23,14 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]UncleModelV3), models)
 	}
-	for _, uModel := range uModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), uModel.BlockHash, uModel.HeaderID, uModel.ParentHash,
-			uModel.CID, uModel.Reward, uModel.MhKey); err != nil {
-			return err
+	records := make([][]string, len(uModels))
+	for i, uModel := range uModels {
+		records[i] = []string{
+			uModel.HeaderID, uModel.BlockHash, uModel.ParentHash, uModel.CID, uModel.MhKey,
+			uModel.Reward,
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
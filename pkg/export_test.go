@@ -0,0 +1,70 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+)
+
+// NewIdleMigrator returns a Migrator without any DB, with the provided number of workers per table
+func NewIdleMigrator(workersPerTable int) Migrator {
+	return &Service{
+		closeChan:          make(chan struct{}),
+		numWorkersPerTable: workersPerTable,
+	}
+}
+
+// TransferSegments transfers the page segments through the provided func, as Transfer does once it has found them,
+// with a single worker that retries each failed segment up to retries times and reports to the ReportWriter
+func TransferSegments(ctx context.Context, wg *sync.WaitGroup, transferPages func(firstPage, lastPage uint64) error,
+	segments [][2]uint64, retries int, backoff time.Duration, reportWriter *ReportWriter) (chan [2]uint64, chan struct{}, chan error) {
+	s := &Service{
+		closeChan:            make(chan struct{}),
+		numWorkersPerTable:   1,
+		transferRetries:      retries,
+		transferRetryBackoff: backoff,
+		reportWriter:         reportWriter,
+	}
+	return s.transferSegments(ctx, wg, public_blocks.DefaultV2FDWTableName, transferPages, segments)
+}
+
+// KeyHashOfRows returns the keyHash of the rows of key column values, as they are scanned from the v3 DB
+func KeyHashOfRows(rows ...[]interface{}) (string, error) {
+	hash := newKeyHash()
+	for _, row := range rows {
+		if err := hash.add(row); err != nil {
+			return "", err
+		}
+	}
+	return hash.String(), nil
+}
+
+// KeyHashOfModels returns the keyHash of the key columns of the provided slice of v3 models
+func KeyHashOfModels(models interface{}, keyColumns []string) (string, error) {
+	hash := newKeyHash()
+	if err := hash.addModels(reflectx.NewMapperFunc("db", sqlx.NameMapper), models, keyColumns); err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
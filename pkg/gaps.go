@@ -0,0 +1,114 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RetriedGapFileSuffix is appended to the name of a gap file once its ranges have been retried
+const RetriedGapFileSuffix = ".retried"
+
+// GapFileTableName returns the TableName encoded in a gap file name of the form "{table_name}_{unix_timestamp}"
+func GapFileTableName(path string) (TableName, error) {
+	name := filepath.Base(path)
+	sep := strings.LastIndex(name, "_")
+	if sep <= 0 {
+		return Unknown, fmt.Errorf("gap file name %s is not of the form {table_name}_{unix_timestamp}", name)
+	}
+	if _, err := strconv.ParseInt(name[sep+1:], 10, 64); err != nil {
+		return Unknown, fmt.Errorf("gap file name %s is not of the form {table_name}_{unix_timestamp}", name)
+	}
+	return NewTableNameFromString(name[:sep])
+}
+
+// ReadGapFile parses the "start, stop" lines of a read, write, or transfer gap file into block ranges
+func ReadGapFile(path string) ([][2]uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gaps := make([][2]uint64, 0)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		gap, err := parseGapLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("gap file %s line %d: %v", path, lineNum, err)
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps, scanner.Err()
+}
+
+func parseGapLine(line string) ([2]uint64, error) {
+	bounds := strings.Split(line, ",")
+	if len(bounds) != 2 {
+		return [2]uint64{}, fmt.Errorf("expected a line of the form \"start, stop\", got %q", line)
+	}
+	start, err := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 64)
+	if err != nil {
+		return [2]uint64{}, err
+	}
+	stop, err := strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 64)
+	if err != nil {
+		return [2]uint64{}, err
+	}
+	if stop < start {
+		return [2]uint64{}, fmt.Errorf("range stop (%d) is lower than range start (%d)", stop, start)
+	}
+	return [2]uint64{start, stop}, nil
+}
+
+// ReadGapDir parses every gap file in the provided directory, skipping those that have already been retried
+// it returns the gaps found for each table along with the paths of the files they were read from
+func ReadGapDir(dir string) (map[TableName][][2]uint64, map[TableName][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	gaps := make(map[TableName][][2]uint64)
+	files := make(map[TableName][]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), RetriedGapFileSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		tableName, err := GapFileTableName(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileGaps, err := ReadGapFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		gaps[tableName] = append(gaps[tableName], fileGaps...)
+		files[tableName] = append(files[tableName], path)
+	}
+	return gaps, files, nil
+}
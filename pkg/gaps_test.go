@@ -0,0 +1,108 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/util"
+)
+
+var _ = Describe("Gap files", Serial, func() {
+	var gapDir string
+	BeforeEach(func() {
+		gapDir, err = os.MkdirTemp("", "gaps")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(gapDir)).To(Succeed())
+	})
+
+	It("parses gap file names", Serial, func() {
+		tableName, err := migration_tools.GapFileTableName(filepath.Join(gapDir, "header_cids_1656633600"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tableName).To(Equal(migration_tools.EthHeaders))
+		tableName, err = migration_tools.GapFileTableName("log_cids_repair_1656633600")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tableName).To(Equal(migration_tools.EthLogsRepair))
+		_, err = migration_tools.GapFileTableName("header_cids")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads the gaps for each table, skipping retried files", Serial, func() {
+		Expect(os.WriteFile(filepath.Join(gapDir, "header_cids_1"), []byte("0, 9\r\n20, 29\r\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(gapDir, "header_cids_2"), []byte("5, 14\r\n"), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(gapDir, "storage_cids_1"), []byte(""), 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(gapDir, "state_cids_1"+migration_tools.RetriedGapFileSuffix), []byte("0, 9\r\n"), 0644)).To(Succeed())
+
+		gaps, files, err := migration_tools.ReadGapDir(gapDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(HaveLen(2))
+		Expect(migration_tools.MergeRanges(gaps[migration_tools.EthHeaders])).To(Equal([][2]uint64{{0, 14}, {20, 29}}))
+		Expect(gaps[migration_tools.EthStorage]).To(BeEmpty())
+		Expect(files[migration_tools.EthHeaders]).To(HaveLen(2))
+		Expect(files).ToNot(HaveKey(migration_tools.EthState))
+	})
+
+	It("rejects malformed lines", Serial, func() {
+		path := filepath.Join(gapDir, "header_cids_1")
+		Expect(os.WriteFile(path, []byte("0; 9\r\n"), 0644)).To(Succeed())
+		_, err := migration_tools.ReadGapFile(path)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("util.GapDetector", func() {
+	It("reports gaps by block height, independent of the number of rows per block", func() {
+		detector := util.NewGapDetector([2]uint64{10, 20})
+		for _, height := range []uint64{12, 12, 12, 13, 16, 16, 18} {
+			Expect(detector.Add(height, "mockHash")).To(Succeed())
+		}
+		Expect(detector.Gaps()).To(Equal([][2]uint64{{10, 11}, {14, 15}, {17, 17}, {19, 20}}))
+		Expect(detector.Duplicates()).To(BeEmpty())
+	})
+
+	It("reports the whole range when no rows are added", func() {
+		detector := util.NewGapDetector([2]uint64{10, 20})
+		Expect(detector.Gaps()).To(Equal([][2]uint64{{10, 20}}))
+	})
+
+	It("records the heights with rows for more than one block hash", func() {
+		detector := util.NewGapDetector([2]uint64{10, 12})
+		Expect(detector.Add(10, "mockHash1")).To(Succeed())
+		Expect(detector.Add(11, "mockHash2")).To(Succeed())
+		Expect(detector.Add(11, "mockHash3")).To(Succeed())
+		Expect(detector.Add(11, "mockHash2")).To(Succeed())
+		Expect(detector.Add(11, "mockHash4")).To(Succeed())
+		Expect(detector.Add(12, "mockHash5")).To(Succeed())
+		Expect(detector.Gaps()).To(BeEmpty())
+		Expect(detector.Duplicates()).To(Equal([]uint64{11}))
+	})
+
+	It("rejects rows that are out of order or outside of the range", func() {
+		detector := util.NewGapDetector([2]uint64{10, 20})
+		Expect(detector.Add(9, "mockHash")).ToNot(Succeed())
+		Expect(detector.Add(21, "mockHash")).ToNot(Succeed())
+		Expect(detector.Add(15, "mockHash")).To(Succeed())
+		Expect(detector.Add(14, "mockHash")).ToNot(Succeed())
+	})
+})
@@ -21,5 +21,23 @@ type Transformer interface {
 	Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error)
 }
 
+// DuplicateFindingTransformer interface for Transformers that also return the heights at which their models belong to
+// more than one block hash (i.e. reorgs)
+type DuplicateFindingTransformer interface {
+	Transformer
+	TransformWithDuplicates(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, []uint64, error)
+}
+
 // TransformerConstructor func sig for constructing a Transformer for a specific table
 type TransformerConstructor func() Transformer
+
+// IPLDFetcher interface for fetching IPLD block data by public.blocks key
+type IPLDFetcher interface {
+	FetchIPLDs(keys []string) (map[string][]byte, error)
+}
+
+// FetchingTransformer interface for Transformers that need IPLDs beyond the models they are provided with
+type FetchingTransformer interface {
+	Transformer
+	UseFetcher(fetcher IPLDFetcher)
+}
@@ -0,0 +1,36 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package interfaces
+
+import (
+	"io"
+
+	"github.com/vulcanize/migration-tools/pkg/sql"
+	"github.com/vulcanize/migration-tools/pkg/util"
+)
+
+// Writer interface for writing v3 DB models using a write statement
+type Writer interface {
+	Write(pgStr sql.WritePgStr, models interface{}) error
+	io.Closer
+}
+
+// HookedWriter is satisfied by Writers that can run a hook within the transaction the models are written in
+type HookedWriter interface {
+	Writer
+	WriteWithHook(pgStr sql.WritePgStr, models interface{}, hook util.TxHook) error
+}
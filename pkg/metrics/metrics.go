@@ -0,0 +1,167 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const namespace = "migration_tools"
+
+// Process labels
+const (
+	ProcessMigrate = "migrate"
+	ProcessCSV     = "csv"
+)
+
+// Stage labels
+const (
+	StageRead      = "read"
+	StageTransform = "transform"
+	StageValidate  = "validate"
+	StageWrite     = "write"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	rangesCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ranges_completed_total",
+		Help:      "Number of block ranges completed",
+	}, []string{"process", "table"})
+	rangesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ranges_failed_total",
+		Help:      "Number of block ranges that failed, by the stage they failed at",
+	}, []string{"process", "table", "stage"})
+	rowsRead = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_read_total",
+		Help:      "Number of rows read from the old DB",
+	}, []string{"process", "table"})
+	rowsWritten = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_written_total",
+		Help:      "Number of rows written to the new DB or csv files",
+	}, []string{"process", "table"})
+	stageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stage_duration_seconds",
+		Help:      "Time taken to read, transform, or write a block range",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+	}, []string{"process", "table", "stage"})
+	highestCompletedBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "highest_completed_block",
+		Help:      "Highest block height of the completed block ranges",
+	}, []string{"process", "table"})
+	transferPages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_pages_total",
+		Help:      "Number of pages transferred",
+	}, []string{"table"})
+	transferSegmentsFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_segments_failed_total",
+		Help:      "Number of page segments that failed to transfer",
+	}, []string{"table"})
+	transferPagesPerSecond = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "transfer_pages_per_second",
+		Help:      "Pages per second of the most recently transferred segment",
+	}, []string{"table"})
+
+	highestMu     sync.Mutex
+	highestBlocks = make(map[[2]string]uint64)
+)
+
+func init() {
+	registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(rangesCompleted, rangesFailed, rowsRead, rowsWritten, stageDuration, highestCompletedBlock,
+		transferPages, transferSegmentsFailed, transferPagesPerSecond)
+}
+
+// RangeCompleted records that the block range was completed for the table
+func RangeCompleted(process, table string, rng [2]uint64) {
+	rangesCompleted.WithLabelValues(process, table).Inc()
+	highestMu.Lock()
+	defer highestMu.Unlock()
+	key := [2]string{process, table}
+	if highest, ok := highestBlocks[key]; !ok || rng[1] > highest {
+		highestBlocks[key] = rng[1]
+		highestCompletedBlock.WithLabelValues(process, table).Set(float64(rng[1]))
+	}
+}
+
+// RangeFailed records that a block range failed for the table at the provided stage
+func RangeFailed(process, table, stage string) {
+	rangesFailed.WithLabelValues(process, table, stage).Inc()
+}
+
+// RowsRead records the number of rows read for the table
+func RowsRead(process, table string, rows int) {
+	rowsRead.WithLabelValues(process, table).Add(float64(rows))
+}
+
+// RowsWritten records the number of rows written for the table
+func RowsWritten(process, table string, rows int) {
+	rowsWritten.WithLabelValues(process, table).Add(float64(rows))
+}
+
+// ObserveStage records the time taken by a stage for the table, from the provided start time until now
+func ObserveStage(process, table, stage string, start time.Time) {
+	stageDuration.WithLabelValues(process, table, stage).Observe(time.Since(start).Seconds())
+}
+
+// TransferSegment records the transfer of a segment of pages for the table, and the time it took
+func TransferSegment(table string, pages uint64, elapsed time.Duration) {
+	transferPages.WithLabelValues(table).Add(float64(pages))
+	if elapsed > 0 {
+		transferPagesPerSecond.WithLabelValues(table).Set(float64(pages) / elapsed.Seconds())
+	}
+}
+
+// TransferSegmentFailed records a failed segment transfer for the table
+func TransferSegmentFailed(table string) {
+	transferSegmentsFailed.WithLabelValues(table).Inc()
+}
+
+// Handler returns the http.Handler serving the metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// StartServer starts an HTTP server exposing the metrics at /metrics on the provided address
+func StartServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		logrus.Infof("serving metrics at http://%s/metrics", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logrus.Errorf("metrics server error: %v", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,64 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/migration-tools/pkg/metrics"
+)
+
+var _ = Describe("metrics", Serial, func() {
+	scrape := func() string {
+		server := httptest.NewServer(metrics.Handler())
+		defer server.Close()
+		res, err := server.Client().Get(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return string(body)
+	}
+
+	It("tracks completed ranges and the highest completed block", Serial, func() {
+		metrics.RangeCompleted(metrics.ProcessMigrate, "metrics_test_table", [2]uint64{100, 199})
+		metrics.RangeCompleted(metrics.ProcessMigrate, "metrics_test_table", [2]uint64{0, 99})
+		body := scrape()
+		Expect(body).To(ContainSubstring(`migration_tools_ranges_completed_total{process="migrate",table="metrics_test_table"} 2`))
+		Expect(body).To(ContainSubstring(`migration_tools_highest_completed_block{process="migrate",table="metrics_test_table"} 199`))
+	})
+
+	It("tracks failures, rows, stage latency, and transfer rate", Serial, func() {
+		metrics.RangeFailed(metrics.ProcessCSV, "metrics_test_table", metrics.StageWrite)
+		metrics.RowsRead(metrics.ProcessCSV, "metrics_test_table", 10)
+		metrics.RowsWritten(metrics.ProcessCSV, "metrics_test_table", 8)
+		metrics.ObserveStage(metrics.ProcessCSV, "metrics_test_table", metrics.StageRead, time.Now())
+		metrics.TransferSegment("metrics_test_fdw", 100, 2*time.Second)
+		body := scrape()
+		Expect(body).To(ContainSubstring(`migration_tools_ranges_failed_total{process="csv",stage="write",table="metrics_test_table"} 1`))
+		Expect(body).To(ContainSubstring(`migration_tools_rows_read_total{process="csv",table="metrics_test_table"} 10`))
+		Expect(body).To(ContainSubstring(`migration_tools_rows_written_total{process="csv",table="metrics_test_table"} 8`))
+		Expect(body).To(ContainSubstring(`migration_tools_stage_duration_seconds_count{process="csv",stage="read",table="metrics_test_table"} 1`))
+		Expect(body).To(ContainSubstring(`migration_tools_transfer_pages_total{table="metrics_test_fdw"} 100`))
+		Expect(body).To(ContainSubstring(`migration_tools_transfer_pages_per_second{table="metrics_test_fdw"} 50`))
+	})
+})
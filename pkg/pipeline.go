@@ -0,0 +1,161 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
+)
+
+// tablePipeline reads the v2 models of a table by block range, and transforms them into the v3 models that are written out
+type tablePipeline interface {
+	read(reader *Reader, canonicalizer *Canonicalizer, rng [2]uint64) (readBatch, error)
+}
+
+// readBatch holds the v2 models read for a block range
+type readBatch interface {
+	len() int
+	// transform returns the transformed models, along with the gaps and the heights of more than one block hash
+	// found within the range
+	transform(rng [2]uint64) (writeBatch, [][2]uint64, []uint64, error)
+}
+
+// writeBatch holds the v3 models transformed from a block range
+type writeBatch interface {
+	len() int
+	// models returns the models as they are passed to writers and validators
+	models() interface{}
+}
+
+// pipelineConstructor func sig for constructing the tablePipeline of a table
+// the fetcher, if not nil, is provided to Transformers that fetch IPLDs beyond their read models
+type pipelineConstructor func(fetcher interfaces.IPLDFetcher) tablePipeline
+
+// newTablePipeline returns the tablePipeline of the table
+// tables registered through NewTypedTableSpec get a pipeline typed by their Transformer, the others one that goes
+// through their untyped read models and Transformer
+func newTablePipeline(tableName TableName, fetcher interfaces.IPLDFetcher) (tablePipeline, error) {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok {
+		return nil, fmt.Errorf("unsupported table name: %s", tableName)
+	}
+	if spec.newPipeline != nil {
+		return spec.newPipeline(fetcher), nil
+	}
+	transformer := spec.Transformer()
+	if fetchingTransformer, ok := transformer.(interfaces.FetchingTransformer); ok && fetcher != nil {
+		fetchingTransformer.UseFetcher(fetcher)
+	}
+	return untypedPipeline{tableName: tableName, newReadModels: spec.NewReadModels, transformer: transformer}, nil
+}
+
+// fetcherUser is satisfied by typed.Transformers that fetch IPLDs beyond their read models
+type fetcherUser interface {
+	UseFetcher(fetcher interfaces.IPLDFetcher)
+}
+
+// newTypedPipelineConstructor returns the pipelineConstructor of a table with a typed.Transformer
+func newTypedPipelineConstructor[In, Out any](tableName TableName, constructor typed.TransformerConstructor[In, Out]) pipelineConstructor {
+	return func(fetcher interfaces.IPLDFetcher) tablePipeline {
+		transformer := constructor()
+		if user, ok := transformer.(fetcherUser); ok && fetcher != nil {
+			user.UseFetcher(fetcher)
+		}
+		return typedPipeline[In, Out]{tableName: tableName, transformer: transformer}
+	}
+}
+
+// typedPipeline is the tablePipeline of a table with a typed.Transformer
+type typedPipeline[In, Out any] struct {
+	tableName   TableName
+	transformer typed.Transformer[In, Out]
+}
+
+func (p typedPipeline[In, Out]) read(reader *Reader, canonicalizer *Canonicalizer, rng [2]uint64) (readBatch, error) {
+	var models []In
+	if err := readTableRange(reader, canonicalizer, p.tableName, rng, &models); err != nil {
+		return nil, err
+	}
+	return typedReadBatch[In, Out]{models: models, transformer: p.transformer}, nil
+}
+
+// typedReadBatch is the readBatch of a typedPipeline
+type typedReadBatch[In, Out any] struct {
+	models      []In
+	transformer typed.Transformer[In, Out]
+}
+
+func (b typedReadBatch[In, Out]) len() int { return len(b.models) }
+
+func (b typedReadBatch[In, Out]) transform(rng [2]uint64) (writeBatch, [][2]uint64, []uint64, error) {
+	models, gaps, duplicates, err := typed.TransformWithDuplicates(b.transformer, b.models, rng)
+	if err != nil {
+		return nil, gaps, nil, err
+	}
+	return typedWriteBatch[Out](models), gaps, duplicates, nil
+}
+
+// typedWriteBatch is the writeBatch of a typedPipeline
+type typedWriteBatch[Out any] []Out
+
+func (b typedWriteBatch[Out]) len() int { return len(b) }
+
+func (b typedWriteBatch[Out]) models() interface{} { return []Out(b) }
+
+// untypedPipeline is the tablePipeline of a table registered with untyped read models and Transformer
+// the number of models is only known by reflecting on them
+type untypedPipeline struct {
+	tableName     TableName
+	newReadModels func() interface{}
+	transformer   interfaces.Transformer
+}
+
+func (p untypedPipeline) read(reader *Reader, canonicalizer *Canonicalizer, rng [2]uint64) (readBatch, error) {
+	models := p.newReadModels()
+	if err := readTableRange(reader, canonicalizer, p.tableName, rng, models); err != nil {
+		return nil, err
+	}
+	return untypedBatch{modelsVal: models, transformer: p.transformer}, nil
+}
+
+// untypedBatch is both the readBatch and the writeBatch of an untypedPipeline
+type untypedBatch struct {
+	modelsVal   interface{}
+	transformer interfaces.Transformer
+}
+
+func (b untypedBatch) len() int { return reflect.Indirect(reflect.ValueOf(b.modelsVal)).Len() }
+
+func (b untypedBatch) models() interface{} { return b.modelsVal }
+
+func (b untypedBatch) transform(rng [2]uint64) (writeBatch, [][2]uint64, []uint64, error) {
+	if finder, ok := b.transformer.(interfaces.DuplicateFindingTransformer); ok {
+		models, gaps, duplicates, err := finder.TransformWithDuplicates(b.modelsVal, rng)
+		if err != nil {
+			return nil, gaps, nil, err
+		}
+		return untypedBatch{modelsVal: models}, gaps, duplicates, nil
+	}
+	models, gaps, err := b.transformer.Transform(b.modelsVal, rng)
+	if err != nil {
+		return nil, gaps, nil, err
+	}
+	return untypedBatch{modelsVal: models}, gaps, nil, nil
+}
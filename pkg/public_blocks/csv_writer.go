@@ -23,12 +23,13 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]IPLDModel), models)
 	}
-	for _, iModel := range iModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), iModel.Key, iModel.Data); err != nil {
-			return err
+	records := make([][]string, len(iModels))
+	for i, iModel := range iModels {
+		records[i] = []string{
+			iModel.Key, csv.Bytea(iModel.Data),
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
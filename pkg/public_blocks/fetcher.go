@@ -0,0 +1,72 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package public_blocks
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/multiformats/go-multihash"
+)
+
+const pgReadIPLDsStr = `SELECT key, data FROM public.blocks WHERE key = ANY($1)`
+
+// maxKeysPerFetch bounds the number of keys looked up by a single query
+const maxKeysPerFetch = 10000
+
+// Fetcher fetches IPLDs out of public.blocks
+type Fetcher struct {
+	db *sqlx.DB
+}
+
+// NewFetcher returns a new Fetcher for the public.blocks table of the provided DB
+func NewFetcher(db *sqlx.DB) *Fetcher {
+	return &Fetcher{db: db}
+}
+
+// FetchIPLDs satisfies interfaces.IPLDFetcher
+// the returned map only holds the keys that were found
+// the keys are looked up maxKeysPerFetch at a time, so that a large range does not end up in one huge query
+// FetchIPLDs is safe for concurrent use, as the only shared state is the concurrent safe *sqlx.DB
+func (f *Fetcher) FetchIPLDs(keys []string) (map[string][]byte, error) {
+	iplds := make(map[string][]byte, len(keys))
+	for start := 0; start < len(keys); start += maxKeysPerFetch {
+		stop := start + maxKeysPerFetch
+		if stop > len(keys) {
+			stop = len(keys)
+		}
+		var models []IPLDModel
+		if err := f.db.Select(&models, pgReadIPLDsStr, pq.Array(keys[start:stop])); err != nil {
+			return nil, err
+		}
+		for _, model := range models {
+			iplds[model.Key] = model.Data
+		}
+	}
+	return iplds, nil
+}
+
+// Keccak256Key returns the public.blocks key of the IPLD whose keccak-256 hash is the provided hash
+func Keccak256Key(hash common.Hash) (string, error) {
+	mh, err := multihash.Encode(hash.Bytes(), multihash.KECCAK_256)
+	if err != nil {
+		return "", err
+	}
+	return blockstore.BlockPrefix.String() + dshelp.MultihashToDsKey(mh).String(), nil
+}
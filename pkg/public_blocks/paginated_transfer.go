@@ -27,12 +27,40 @@ import (
 
 const DefaultV2FDWTableName = "v2db_public_blocks"
 
+// V2TableName is the table read from the old DB by a direct transfer
+const V2TableName = "public.blocks"
+
+// TransferMode determines how public.blocks is transferred to the new DB
+type TransferMode string
+
+const (
+	// TransferModeFDW copies the pages of a postgres_fdw foreign table within the new DB
+	TransferModeFDW TransferMode = "fdw"
+	// TransferModeDirect reads the pages from the old DB and writes them to the new DB through the Migrator's connections
+	TransferModeDirect TransferMode = "direct"
+)
+
+// NewTransferModeFromString returns the TransferMode from the provided string
+func NewTransferModeFromString(modeStr string) (TransferMode, error) {
+	switch strings.ToLower(modeStr) {
+	case "", "fdw":
+		return TransferModeFDW, nil
+	case "direct":
+		return TransferModeDirect, nil
+	default:
+		return "", fmt.Errorf("unrecognized transfer mode: %s", modeStr)
+	}
+}
+
 const (
 	getMaxPagePgStr   = `SELECT MAX(ctid)::TEXT FROM %s`
 	transferPagePgStr = "INSERT INTO public.blocks(key, data)" +
 		"SELECT key, data FROM %s WHERE ctid = ANY (ARRAY(SELECT ('(%d,' || s.i || ')')::tid " +
 		"FROM generate_series(0, current_setting('block_size')::int/4) AS s(i)))" +
 		"ON CONFLICT (key) DO NOTHING"
+	readPagesPgStr = "SELECT key, data FROM %s WHERE ctid = ANY (ARRAY(SELECT ('(' || p.i || ',' || s.i || ')')::tid " +
+		"FROM generate_series($1::BIGINT, $2::BIGINT) AS p(i), " +
+		"generate_series(0, current_setting('block_size')::int/4) AS s(i)))"
 )
 
 func GetMaxPage(db *sqlx.DB, fdwTableName string) (uint64, error) {
@@ -48,22 +76,22 @@ func GetMaxPage(db *sqlx.DB, fdwTableName string) (uint64, error) {
 	return strconv.ParseUint(maxPageStr, 10, 64)
 }
 
+// GetPageSegments splits the pages 0 through maxPage up into consecutive segments of segmentSize pages
 func GetPageSegments(maxPage, segmentSize uint64) [][2]uint64 {
-	numSegments := maxPage / segmentSize
-	remainder := maxPage % segmentSize
-	if remainder != 0 {
-		numSegments = numSegments + 1
-	}
-	segments := make([][2]uint64, numSegments+1)
-	currentPage := uint64(0)
-	for i := uint64(0); i < numSegments; i++ {
-		segments[i] = [2]uint64{currentPage, currentPage + segmentSize - 1}
-		currentPage = currentPage + segmentSize
+	segments := make([][2]uint64, 0, maxPage/segmentSize+1)
+	for currentPage := uint64(0); currentPage <= maxPage; currentPage += segmentSize {
+		lastPage := currentPage + segmentSize - 1
+		if lastPage > maxPage {
+			lastPage = maxPage
+		}
+		segments = append(segments, [2]uint64{currentPage, lastPage})
 	}
 	return segments
 }
 
-func TransferPages(db *sqlx.DB, fdwTableName string, firstPage, lastPage uint64) (err error) {
+// TransferPages copies the pages firstPage through lastPage of the foreign table into public.blocks within a single transaction
+// the hook, if any, is run within the transaction before it is committed
+func TransferPages(db *sqlx.DB, fdwTableName string, firstPage, lastPage uint64, hook util.TxHook) (err error) {
 	tx, err := db.Beginx()
 	if err != nil {
 		return err
@@ -82,5 +110,20 @@ func TransferPages(db *sqlx.DB, fdwTableName string, firstPage, lastPage uint64)
 			return err
 		}
 	}
+	if hook != nil {
+		if err = hook(tx); err != nil {
+			return err
+		}
+	}
 	return tx.Commit()
 }
+
+// ReadPages reads the rows stored in the pages firstPage through lastPage of the table
+// the ctids of every page are looked up in a single query, so that the pages are all read from the same snapshot
+func ReadPages(db *sqlx.DB, tableName string, firstPage, lastPage uint64) ([]IPLDModel, error) {
+	var models []IPLDModel
+	if err := db.Select(&models, fmt.Sprintf(readPagesPgStr, tableName), firstPage, lastPage); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
@@ -0,0 +1,104 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package public_blocks
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+)
+
+// TrieNodeReader reads trie nodes out of public.blocks by their hash, caching every node it fetches
+// TrieNodeReader is not safe for concurrent use
+type TrieNodeReader struct {
+	fetcher interfaces.IPLDFetcher
+	nodes   map[common.Hash][]byte
+	err     error
+}
+
+// NewTrieNodeReader returns a new TrieNodeReader that fetches trie nodes with the provided fetcher
+func NewTrieNodeReader(fetcher interfaces.IPLDFetcher) *TrieNodeReader {
+	return &TrieNodeReader{
+		fetcher: fetcher,
+		nodes:   make(map[common.Hash][]byte),
+	}
+}
+
+// Has satisfies ethdb.KeyValueReader
+func (r *TrieNodeReader) Has(hash []byte) (bool, error) {
+	node, err := r.Get(hash)
+	return node != nil, err
+}
+
+// Get satisfies ethdb.KeyValueReader
+// it returns nil, without an error, if the node is not in public.blocks
+func (r *TrieNodeReader) Get(hash []byte) ([]byte, error) {
+	nodeHash := common.BytesToHash(hash)
+	if node, ok := r.nodes[nodeHash]; ok {
+		return node, nil
+	}
+	key, err := Keccak256Key(nodeHash)
+	if err != nil {
+		return nil, err
+	}
+	iplds, err := r.fetcher.FetchIPLDs([]string{key})
+	if err != nil {
+		// trie.VerifyProof does not surface read errors, so hold on to the first one
+		if r.err == nil {
+			r.err = err
+		}
+		return nil, err
+	}
+	node, ok := iplds[key]
+	if ok {
+		r.nodes[nodeHash] = node
+	}
+	return node, nil
+}
+
+// Resolve walks the trie with the provided root down to the provided key and returns the value stored at the key
+func (r *TrieNodeReader) Resolve(root common.Hash, key []byte) ([]byte, error) {
+	value, err := trie.VerifyProof(root, key, r)
+	if r.err != nil {
+		err, r.err = r.err, nil
+		return nil, fmt.Errorf("unable to fetch the nodes of trie %s: %v", root.Hex(), err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to walk trie %s to key %x: %v", root.Hex(), key, err)
+	}
+	if value == nil {
+		return nil, fmt.Errorf("trie %s has no value at key %x", root.Hex(), key)
+	}
+	return value, nil
+}
+
+// LeafValue returns the value held by the provided trie leaf node
+func LeafValue(node []byte) ([]byte, error) {
+	var elems [][]byte
+	if err := rlp.DecodeBytes(node, &elems); err != nil {
+		return nil, fmt.Errorf("unable to decode trie node: %v", err)
+	}
+	// a leaf node is the hex prefix encoded remainder of its path, with the leaf flag set, followed by its value
+	if len(elems) != 2 || len(elems[0]) == 0 || elems[0][0]>>4 < 2 {
+		return nil, fmt.Errorf("trie node is not a leaf node")
+	}
+	return elems[1], nil
+}
@@ -23,13 +23,14 @@ func (cw *CSVWriter) Write(pgStr csv.WriteCSVStr, models interface{}) error {
 	if !ok {
 		return fmt.Errorf("expected models of type %T, got %T", new([]NodeModel), models)
 	}
-	for _, nModel := range nModels {
-		if _, err := fmt.Fprintf(cw.dst, string(pgStr), nModel.GenesisBlock, nModel.NetworkID, nModel.NodeID,
-			nModel.ClientName, nModel.ChainID); err != nil {
-			return err
+	records := make([][]string, len(nModels))
+	for i, nModel := range nModels {
+		records[i] = []string{
+			nModel.ClientName, nModel.GenesisBlock, nModel.NetworkID, nModel.NodeID,
+			csv.Int(int64(nModel.ChainID)),
 		}
 	}
-	return nil
+	return csv.WriteRecords(cw.dst, pgStr, records)
 }
 
 // Close satisfies io.Closer
@@ -17,9 +17,8 @@
 package public_nodes
 
 import (
-	"fmt"
-
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/typed"
 )
 
 // Transformer for transforming v2 DB public.nodes models to v3 DB models
@@ -28,14 +27,15 @@ type Transformer struct {
 
 // NewTransformer satisfies interfaces.TransformerConstructor for public.nodes
 func NewTransformer() interfaces.Transformer {
+	return typed.Adapt(NewTypedTransformer())
+}
+
+// NewTypedTransformer satisfies typed.TransformerConstructor for public.nodes
+func NewTypedTransformer() typed.Transformer[NodeModel, NodeModel] {
 	return &Transformer{}
 }
 
-// Transform satisfies interfaces.Transformer for public.nodes
-func (t *Transformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
-	nodeModels, ok := models.(*[]NodeModel)
-	if !ok {
-		return nil, [][2]uint64{expectedRange}, fmt.Errorf("expected models of type %T, got %T", new([]NodeModel), models)
-	}
-	return *nodeModels, nil, nil
+// Transform satisfies typed.Transformer for public.nodes
+func (t *Transformer) Transform(nodeModels []NodeModel, expectedRange [2]uint64) ([]NodeModel, [][2]uint64, error) {
+	return nodeModels, nil, nil
 }
@@ -18,9 +18,12 @@ package migration_tools
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum/statediff/indexer/database/sql/postgres"
+	"github.com/jmoiron/sqlx"
 )
 
 // PgReadMinAndMaxBlockNumbers for finding the min and max block height in the DB
@@ -33,9 +36,79 @@ type MinAndMux struct {
 	Max string `db:"max"`
 }
 
+// pgReadHeightRangesStr finds the contiguous ranges of block heights between $1 and $2 that have a header matching a filter
+const pgReadHeightRangesStr = `SELECT MIN(block_number) AS start, MAX(block_number) AS stop
+								FROM (
+									SELECT block_number, block_number - ROW_NUMBER() OVER (ORDER BY block_number) AS island
+									FROM (
+										SELECT DISTINCT block_number FROM eth.header_cids
+										WHERE block_number BETWEEN $1 AND $2 AND (%s)
+									) AS heights
+								) AS islands
+								GROUP BY island
+								ORDER BY start`
+
+// heightRangeModel is the db model for a contiguous range of block heights
+type heightRangeModel struct {
+	Start uint64 `db:"start"`
+	Stop  uint64 `db:"stop"`
+}
+
+// readHeightRanges returns the contiguous ranges of block heights within the bounds that have a header matching the filter
+func readHeightRanges(db *sqlx.DB, filter string, bounds [2]uint64) ([][2]uint64, error) {
+	var models []heightRangeModel
+	if err := db.Select(&models, fmt.Sprintf(pgReadHeightRangesStr, filter), bounds[0], bounds[1]); err != nil {
+		return nil, err
+	}
+	ranges := make([][2]uint64, len(models))
+	for i, model := range models {
+		ranges[i] = [2]uint64{model.Start, model.Stop}
+	}
+	return ranges, nil
+}
+
+// DetectMissingRanges returns the block ranges within the bounds that the table has rows at in the v2 DB, but not in the v3 DB
+// if no v3 DB is provided, every range within the bounds that the table has rows at in the v2 DB is returned
+// the heights are read from eth.header_cids, checking each header against the table's height filter
+func DetectMissingRanges(oldDB, newDB *sqlx.DB, tableName TableName, bounds [2]uint64) ([][2]uint64, error) {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok || spec.HeightFilter == "" {
+		return nil, fmt.Errorf("auto range detection is not supported for table %s", tableName)
+	}
+	present, err := readHeightRanges(oldDB, spec.HeightFilter, bounds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the v2 block ranges of table %s: %v", tableName, err)
+	}
+	if newDB == nil || spec.MigratedHeightFilter == "" || len(present) == 0 {
+		return present, nil
+	}
+	migrated, err := readHeightRanges(newDB, spec.MigratedHeightFilter, bounds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the v3 block ranges of table %s: %v", tableName, err)
+	}
+	return SubtractRanges(present, migrated), nil
+}
+
+// DetectMigratedRanges returns the block ranges within the bounds that the table has rows at in the v3 DB
+// the heights are read from eth.header_cids, checking each header against the table's migrated height filter
+func DetectMigratedRanges(newDB *sqlx.DB, tableName TableName, bounds [2]uint64) ([][2]uint64, error) {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok || spec.MigratedHeightFilter == "" {
+		return nil, fmt.Errorf("auto range detection in the v3 DB is not supported for table %s", tableName)
+	}
+	migrated, err := readHeightRanges(newDB, spec.MigratedHeightFilter, bounds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the v3 block ranges of table %s: %v", tableName, err)
+	}
+	return migrated, nil
+}
+
 // SegmentRangeByChunkSize splits the provided range up into segments based on the desired size of the segments
 func SegmentRangeByChunkSize(chunkSize, start, stop uint64) [][2]uint64 {
 	totalRangeSize := stop - start + 1
+	if totalRangeSize <= chunkSize {
+		return [][2]uint64{{start, stop}}
+	}
 	numOfChunks := totalRangeSize / chunkSize
 	remainder := totalRangeSize % chunkSize
 
@@ -73,3 +146,73 @@ func DetectAndSegmentRangeByChunkSize(readConf postgres.Config, chunkSize uint64
 	}
 	return SegmentRangeByChunkSize(chunkSize, min, max), nil
 }
+
+// MergeRanges sorts the provided ranges and merges any that overlap or are directly adjacent
+func MergeRanges(ranges [][2]uint64) [][2]uint64 {
+	return mergeRanges(ranges, true)
+}
+
+// DedupeRanges sorts the provided ranges and merges any that overlap, leaving directly adjacent ranges apart
+// so that ranges of a bounded size stay bounded unless they overlap
+func DedupeRanges(ranges [][2]uint64) [][2]uint64 {
+	return mergeRanges(ranges, false)
+}
+
+// mergeRanges sorts the provided ranges and merges any that overlap, and those that are directly adjacent if adjacent is set
+func mergeRanges(ranges [][2]uint64, adjacent bool) [][2]uint64 {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := make([][2]uint64, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i][0] == sorted[j][0] {
+			return sorted[i][1] < sorted[j][1]
+		}
+		return sorted[i][0] < sorted[j][0]
+	})
+	merged := make([][2]uint64, 0, len(sorted))
+	current := sorted[0]
+	for _, rng := range sorted[1:] {
+		if rng[0] <= current[1] || (adjacent && rng[0] == current[1]+1) {
+			if rng[1] > current[1] {
+				current[1] = rng[1]
+			}
+			continue
+		}
+		merged = append(merged, current)
+		current = rng
+	}
+	return append(merged, current)
+}
+
+// SubtractRanges removes the provided completed ranges from the provided ranges, splitting ranges where necessary
+// the order of the provided ranges is preserved
+func SubtractRanges(ranges, completed [][2]uint64) [][2]uint64 {
+	completed = MergeRanges(completed)
+	pending := make([][2]uint64, 0, len(ranges))
+	for _, rng := range ranges {
+		start := rng[0]
+		remaining := true
+		for _, done := range completed {
+			if done[1] < start {
+				continue
+			}
+			if done[0] > rng[1] {
+				break
+			}
+			if done[0] > start {
+				pending = append(pending, [2]uint64{start, done[0] - 1})
+			}
+			if done[1] >= rng[1] {
+				remaining = false
+				break
+			}
+			start = done[1] + 1
+		}
+		if remaining {
+			pending = append(pending, [2]uint64{start, rng[1]})
+		}
+	}
+	return pending
+}
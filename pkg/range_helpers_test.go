@@ -17,6 +17,9 @@
 package migration_tools_test
 
 import (
+	"fmt"
+	"math"
+
 	"github.com/jmoiron/sqlx"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -95,6 +98,37 @@ var _ = Describe("Range Segmenting Helpers", Serial, func() {
 				Expect(segments[len(segments)-1][1]).To(Equal(testCase.Stop))
 			}
 		})
+		It("returns ranges smaller than the chunk size as a single segment", Serial, func() {
+			Expect(migration_tools.SegmentRangeByChunkSize(10, 5, 9)).To(Equal([][2]uint64{{5, 9}}))
+			Expect(migration_tools.SegmentRangeByChunkSize(10, 0, 9)).To(Equal([][2]uint64{{0, 9}}))
+		})
+	})
+	Describe("MergeRanges", Serial, func() {
+		It("merges overlapping and adjacent ranges", Serial, func() {
+			merged := migration_tools.MergeRanges([][2]uint64{{20, 30}, {0, 9}, {10, 15}, {25, 40}, {50, 60}})
+			Expect(merged).To(Equal([][2]uint64{{0, 15}, {20, 40}, {50, 60}}))
+		})
+		It("returns nil for no ranges", Serial, func() {
+			Expect(migration_tools.MergeRanges(nil)).To(BeNil())
+		})
+	})
+	Describe("DedupeRanges", Serial, func() {
+		It("merges overlapping ranges but leaves adjacent ranges apart", Serial, func() {
+			deduped := migration_tools.DedupeRanges([][2]uint64{{20, 30}, {0, 9}, {10, 15}, {25, 40}, {0, 9}, {50, 60}})
+			Expect(deduped).To(Equal([][2]uint64{{0, 9}, {10, 15}, {20, 40}, {50, 60}}))
+		})
+	})
+	Describe("SubtractRanges", Serial, func() {
+		It("removes completed ranges and splits partially completed ranges", Serial, func() {
+			ranges := [][2]uint64{{0, 99}, {100, 199}, {200, 299}, {300, 399}}
+			completed := [][2]uint64{{0, 99}, {150, 159}, {170, 250}}
+			pending := migration_tools.SubtractRanges(ranges, completed)
+			Expect(pending).To(Equal([][2]uint64{{100, 149}, {160, 169}, {251, 299}, {300, 399}}))
+		})
+		It("returns the ranges untouched when nothing is completed", Serial, func() {
+			ranges := [][2]uint64{{0, 99}, {100, 199}}
+			Expect(migration_tools.SubtractRanges(ranges, nil)).To(Equal(ranges))
+		})
 	})
 	Describe("DetectAndSegmentRangeByChunkSize", Serial, Label("test"), func() {
 		BeforeEach(func() {
@@ -189,4 +223,89 @@ var _ = Describe("Range Segmenting Helpers", Serial, func() {
 			Expect(segments[len(segments)-1][1]).To(Equal(migration_tools.BlockNumber.Uint64()))
 		})
 	})
+	Describe("DetectMissingRanges", Serial, Label("test"), func() {
+		var v2DB *sqlx.DB
+		v3Hashes := make(map[string]string)
+		everyHeight := [2]uint64{0, math.MaxInt64}
+		BeforeEach(func() {
+			sqlxDB, err = sqlx.Connect("postgres", v3DBConfig.DbConnectionString())
+			Expect(err).ToNot(HaveOccurred())
+			tearDownSQLXDB(sqlxDB)
+			v2DB, err = sqlx.Connect("postgres", v2DBConfig.DbConnectionString())
+			Expect(err).ToNot(HaveOccurred())
+			prepV2SQLXDB(v2DB)
+
+			for _, height := range []uint64{10, 11, 12, 13, 20, 21} {
+				hash := fmt.Sprintf("0x%d", height)
+				writeV2Header(v2DB, height, hash, "", 1)
+			}
+			// a reorged height only counts once
+			writeV2Header(v2DB, 12, "0x12b", "", 1)
+
+			header := migration_tools.MockHeader
+			_, err = sqlxDB.Exec(writeIPDL, "mockMhKey", []byte{1, 2, 3, 4})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = sqlxDB.Exec(writeNodeStr, "mockName", "mockGenesisBlock", 1, "mockNodeID", 1337)
+			Expect(err).ToNot(HaveOccurred())
+			for _, height := range []string{"11", "12", "21"} {
+				v3Hashes[height] = randomHash().String()
+				_, err = sqlxDB.Exec(writeSingleV3Header, height, v3Hashes[height], header.ParentHash.String(),
+					"mockCID", "mockMhKey", header.Difficulty.String(), "mockNodeID", "1010230213", header.Root.String(),
+					header.UncleHash.String(), header.TxHash.String(), header.ReceiptHash.String(), header.Bloom.Bytes(),
+					header.Time, 1, header.Coinbase.String())
+				Expect(err).ToNot(HaveOccurred())
+			}
+		})
+		AfterEach(func() {
+			tearDownSQLXDB(sqlxDB)
+			tearDownV2SQLXDB(v2DB)
+			Expect(v2DB.Close()).To(Succeed())
+		})
+		It("returns the ranges present in the v2 DB but not in the v3 DB", Serial, func() {
+			missing, err := migration_tools.DetectMissingRanges(v2DB, sqlxDB, migration_tools.EthHeaders, everyHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([][2]uint64{{10, 10}, {13, 13}, {20, 20}}))
+		})
+		It("returns every range present in the v2 DB without a v3 DB", Serial, func() {
+			present, err := migration_tools.DetectMissingRanges(v2DB, nil, migration_tools.EthHeaders, everyHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(present).To(Equal([][2]uint64{{10, 13}, {20, 21}}))
+		})
+		It("returns the ranges present in the v3 DB", Serial, func() {
+			migrated, err := migration_tools.DetectMigratedRanges(sqlxDB, migration_tools.EthHeaders, everyHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(migrated).To(Equal([][2]uint64{{11, 12}, {21, 21}}))
+			migrated, err = migration_tools.DetectMigratedRanges(sqlxDB, migration_tools.EthHeaders, [2]uint64{12, 20})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(migrated).To(Equal([][2]uint64{{12, 12}}))
+		})
+		It("only searches the heights within the bounds", Serial, func() {
+			missing, err := migration_tools.DetectMissingRanges(v2DB, sqlxDB, migration_tools.EthHeaders, [2]uint64{11, 20})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([][2]uint64{{13, 13}, {20, 20}}))
+		})
+		It("finds the heights of child tables through their headers", Serial, func() {
+			missing, err := migration_tools.DetectMissingRanges(v2DB, sqlxDB, migration_tools.EthState, everyHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(BeEmpty())
+
+			// state nodes at heights 10, 11, 12, and 20 of the v2 DB, of which only height 11 is in the v3 DB
+			for i, blockHash := range []string{"0x10", "0x11", "0x12b", "0x20"} {
+				_, err = v2DB.Exec(`INSERT INTO eth.state_cids (header_id, cid, mh_key, state_path, node_type)
+					SELECT id, $2, $3, $4, 2 FROM eth.header_cids WHERE block_hash = $1`,
+					blockHash, "mockStateCID", "mhKey"+blockHash, []byte{byte(i)})
+				Expect(err).ToNot(HaveOccurred())
+			}
+			_, err = sqlxDB.Exec(`INSERT INTO eth.state_cids (header_id, state_path, node_type, cid, mh_key)
+				VALUES ($1, $2, 2, $3, $4)`, v3Hashes["11"], []byte{1}, "mockStateCID", "mockMhKey")
+			Expect(err).ToNot(HaveOccurred())
+
+			present, err := migration_tools.DetectMissingRanges(v2DB, nil, migration_tools.EthState, everyHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(present).To(Equal([][2]uint64{{10, 12}, {20, 20}}))
+			missing, err = migration_tools.DetectMissingRanges(v2DB, sqlxDB, migration_tools.EthState, everyHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(missing).To(Equal([][2]uint64{{10, 10}, {12, 12}, {20, 20}}))
+		})
+	})
 })
@@ -18,6 +18,8 @@ package migration_tools
 
 import (
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
 	"github.com/vulcanize/migration-tools/pkg/sql"
 )
 
@@ -37,6 +39,17 @@ func (r *Reader) Read(blockRange [2]uint64, pgStr sql.ReadPgStr, models interfac
 	return r.db.Select(models, string(pgStr), blockRange[0], blockRange[1])
 }
 
+// ReadExcluding reads the models for the block range with a statement built by sql.ReadPgStr.ExcludingBlocks,
+// leaving out the rows of the blocks with the provided hashes
+// ReadExcluding is safe for concurrent use, as the only shared state is the concurrent safe *sqlx.DB
+func (r *Reader) ReadExcluding(blockRange [2]uint64, pgStr sql.ReadPgStr, blockHashes []string, models interface{}) error {
+	if blockHashes == nil {
+		// a nil array is encoded as NULL, which would leave out every row rather than none
+		blockHashes = []string{}
+	}
+	return r.db.Select(models, string(pgStr), blockRange[0], blockRange[1], pq.Array(blockHashes))
+}
+
 // Close satisfies io.Closer
 func (r *Reader) Close() error {
 	return r.db.Close()
@@ -0,0 +1,163 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vulcanize/migration-tools/pkg/csv"
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+	"github.com/vulcanize/migration-tools/pkg/typed"
+)
+
+// TableSpec describes how the rows of a table are read from the v2 DB, transformed, and written out
+// Name, ReadQuery, NewReadModels, and Transformer are required, the other fields turn on the features that use them
+type TableSpec struct {
+	// Name is the name of the table, as used in configs, gap files, checkpoints, and reports
+	Name TableName
+	// Aliases are the other strings the table can be configured by, matched case-insensitively
+	Aliases []string
+
+	// ReadQuery reads the v2 rows of a block range, taking its start and stop heights as $1 and $2
+	ReadQuery sql.ReadPgStr
+	// NewReadModels returns an allocation for the models ReadQuery reads into, a pointer to a slice of structs
+	NewReadModels func() interface{}
+	// Transformer constructs a Transformer from the read models into the models written by WriteQuery and CSVWriter
+	// Transformers that also satisfy interfaces.FetchingTransformer are provided with a fetcher of v2 IPLDs
+	Transformer interfaces.TransformerConstructor
+
+	// WriteQuery writes the transformed models to the v3 DB; tables without one can only be exported to csv
+	WriteQuery sql.WritePgStr
+	// ConflictKey is the v3 primary key WriteQuery conflicts on, for the do-nothing and update conflict policies
+	ConflictKey sql.ConflictKey
+	// CSVWriteQuery is passed to the csv.Writer along with the transformed models
+	CSVWriteQuery csv.WriteCSVStr
+	// CSVWriter constructs the csv.Writer of the table; tables without one can not be exported to csv
+	CSVWriter csv.WriterConstructor
+
+	// GapChecked tables have rows at every block height, so a range without any is reported as a read gap
+	GapChecked bool
+	// Dependencies are the tables the v3 rows of the table reference through foreign keys
+	Dependencies []TableName
+	// CanonicalFiltered tables are read through eth.header_cids, so ReadQuery can be limited to the canonical blocks
+	CanonicalFiltered bool
+	// Unsampled tables are not segmented by row volume
+	Unsampled bool
+	// HeightFilter is the condition under which a v2 eth.header_cids row has rows of the table, usually an EXISTS
+	// subquery on the table by header id, or TRUE for the tables with rows at every header; tables without one do not
+	// support auto range detection
+	HeightFilter string
+	// MigratedHeightFilter is the condition under which a v3 eth.header_cids row has rows of the table; without one,
+	// the ranges found in the v2 DB are processed regardless of what the v3 DB holds
+	MigratedHeightFilter string
+
+	// newPipeline is set by NewTypedTableSpec, to process the table with the types of its Transformer
+	newPipeline pipelineConstructor
+}
+
+// NewTypedTableSpec returns the spec with NewReadModels and Transformer set from the constructor of a typed.Transformer,
+// so the models read for the table are always the ones its Transformer takes
+// the table is migrated and exported through the typed.Transformer itself, without going through NewReadModels and Transformer
+func NewTypedTableSpec[In, Out any](spec TableSpec, constructor typed.TransformerConstructor[In, Out]) TableSpec {
+	spec.NewReadModels = func() interface{} { return new([]In) }
+	spec.Transformer = func() interfaces.Transformer { return typed.Adapt(constructor()) }
+	spec.newPipeline = newTypedPipelineConstructor(spec.Name, constructor)
+	return spec
+}
+
+// tableRegistry holds the TableSpecs of the tables that can be processed, keyed by name and by alias
+var tableRegistry = struct {
+	sync.RWMutex
+	specs   map[TableName]TableSpec
+	aliases map[string]TableName
+}{
+	specs:   make(map[TableName]TableSpec),
+	aliases: make(map[string]TableName),
+}
+
+// RegisterTable adds a table to the set of tables that can be migrated, exported, and repaired
+// RegisterTable is meant to be called from an init function, before any Migrator is created; it returns an error if
+// the spec is missing a required field, or if its name or one of its aliases is already taken
+func RegisterTable(spec TableSpec) error {
+	switch {
+	case spec.Name == "":
+		return fmt.Errorf("table spec has no name")
+	case spec.ReadQuery == "":
+		return fmt.Errorf("table spec %s has no read query", spec.Name)
+	case spec.NewReadModels == nil:
+		return fmt.Errorf("table spec %s has no read models constructor", spec.Name)
+	case spec.Transformer == nil:
+		return fmt.Errorf("table spec %s has no transformer constructor", spec.Name)
+	}
+	names := append([]string{string(spec.Name)}, spec.Aliases...)
+	tableRegistry.Lock()
+	defer tableRegistry.Unlock()
+	if _, ok := tableRegistry.specs[spec.Name]; ok {
+		return fmt.Errorf("table %s is already registered", spec.Name)
+	}
+	for _, name := range names {
+		if tableName, ok := tableRegistry.aliases[strings.ToLower(name)]; ok {
+			return fmt.Errorf("table name %s of table %s is already taken by table %s", name, spec.Name, tableName)
+		}
+	}
+	spec.Aliases = append([]string(nil), spec.Aliases...)
+	spec.Dependencies = append([]TableName(nil), spec.Dependencies...)
+	tableRegistry.specs[spec.Name] = spec
+	for _, name := range names {
+		tableRegistry.aliases[strings.ToLower(name)] = spec.Name
+	}
+	return nil
+}
+
+// MustRegisterTable is RegisterTable, but panics on error
+func MustRegisterTable(spec TableSpec) {
+	if err := RegisterTable(spec); err != nil {
+		panic(err)
+	}
+}
+
+// LookupTableSpec returns the TableSpec the table was registered with
+func LookupTableSpec(tableName TableName) (TableSpec, bool) {
+	tableRegistry.RLock()
+	defer tableRegistry.RUnlock()
+	spec, ok := tableRegistry.specs[tableName]
+	return spec, ok
+}
+
+// RegisteredTables returns the names of every registered table, in order
+func RegisteredTables() []TableName {
+	tableRegistry.RLock()
+	defer tableRegistry.RUnlock()
+	tables := make([]TableName, 0, len(tableRegistry.specs))
+	for tableName := range tableRegistry.specs {
+		tables = append(tables, tableName)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i] < tables[j] })
+	return tables
+}
+
+// lookupTableAlias returns the name of the table registered under the provided name or alias
+func lookupTableAlias(name string) (TableName, bool) {
+	tableRegistry.RLock()
+	defer tableRegistry.RUnlock()
+	tableName, ok := tableRegistry.aliases[strings.ToLower(name)]
+	return tableName, ok
+}
@@ -0,0 +1,101 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+// tokenTransferModel is the model of a derived table registered by the specs
+type tokenTransferModel struct {
+	TxHash string `db:"tx_hash"`
+	Amount string `db:"amount"`
+}
+
+// passThroughTransformer returns the models it is given
+type passThroughTransformer struct{}
+
+func (passThroughTransformer) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
+	return *(models.(*[]tokenTransferModel)), nil, nil
+}
+
+var _ = Describe("Table registry", func() {
+	tokenTransfers := migration_tools.TableSpec{
+		Name:          "token_transfers",
+		Aliases:       []string{"custom.token_transfers", "Transfers"},
+		ReadQuery:     "SELECT tx_hash, amount FROM custom.token_transfers WHERE block_number BETWEEN $1 AND $2",
+		NewReadModels: func() interface{} { return new([]tokenTransferModel) },
+		Transformer:   func() interfaces.Transformer { return passThroughTransformer{} },
+		WriteQuery:    "INSERT INTO custom.token_transfers (tx_hash, amount) VALUES (:tx_hash, :amount)",
+		ConflictKey:   sql.ConflictKey{"tx_hash"},
+		Dependencies:  []migration_tools.TableName{migration_tools.EthLogs},
+	}
+
+	It("registers the built-in tables", func() {
+		tables := migration_tools.RegisteredTables()
+		Expect(tables).To(ContainElements(migration_tools.EthHeaders, migration_tools.EthStorage, migration_tools.EthLogsRepair))
+		tableName, err := migration_tools.NewTableNameFromString("eth.transaction_cids")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tableName).To(Equal(migration_tools.EthTransactions))
+		Expect(migration_tools.TableDependencies(migration_tools.EthLogs)).To(Equal([]migration_tools.TableName{migration_tools.EthReceipts}))
+	})
+
+	It("plugs a custom table into the built-in lookups", func() {
+		Expect(migration_tools.RegisterTable(tokenTransfers)).To(Succeed())
+
+		tableName, err := migration_tools.NewTableNameFromString("transfers")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tableName).To(Equal(tokenTransfers.Name))
+
+		models, err := migration_tools.NewTableReadModels(tableName)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(BeAssignableToTypeOf(new([]tokenTransferModel)))
+		Expect(migration_tools.NewTableTransformer(tableName)).To(Equal(passThroughTransformer{}))
+
+		writePgStr, err := migration_tools.NewTableWritePgStr(tableName, sql.ConflictDoNothing)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(writePgStr)).To(HaveSuffix("ON CONFLICT (tx_hash) DO NOTHING"))
+
+		sorted, err := migration_tools.SortTablesByDependency([]migration_tools.TableName{tableName, migration_tools.EthLogs})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sorted).To(Equal([]migration_tools.TableName{migration_tools.EthLogs, tableName}))
+
+		_, err = migration_tools.NewTableCSVWriter(tableName, GinkgoT().TempDir(), 0, 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects specs whose name or aliases are taken", func() {
+		spec := tokenTransfers
+		spec.Name, spec.Aliases = "erc20_transfers", []string{"logs"}
+		Expect(migration_tools.RegisterTable(spec)).To(MatchError(ContainSubstring("already taken by table log_cids")))
+		spec.Name, spec.Aliases = migration_tools.EthHeaders, nil
+		Expect(migration_tools.RegisterTable(spec)).To(MatchError(ContainSubstring("already registered")))
+		_, err := migration_tools.NewTableNameFromString("erc20_transfers")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects specs without a read query, read models, or transformer", func() {
+		spec := tokenTransfers
+		spec.Name, spec.Aliases, spec.Transformer = "erc721_transfers", nil, nil
+		Expect(migration_tools.RegisterTable(spec)).To(MatchError(ContainSubstring("no transformer constructor")))
+	})
+})
@@ -0,0 +1,288 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReportFileExtension is the extension of the JSON Lines report files
+const ReportFileExtension = ".jsonl"
+
+// GapKind is the reason a block range is reported
+type GapKind string
+
+const (
+	// GapRead is a range that could not be read, or that has no rows in the old DB
+	GapRead GapKind = "read"
+	// GapTransform is a range whose rows could not be transformed
+	GapTransform GapKind = "transform"
+	// GapValidate is a range whose transformed rows reference IPLDs that are missing or do not hash to their cid
+	GapValidate GapKind = "validate"
+	// GapWrite is a range that could not be written, or that was skipped because a range it depends on failed
+	GapWrite GapKind = "write"
+	// GapTransfer is a page range of public.blocks that could not be transferred
+	GapTransfer GapKind = "transfer"
+	// GapDuplicate is a height whose rows belong to more than one block hash (i.e. a reorg), its rows are still migrated
+	GapDuplicate GapKind = "duplicate"
+)
+
+// ReportEntry is a single line of a report file
+// Range is a range of block heights, except for transfer entries, where it is a range of pages
+// IPLDs lists the invalid IPLD references of validate entries
+type ReportEntry struct {
+	Time   time.Time      `json:"time"`
+	Table  TableName      `json:"table"`
+	Range  [2]uint64      `json:"range"`
+	Kind   GapKind        `json:"kind"`
+	Error  string         `json:"error,omitempty"`
+	Worker int            `json:"worker,omitempty"`
+	IPLDs  []IPLDMismatch `json:"iplds,omitempty"`
+}
+
+// ReportWriter writes ReportEntries out as JSON Lines
+// ReportWriter is safe for concurrent use, and its methods are no-ops on a nil ReportWriter
+type ReportWriter struct {
+	sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewReportWriter returns a new ReportWriter that writes to w
+func NewReportWriter(w io.Writer) *ReportWriter {
+	rw := &ReportWriter{encoder: json.NewEncoder(w)}
+	if closer, ok := w.(io.Closer); ok {
+		rw.closer = closer
+	}
+	return rw
+}
+
+// NewReportFile returns a new ReportWriter that writes to a new {name}_{unix_timestamp}.jsonl file in the provided directory
+func NewReportFile(dir, name string) (*ReportWriter, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, name+"_"+strconv.Itoa(int(time.Now().Unix()))+ReportFileExtension)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return NewReportWriter(file), nil
+}
+
+// Write writes the entry out as a single line, stamping it with the current time if it has none
+func (w *ReportWriter) Write(entry ReportEntry) error {
+	if w == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	w.Lock()
+	defer w.Unlock()
+	return w.encoder.Encode(entry)
+}
+
+// Close satisfies io.Closer
+func (w *ReportWriter) Close() error {
+	if w == nil || w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
+
+// report writes out an entry for the range of the table to the ReportWriter in use, if any
+func (s *Service) report(tableName TableName, rng [2]uint64, kind GapKind, workerNum int, err error) {
+	if s.reportWriter == nil {
+		return
+	}
+	entry := ReportEntry{
+		Table:  tableName,
+		Range:  rng,
+		Kind:   kind,
+		Worker: workerNum,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		var validationErr *IPLDValidationError
+		if errors.As(err, &validationErr) {
+			entry.IPLDs = validationErr.Mismatches
+		}
+	}
+	if err := s.reportWriter.Write(entry); err != nil {
+		logrus.Errorf("unable to write %s report entry for table %s range (%d, %d): %v", kind, tableName, rng[0], rng[1], err)
+	}
+}
+
+// ReportFiles returns the paths of the report files in the directory, skipping any subdirectories
+func ReportFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ReportFileExtension) {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+	return paths, nil
+}
+
+// reportDuplicates writes out an entry for each height of the table found to hold rows for more than one block hash
+func (s *Service) reportDuplicates(tableName TableName, rng [2]uint64, workerNum int, duplicates []uint64) {
+	if len(duplicates) == 0 {
+		return
+	}
+	logrus.Warnf("table %s worker %d found rows for more than one block hash at %d heights in range (%d, %d)",
+		tableName, workerNum, len(duplicates), rng[0], rng[1])
+	for _, height := range duplicates {
+		s.report(tableName, [2]uint64{height, height}, GapDuplicate, workerNum, nil)
+	}
+}
+
+// ReadReportFile parses the entries of a JSON Lines report file
+func ReadReportFile(path string) ([]ReportEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []ReportEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry ReportEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("unable to parse line %d of report file %s: %v", lineNum, path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// ReportSummary aggregates the report entries of a table
+// Blocks counts the distinct heights (or pages, for transfers) reported for each kind, so ranges reported by more
+// than one run are only counted once
+type ReportSummary struct {
+	Table    TableName
+	Entries  map[GapKind]int
+	Blocks   map[GapKind]uint64
+	Errors   int
+	Workers  int
+	Runs     int
+	First    time.Time
+	Last     time.Time
+	ranges   map[GapKind][][2]uint64
+	workers  map[int]struct{}
+	runFiles map[string]struct{}
+}
+
+// SummarizeReports aggregates the entries of the provided report files into a ReportSummary per table,
+// ordered by table name
+func SummarizeReports(paths []string) ([]*ReportSummary, error) {
+	summaries := make(map[TableName]*ReportSummary)
+	for _, path := range paths {
+		entries, err := ReadReportFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			summary, ok := summaries[entry.Table]
+			if !ok {
+				summary = &ReportSummary{
+					Table:    entry.Table,
+					Entries:  make(map[GapKind]int),
+					Blocks:   make(map[GapKind]uint64),
+					ranges:   make(map[GapKind][][2]uint64),
+					workers:  make(map[int]struct{}),
+					runFiles: make(map[string]struct{}),
+				}
+				summaries[entry.Table] = summary
+			}
+			summary.Entries[entry.Kind]++
+			summary.ranges[entry.Kind] = append(summary.ranges[entry.Kind], entry.Range)
+			if entry.Error != "" {
+				summary.Errors++
+			}
+			if entry.Worker != 0 {
+				summary.workers[entry.Worker] = struct{}{}
+			}
+			summary.runFiles[path] = struct{}{}
+			if summary.First.IsZero() || entry.Time.Before(summary.First) {
+				summary.First = entry.Time
+			}
+			if entry.Time.After(summary.Last) {
+				summary.Last = entry.Time
+			}
+		}
+	}
+	sorted := make([]*ReportSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		for kind, ranges := range summary.ranges {
+			for _, rng := range MergeRanges(ranges) {
+				summary.Blocks[kind] += rng[1] - rng[0] + 1
+			}
+		}
+		summary.Workers = len(summary.workers)
+		summary.Runs = len(summary.runFiles)
+		sorted = append(sorted, summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Table < sorted[j].Table })
+	return sorted, nil
+}
+
+// reportKinds are the columns of the summary table, in order
+var reportKinds = []GapKind{GapRead, GapTransform, GapValidate, GapWrite, GapTransfer, GapDuplicate}
+
+// WriteReportSummaries writes the summaries out to w as a table, with the number of entries and of distinct blocks
+// reported for each kind
+func WriteReportSummaries(w io.Writer, summaries []*ReportSummary) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "table\truns\t")
+	for _, kind := range reportKinds {
+		fmt.Fprintf(tw, "%s entries\t%s blocks\t", kind, kind)
+	}
+	fmt.Fprintln(tw, "errors\tworkers\tfirst\tlast\t")
+	for _, summary := range summaries {
+		fmt.Fprintf(tw, "%s\t%d\t", summary.Table, summary.Runs)
+		for _, kind := range reportKinds {
+			fmt.Fprintf(tw, "%d\t%d\t", summary.Entries[kind], summary.Blocks[kind])
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%s\t\n", summary.Errors, summary.Workers,
+			summary.First.Format(time.RFC3339), summary.Last.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
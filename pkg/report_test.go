@@ -0,0 +1,146 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+var _ = Describe("Report files", Serial, func() {
+	var reportDir string
+	BeforeEach(func() {
+		reportDir, err = os.MkdirTemp("", "reports")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		Expect(os.RemoveAll(reportDir)).To(Succeed())
+	})
+
+	writeReport := func(path string, entries ...migration_tools.ReportEntry) {
+		file, err := os.Create(path)
+		Expect(err).ToNot(HaveOccurred())
+		writer := migration_tools.NewReportWriter(file)
+		for _, entry := range entries {
+			Expect(writer.Write(entry)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+	}
+
+	It("writes an entry per line, and reads them back", func() {
+		buf := new(bytes.Buffer)
+		writer := migration_tools.NewReportWriter(buf)
+		Expect(writer.Write(migration_tools.ReportEntry{
+			Table:  migration_tools.EthHeaders,
+			Range:  [2]uint64{0, 9},
+			Kind:   migration_tools.GapRead,
+			Error:  "connection reset",
+			Worker: 2,
+		})).To(Succeed())
+		Expect(writer.Write(migration_tools.ReportEntry{
+			Table: migration_tools.EthHeaders,
+			Range: [2]uint64{10, 19},
+			Kind:  migration_tools.GapWrite,
+		})).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(ContainSubstring(`"table":"header_cids","range":[0,9],"kind":"read","error":"connection reset","worker":2`))
+		Expect(lines[1]).ToNot(ContainSubstring(`"error"`))
+
+		path := filepath.Join(reportDir, "migrate_1"+migration_tools.ReportFileExtension)
+		Expect(os.WriteFile(path, buf.Bytes(), 0644)).To(Succeed())
+		entries, err := migration_tools.ReadReportFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Table).To(Equal(migration_tools.EthHeaders))
+		Expect(entries[0].Range).To(Equal([2]uint64{0, 9}))
+		Expect(entries[0].Worker).To(Equal(2))
+		Expect(entries[0].Time.IsZero()).To(BeFalse())
+		Expect(entries[1].Kind).To(Equal(migration_tools.GapWrite))
+	})
+
+	It("is a no-op when nil", func() {
+		var writer *migration_tools.ReportWriter
+		Expect(writer.Write(migration_tools.ReportEntry{})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+	})
+
+	It("lists the report files of a directory, skipping other files and subdirectories", func() {
+		Expect(os.WriteFile(filepath.Join(reportDir, "migrate_1.jsonl"), nil, 0644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(reportDir, "header_cids_1"), nil, 0644)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(reportDir, "dryRun"), 0777)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(reportDir, "dryRun", "migrate_2.jsonl"), nil, 0644)).To(Succeed())
+		paths, err := migration_tools.ReportFiles(reportDir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(paths).To(Equal([]string{filepath.Join(reportDir, "migrate_1.jsonl")}))
+	})
+
+	It("summarizes the entries of every run per table, counting overlapping ranges once", func() {
+		first := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+		firstRun := filepath.Join(reportDir, "migrate_1.jsonl")
+		secondRun := filepath.Join(reportDir, "retry-gaps_2.jsonl")
+		writeReport(firstRun,
+			migration_tools.ReportEntry{Time: first, Table: migration_tools.EthState, Range: [2]uint64{0, 9}, Kind: migration_tools.GapRead, Error: "timeout", Worker: 1},
+			migration_tools.ReportEntry{Time: first, Table: migration_tools.EthState, Range: [2]uint64{20, 29}, Kind: migration_tools.GapWrite, Error: "conflict", Worker: 2},
+			migration_tools.ReportEntry{Time: first, Table: migration_tools.EthHeaders, Range: [2]uint64{5, 5}, Kind: migration_tools.GapRead, Worker: 1},
+			migration_tools.ReportEntry{Time: first, Table: migration_tools.EthHeaders, Range: [2]uint64{7, 7}, Kind: migration_tools.GapDuplicate, Worker: 1},
+		)
+		writeReport(secondRun,
+			migration_tools.ReportEntry{Time: first.Add(time.Hour), Table: migration_tools.EthState, Range: [2]uint64{5, 14}, Kind: migration_tools.GapRead, Error: "timeout", Worker: 1},
+		)
+
+		summaries, err := migration_tools.SummarizeReports([]string{firstRun, secondRun})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(summaries).To(HaveLen(2))
+		Expect(summaries[0].Table).To(Equal(migration_tools.EthHeaders))
+		Expect(summaries[0].Runs).To(Equal(1))
+		Expect(summaries[0].Errors).To(Equal(0))
+		Expect(summaries[0].Blocks[migration_tools.GapDuplicate]).To(Equal(uint64(1)))
+
+		state := summaries[1]
+		Expect(state.Table).To(Equal(migration_tools.EthState))
+		Expect(state.Runs).To(Equal(2))
+		Expect(state.Entries[migration_tools.GapRead]).To(Equal(2))
+		Expect(state.Blocks[migration_tools.GapRead]).To(Equal(uint64(15)))
+		Expect(state.Entries[migration_tools.GapWrite]).To(Equal(1))
+		Expect(state.Blocks[migration_tools.GapWrite]).To(Equal(uint64(10)))
+		Expect(state.Errors).To(Equal(3))
+		Expect(state.Workers).To(Equal(2))
+		Expect(state.First).To(Equal(first))
+		Expect(state.Last).To(Equal(first.Add(time.Hour)))
+
+		buf := new(bytes.Buffer)
+		Expect(migration_tools.WriteReportSummaries(buf, summaries)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("state_cids"))
+		Expect(buf.String()).To(ContainSubstring("duplicate blocks"))
+	})
+
+	It("rejects malformed lines", func() {
+		path := filepath.Join(reportDir, "migrate_1.jsonl")
+		Expect(os.WriteFile(path, []byte("0, 9\r\n"), 0644)).To(Succeed())
+		_, err := migration_tools.ReadReportFile(path)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,208 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// TableDependencies returns the tables that the provided table depends on
+func TableDependencies(tableName TableName) []TableName {
+	spec, _ := LookupTableSpec(tableName)
+	return spec.Dependencies
+}
+
+// SortTablesByDependency returns the provided tables ordered such that every table comes after the tables it depends on
+// dependencies that are not in the provided set are ignored
+func SortTablesByDependency(tables []TableName) ([]TableName, error) {
+	inSet := make(map[TableName]bool, len(tables))
+	for _, table := range tables {
+		inSet[table] = true
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[TableName]int, len(tables))
+	sorted := make([]TableName, 0, len(tables))
+	var visit func(table TableName) error
+	visit = func(table TableName) error {
+		switch state[table] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle detected at table %s", table)
+		}
+		state[table] = visiting
+		for _, parent := range TableDependencies(table) {
+			if !inSet[parent] {
+				continue
+			}
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[table] = visited
+		sorted = append(sorted, table)
+		return nil
+	}
+	for _, table := range tables {
+		if err := visit(table); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// errSchedulerQuit is returned by Scheduler.Wait when the wait is abandoned through the quit channel
+var errSchedulerQuit = errors.New("quit while waiting on parent tables")
+
+// errUnsettled settles the ranges that are left pending once the process of their table has shut down
+var errUnsettled = errors.New("process quit before the range was migrated")
+
+// scheduledRange tracks the outcome of a block range for a table
+type scheduledRange struct {
+	rng      [2]uint64
+	err      error
+	doneChan chan struct{}
+}
+
+// Scheduler orders the migration of tables by their dependencies, one block range at a time
+// a block range is only migrated for a table once every overlapping pending range of its parent tables has settled
+// and none of them has failed, so that the new DB can be written to with its foreign key constraints enabled
+type Scheduler struct {
+	sync.Mutex
+	parents map[TableName][]TableName
+	ranges  map[TableName][]*scheduledRange
+}
+
+// NewScheduler returns a new Scheduler for the provided tables and the block ranges that are pending for each of them
+// parents that are not being migrated, or that have no pending ranges, are assumed to already be in the new DB
+func NewScheduler(tableRanges map[TableName][][2]uint64) (*Scheduler, error) {
+	tables := make([]TableName, 0, len(tableRanges))
+	for table := range tableRanges {
+		tables = append(tables, table)
+	}
+	if _, err := SortTablesByDependency(tables); err != nil {
+		return nil, err
+	}
+	s := &Scheduler{
+		parents: make(map[TableName][]TableName, len(tableRanges)),
+		ranges:  make(map[TableName][]*scheduledRange, len(tableRanges)),
+	}
+	for table, ranges := range tableRanges {
+		for _, parent := range TableDependencies(table) {
+			if _, ok := tableRanges[parent]; ok {
+				s.parents[table] = append(s.parents[table], parent)
+			}
+		}
+		if table == PublicNodes && len(ranges) > 0 {
+			// public nodes are migrated in one batch with the first range, since they are not segmented by block height
+			ranges = ranges[:1]
+		}
+		for _, rng := range ranges {
+			s.ranges[table] = append(s.ranges[table], &scheduledRange{rng: rng, doneChan: make(chan struct{})})
+		}
+	}
+	return s, nil
+}
+
+// Wait blocks until the provided block range can be migrated for the provided table
+// it returns an error if any of the parent ranges it waited on failed, or if ctx is done or quit is closed first
+// Wait is a no-op on a nil Scheduler
+func (s *Scheduler) Wait(ctx context.Context, quit <-chan struct{}, tableName TableName, rng [2]uint64) error {
+	if s == nil {
+		return nil
+	}
+	for _, parent := range s.parents[tableName] {
+		for _, parentRange := range s.blocking(parent, rng) {
+			select {
+			case <-parentRange.doneChan:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-quit:
+				return errSchedulerQuit
+			}
+			if parentRange.err != nil {
+				return fmt.Errorf("parent table %s failed range (%d, %d): %v", parent, parentRange.rng[0], parentRange.rng[1], parentRange.err)
+			}
+		}
+	}
+	return nil
+}
+
+// blocking returns the pending ranges of the parent table that a child range has to wait on
+func (s *Scheduler) blocking(parent TableName, rng [2]uint64) []*scheduledRange {
+	if parent == PublicNodes {
+		return s.ranges[parent]
+	}
+	var blocking []*scheduledRange
+	for _, parentRange := range s.ranges[parent] {
+		if parentRange.rng[0] <= rng[1] && rng[0] <= parentRange.rng[1] {
+			blocking = append(blocking, parentRange)
+		}
+	}
+	return blocking
+}
+
+// Settle records the outcome of a block range for a table, releasing the child ranges waiting on it
+// a nil err means the range was committed, any other value fails the waiting child ranges
+// Settle is a no-op on a nil Scheduler, and for ranges it was not constructed with
+func (s *Scheduler) Settle(tableName TableName, rng [2]uint64, err error) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, scheduled := range s.ranges[tableName] {
+		if scheduled.rng != rng {
+			continue
+		}
+		select {
+		case <-scheduled.doneChan:
+			// already settled
+		default:
+			scheduled.err = err
+			close(scheduled.doneChan)
+		}
+		return
+	}
+}
+
+// SettleRemaining fails every range of the table that has not been settled yet with the provided err, so that the child
+// ranges waiting on them fail instead of waiting on a process that has shut down
+// SettleRemaining is a no-op on a nil Scheduler
+func (s *Scheduler) SettleRemaining(tableName TableName, err error) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, scheduled := range s.ranges[tableName] {
+		select {
+		case <-scheduled.doneChan:
+			// already settled
+		default:
+			scheduled.err = err
+			close(scheduled.doneChan)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+var _ = Describe("Scheduler", func() {
+	indexOf := func(tables []migration_tools.TableName, table migration_tools.TableName) int {
+		for i, t := range tables {
+			if t == table {
+				return i
+			}
+		}
+		return -1
+	}
+
+	It("sorts tables after the tables they depend on", func() {
+		tables, err := migration_tools.SortTablesByDependency([]migration_tools.TableName{
+			migration_tools.EthLogs,
+			migration_tools.EthStorage,
+			migration_tools.EthReceipts,
+			migration_tools.EthState,
+			migration_tools.EthTransactions,
+			migration_tools.EthHeaders,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tables).To(HaveLen(6))
+		Expect(indexOf(tables, migration_tools.EthHeaders)).To(BeNumerically("<", indexOf(tables, migration_tools.EthTransactions)))
+		Expect(indexOf(tables, migration_tools.EthTransactions)).To(BeNumerically("<", indexOf(tables, migration_tools.EthReceipts)))
+		Expect(indexOf(tables, migration_tools.EthReceipts)).To(BeNumerically("<", indexOf(tables, migration_tools.EthLogs)))
+		Expect(indexOf(tables, migration_tools.EthHeaders)).To(BeNumerically("<", indexOf(tables, migration_tools.EthState)))
+		Expect(indexOf(tables, migration_tools.EthState)).To(BeNumerically("<", indexOf(tables, migration_tools.EthStorage)))
+	})
+
+	It("only waits on overlapping ranges of parent tables once they have settled", func() {
+		scheduler, err := migration_tools.NewScheduler(map[migration_tools.TableName][][2]uint64{
+			migration_tools.EthHeaders:      {{0, 9}, {10, 19}},
+			migration_tools.EthTransactions: {{0, 9}, {10, 19}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		ctx := context.Background()
+		quit := make(chan struct{})
+
+		waited := make(chan error)
+		go func() {
+			waited <- scheduler.Wait(ctx, quit, migration_tools.EthTransactions, [2]uint64{10, 19})
+		}()
+		Consistently(waited).ShouldNot(Receive())
+		scheduler.Settle(migration_tools.EthHeaders, [2]uint64{0, 9}, nil)
+		Consistently(waited).ShouldNot(Receive())
+		scheduler.Settle(migration_tools.EthHeaders, [2]uint64{10, 19}, nil)
+		Eventually(waited).Should(Receive(BeNil()))
+
+		// headers do not depend on any of the scheduled tables
+		Expect(scheduler.Wait(ctx, quit, migration_tools.EthHeaders, [2]uint64{20, 29})).To(Succeed())
+	})
+
+	It("fails the child range when the parent range failed", func() {
+		scheduler, err := migration_tools.NewScheduler(map[migration_tools.TableName][][2]uint64{
+			migration_tools.EthReceipts: {{0, 9}},
+			migration_tools.EthLogs:     {{0, 9}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		scheduler.Settle(migration_tools.EthReceipts, [2]uint64{0, 9}, errors.New("mock write error"))
+		err = scheduler.Wait(context.Background(), make(chan struct{}), migration_tools.EthLogs, [2]uint64{0, 9})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("mock write error"))
+	})
+
+	It("fails the child ranges once the parent process has shut down without settling them", func() {
+		scheduler, err := migration_tools.NewScheduler(map[migration_tools.TableName][][2]uint64{
+			migration_tools.EthHeaders:      {{0, 9}, {10, 19}},
+			migration_tools.EthTransactions: {{0, 9}, {10, 19}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		ctx := context.Background()
+		quit := make(chan struct{})
+		scheduler.Settle(migration_tools.EthHeaders, [2]uint64{0, 9}, nil)
+
+		waited := make(chan error)
+		go func() {
+			waited <- scheduler.Wait(ctx, quit, migration_tools.EthTransactions, [2]uint64{10, 19})
+		}()
+		Consistently(waited).ShouldNot(Receive())
+		scheduler.SettleRemaining(migration_tools.EthHeaders, errors.New("mock shutdown"))
+		Eventually(waited).Should(Receive(MatchError(ContainSubstring("mock shutdown"))))
+
+		// the ranges settled before the shutdown keep their outcome
+		Expect(scheduler.Wait(ctx, quit, migration_tools.EthTransactions, [2]uint64{0, 9})).To(Succeed())
+	})
+
+	It("stops waiting once the context is done", func() {
+		scheduler, err := migration_tools.NewScheduler(map[migration_tools.TableName][][2]uint64{
+			migration_tools.EthState:   {{0, 9}},
+			migration_tools.EthStorage: {{0, 9}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(scheduler.Wait(ctx, make(chan struct{}), migration_tools.EthStorage, [2]uint64{0, 9})).To(MatchError(context.Canceled))
+	})
+
+	It("never waits when no Scheduler is in use", func() {
+		var scheduler *migration_tools.Scheduler
+		Expect(scheduler.Wait(context.Background(), nil, migration_tools.EthLogs, [2]uint64{0, 9})).To(Succeed())
+		scheduler.Settle(migration_tools.EthLogs, [2]uint64{0, 9}, nil)
+	})
+})
@@ -0,0 +1,226 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// segmenterSamplesPerRange is the number of heights sampled across each range passed to AdaptiveSegmenter.Sample
+	segmenterSamplesPerRange = 16
+	// segmenterSampleWindow is the number of blocks counted at each sampled height
+	segmenterSampleWindow = 10
+	// segmenterSmoothing is the weight given to the latest observed latency when correcting the row target
+	segmenterSmoothing = 0.5
+	// segmenterMaxCorrection bounds the latency correction of the row target, in either direction
+	segmenterMaxCorrection = 64
+)
+
+// RangeObserver is notified of the number of rows read, and the time taken to process them, for every block range
+// that a Migrator completes
+type RangeObserver interface {
+	Observe(tableName TableName, rng [2]uint64, rows int, latency time.Duration)
+}
+
+// densitySample is the number of rows per block of a table around a block height
+type densitySample struct {
+	height       uint64
+	rowsPerBlock float64
+}
+
+// tableDensity tracks the row density of a table across block heights
+type tableDensity struct {
+	samples           []densitySample // ordered by height
+	latencyCorrection float64
+}
+
+// AdaptiveSegmenter sizes block ranges so that each one holds roughly the same number of rows of its table
+// the row density of a table is first sampled across the configured ranges by counting its rows over a few blocks at
+// evenly spaced heights, and is then refined with the rows of every range observed as completed
+// if a target latency is set, the row target of a table is scaled up or down by how long its ranges take to process
+type AdaptiveSegmenter struct {
+	sync.Mutex
+	targetRows     uint64
+	targetLatency  time.Duration
+	maxSegmentSize uint64
+	tables         map[TableName]*tableDensity
+}
+
+// NewAdaptiveSegmenter returns a new AdaptiveSegmenter
+// ranges are sized to hold targetRows rows, up to maxSegmentSize blocks; a maxSegmentSize of 0 leaves them unbounded
+// a targetLatency of 0 turns off the latency correction
+func NewAdaptiveSegmenter(targetRows uint64, targetLatency time.Duration, maxSegmentSize uint64) (*AdaptiveSegmenter, error) {
+	if targetRows == 0 {
+		return nil, fmt.Errorf("adaptive segmenter needs a target number of rows per range")
+	}
+	return &AdaptiveSegmenter{
+		targetRows:     targetRows,
+		targetLatency:  targetLatency,
+		maxSegmentSize: maxSegmentSize,
+		tables:         make(map[TableName]*tableDensity),
+	}, nil
+}
+
+// Sample counts the rows of the table in the provided v2 DB at evenly spaced heights across the provided block range
+func (s *AdaptiveSegmenter) Sample(db *sqlx.DB, tableName TableName, rng [2]uint64) error {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok {
+		return fmt.Errorf("no read statement for table %s", tableName)
+	}
+	if spec.Unsampled {
+		return nil
+	}
+	countPgStr := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS sample", spec.ReadQuery)
+	blocks := rng[1] - rng[0] + 1
+	numSamples := blocks / segmenterSampleWindow
+	if numSamples > segmenterSamplesPerRange {
+		numSamples = segmenterSamplesPerRange
+	}
+	if numSamples == 0 {
+		numSamples = 1
+	}
+	step := blocks / numSamples
+	samples := make([]densitySample, 0, numSamples)
+	for i := uint64(0); i < numSamples; i++ {
+		start := rng[0] + i*step
+		stop := start + segmenterSampleWindow - 1
+		if stop > rng[1] {
+			stop = rng[1]
+		}
+		var count uint64
+		if err := db.Get(&count, countPgStr, start, stop); err != nil {
+			return fmt.Errorf("unable to sample table %s at height %d: %v", tableName, start, err)
+		}
+		samples = append(samples, densitySample{height: start, rowsPerBlock: float64(count) / float64(stop-start+1)})
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.table(tableName).add(samples...)
+	return nil
+}
+
+// Next returns the next block range of the table, starting at start and ending at stop at the latest
+func (s *AdaptiveSegmenter) Next(tableName TableName, start, stop uint64) [2]uint64 {
+	s.Lock()
+	defer s.Unlock()
+	size := stop - start + 1
+	if s.maxSegmentSize != 0 && s.maxSegmentSize < size {
+		size = s.maxSegmentSize
+	}
+	density := s.table(tableName)
+	if rowsPerBlock, ok := density.estimate(start); ok && rowsPerBlock > 0 {
+		fit := uint64(float64(s.targetRows) * density.latencyCorrection / rowsPerBlock)
+		if fit == 0 {
+			fit = 1
+		}
+		if fit < size {
+			size = fit
+		}
+	}
+	// the density can change sharply from one sample to the next, so a range never runs past the next sampled height
+	if next, ok := density.nextHeight(start); ok && next-start < size {
+		size = next - start
+	}
+	return [2]uint64{start, start + size - 1}
+}
+
+// Observe satisfies RangeObserver
+// Observe replaces the sampled density within the range with the observed one, and corrects the row target of the table
+// by how far the latency of the range was from the target latency
+func (s *AdaptiveSegmenter) Observe(tableName TableName, rng [2]uint64, rows int, latency time.Duration) {
+	if spec, _ := LookupTableSpec(tableName); spec.Unsampled {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	density := s.table(tableName)
+	kept := density.samples[:0]
+	for _, sample := range density.samples {
+		if sample.height < rng[0] || sample.height > rng[1] {
+			kept = append(kept, sample)
+		}
+	}
+	density.samples = kept
+	density.add(densitySample{height: rng[0], rowsPerBlock: float64(rows) / float64(rng[1]-rng[0]+1)})
+	if s.targetLatency <= 0 || latency <= 0 || rows == 0 {
+		return
+	}
+	// the correction at which a range of this table would be processed in the target latency
+	ideal := float64(rows) * float64(s.targetLatency) / float64(latency) / float64(s.targetRows)
+	correction := math.Pow(density.latencyCorrection, 1-segmenterSmoothing) * math.Pow(ideal, segmenterSmoothing)
+	density.latencyCorrection = math.Max(1/float64(segmenterMaxCorrection), math.Min(segmenterMaxCorrection, correction))
+	logrus.Debugf("table %s range (%d, %d) processed %d rows in %s, row target corrected to %.0f",
+		tableName, rng[0], rng[1], rows, latency, float64(s.targetRows)*density.latencyCorrection)
+}
+
+// table returns the tracked density of the table, the lock has to be held
+func (s *AdaptiveSegmenter) table(tableName TableName) *tableDensity {
+	density, ok := s.tables[tableName]
+	if !ok {
+		density = &tableDensity{latencyCorrection: 1}
+		s.tables[tableName] = density
+	}
+	return density
+}
+
+// add merges the samples into the density, replacing any existing sample at the same height
+func (d *tableDensity) add(samples ...densitySample) {
+	for _, sample := range samples {
+		i := sort.Search(len(d.samples), func(i int) bool { return d.samples[i].height >= sample.height })
+		if i < len(d.samples) && d.samples[i].height == sample.height {
+			d.samples[i] = sample
+			continue
+		}
+		d.samples = append(d.samples, densitySample{})
+		copy(d.samples[i+1:], d.samples[i:])
+		d.samples[i] = sample
+	}
+}
+
+// estimate returns the rows per block at the height, interpolated between the samples on either side of it
+func (d *tableDensity) estimate(height uint64) (float64, bool) {
+	if len(d.samples) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(d.samples), func(i int) bool { return d.samples[i].height > height })
+	switch {
+	case i == 0:
+		return d.samples[0].rowsPerBlock, true
+	case i == len(d.samples):
+		return d.samples[i-1].rowsPerBlock, true
+	}
+	below, above := d.samples[i-1], d.samples[i]
+	weight := float64(height-below.height) / float64(above.height-below.height)
+	return below.rowsPerBlock + weight*(above.rowsPerBlock-below.rowsPerBlock), true
+}
+
+// nextHeight returns the height of the first sample above the height
+func (d *tableDensity) nextHeight(height uint64) (uint64, bool) {
+	i := sort.Search(len(d.samples), func(i int) bool { return d.samples[i].height > height })
+	if i == len(d.samples) {
+		return 0, false
+	}
+	return d.samples[i].height, true
+}
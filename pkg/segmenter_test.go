@@ -0,0 +1,70 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+)
+
+var _ = Describe("AdaptiveSegmenter", func() {
+	It("requires a row target", func() {
+		_, err := migration_tools.NewAdaptiveSegmenter(0, 0, 0)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("falls back to the max segment size for tables without any row density", func() {
+		segmenter, err := migration_tools.NewAdaptiveSegmenter(100, 0, 1000)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(segmenter.Next(migration_tools.EthStorage, 0, 9999)).To(Equal([2]uint64{0, 999}))
+		Expect(segmenter.Next(migration_tools.EthStorage, 9500, 9999)).To(Equal([2]uint64{9500, 9999}))
+	})
+
+	It("sizes ranges by the observed row density", func() {
+		segmenter, err := migration_tools.NewAdaptiveSegmenter(100, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		segmenter.Observe(migration_tools.EthStorage, [2]uint64{0, 99}, 1000, time.Second)
+		Expect(segmenter.Next(migration_tools.EthStorage, 100, 9999)).To(Equal([2]uint64{100, 109}))
+
+		// a denser range replaces the density observed before it
+		segmenter.Observe(migration_tools.EthStorage, [2]uint64{100, 109}, 500, time.Second)
+		Expect(segmenter.Next(migration_tools.EthStorage, 110, 9999)).To(Equal([2]uint64{110, 111}))
+
+		// densities are tracked per table
+		Expect(segmenter.Next(migration_tools.EthState, 110, 9999)).To(Equal([2]uint64{110, 9999}))
+	})
+
+	It("never runs a range past the next known density", func() {
+		segmenter, err := migration_tools.NewAdaptiveSegmenter(100, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		segmenter.Observe(migration_tools.EthState, [2]uint64{0, 0}, 0, time.Second)
+		segmenter.Observe(migration_tools.EthState, [2]uint64{50, 50}, 0, time.Second)
+		Expect(segmenter.Next(migration_tools.EthState, 10, 9999)).To(Equal([2]uint64{10, 49}))
+	})
+
+	It("scales the row target by the observed latency", func() {
+		segmenter, err := migration_tools.NewAdaptiveSegmenter(100, time.Second, 0)
+		Expect(err).ToNot(HaveOccurred())
+		// 100 rows took four times the target latency, so the target is halved with the smoothing
+		segmenter.Observe(migration_tools.EthStorage, [2]uint64{0, 9}, 100, 4*time.Second)
+		Expect(segmenter.Next(migration_tools.EthStorage, 10, 9999)).To(Equal([2]uint64{10, 14}))
+	})
+})
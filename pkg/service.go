@@ -18,79 +18,224 @@ package migration_tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"reflect"
 	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/migration-tools/pkg/csv"
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/metrics"
 	"github.com/vulcanize/migration-tools/pkg/public_blocks"
 	"github.com/vulcanize/migration-tools/pkg/sql"
+	"github.com/vulcanize/migration-tools/pkg/util"
 )
 
 const defaultNumWorkersPerTable = 1
 
+// maxTransferRetryBackoff caps the exponential backoff between retries of a failed public.blocks page segment
+const maxTransferRetryBackoff = 5 * time.Minute
+
 // Migrator interface for migrating from v2 DB to v3 DB
 type Migrator interface {
-	Migrate(wg *sync.WaitGroup, tableName TableName, blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan struct{}, chan error)
-	Transfer(wg *sync.WaitGroup, fdwTableName string, segmentSize, segmentOffset, maxPage uint64) (chan [2]uint64, chan struct{}, chan error, error)
-	TransformToCSV(csvWriter csv.Writer, wg *sync.WaitGroup, tableName TableName, blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan struct{}, chan error)
+	Migrate(ctx context.Context, wg *sync.WaitGroup, tableName TableName, blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error)
+	Transfer(ctx context.Context, wg *sync.WaitGroup, fdwTableName string, segmentSize, segmentOffset, maxPage uint64) (chan [2]uint64, chan struct{}, chan error, error)
+	TransformToCSV(ctx context.Context, csvWriter csv.Writer, wg *sync.WaitGroup, tableName TableName, blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error)
+	CommittedRanges(tableName TableName) ([][2]uint64, error)
+	DetectRanges(tableName TableName, bounds [2]uint64) ([][2]uint64, error)
+	SampleRanges(segmenter *AdaptiveSegmenter, tableName TableName, ranges [][2]uint64) error
+	DryRunReport() *DryRunReport
+	UseReportWriter(reportWriter *ReportWriter)
+	UseScheduler(scheduler *Scheduler)
+	UseRangeObserver(observer RangeObserver)
 	io.Closer
 }
 
 // Service struct underpinning the Migrator interface
 type Service struct {
 	reader       *Reader
-	writer       *sql.Writer
+	writers      map[TableName]interfaces.Writer
 	oldDB, newDB *sqlx.DB
+	checkpointer *Checkpointer
+	scheduler    *Scheduler
+	observer     RangeObserver
+	dryRun       *DryRunReport
+	validator    *IPLDValidator
+	reportWriter *ReportWriter
+	writePgStrs  map[TableName]sql.WritePgStr
+
+	canonicalizer      *Canonicalizer
+	nonCanonicalReport io.Closer
 
 	wg                 *sync.WaitGroup
 	closeChan          chan struct{}
 	numWorkersPerTable int
+
+	transferRetries      int
+	transferRetryBackoff time.Duration
+	transferMode         public_blocks.TransferMode
+	blocksWriter         interfaces.Writer
 }
 
 // NewMigrator returns a new Migrator from the given Config
-func NewMigrator(ctx context.Context, conf *Config) (Migrator, error) {
-	readDB, err := NewDB(ctx, conf.ReadDB)
+// every connection and file opened along the way is closed again if it returns an error
+func NewMigrator(ctx context.Context, conf *Config) (_ Migrator, err error) {
+	validationSource, err := NewIPLDSourceFromString(string(conf.ValidateIPLDsSource))
 	if err != nil {
 		return nil, err
 	}
-	writeDB, err := NewDB(ctx, conf.WriteDB)
+	if conf.ValidateIPLDs && validationSource == IPLDSourceNew && (conf.ReadOnly || conf.DryRun) {
+		return nil, errors.New("IPLDs can only be validated against the new DB by a Migrator that connects to it")
+	}
+	readDB, err := NewDB(ctx, conf.ReadDB)
 	if err != nil {
 		return nil, err
 	}
+	var writeDB *sqlx.DB
+	var nonCanonicalReport io.Closer
+	defer func() {
+		if err == nil {
+			return
+		}
+		if nonCanonicalReport != nil {
+			nonCanonicalReport.Close()
+		}
+		if writeDB != nil {
+			writeDB.Close()
+		}
+		readDB.Close()
+	}()
 	numWorkers := defaultNumWorkersPerTable
 	if conf.WorkersPerTable != 0 {
 		numWorkers = conf.WorkersPerTable
 	}
+	var canonicalizer *Canonicalizer
+	if conf.CanonicalOnly {
+		report, err := newNonCanonicalReport(conf.NonCanonicalDir)
+		if err != nil {
+			return nil, err
+		}
+		canonicalizer, nonCanonicalReport = NewCanonicalizer(readDB, report), report
+	}
+	var dryRun *DryRunReport
+	if conf.DryRun {
+		dryRun = NewDryRunReport()
+	}
+	var validator *IPLDValidator
+	if conf.ValidateIPLDs && validationSource == IPLDSourceOld {
+		validator = NewIPLDValidator(public_blocks.NewFetcher(readDB), readDB.Mapper)
+	}
+	if conf.ReadOnly || conf.DryRun {
+		tables := RegisteredTables()
+		writers := make(map[TableName]interfaces.Writer, len(tables))
+		for _, tableName := range tables {
+			writers[tableName] = readOnlyWriter{}
+		}
+		return &Service{
+			reader:             NewReader(readDB),
+			writers:            writers,
+			oldDB:              readDB,
+			writePgStrs:        make(map[TableName]sql.WritePgStr),
+			dryRun:             dryRun,
+			validator:          validator,
+			canonicalizer:      canonicalizer,
+			nonCanonicalReport: nonCanonicalReport,
+			closeChan:          make(chan struct{}),
+			numWorkersPerTable: numWorkers,
+		}, nil
+	}
+	writeDB, err = NewDB(ctx, conf.WriteDB)
+	if err != nil {
+		return nil, err
+	}
+	if conf.ValidateIPLDs && validationSource == IPLDSourceNew {
+		validator = NewIPLDValidator(public_blocks.NewFetcher(writeDB), writeDB.Mapper)
+	}
+	var checkpointer *Checkpointer
+	if conf.Checkpoint {
+		checkpointer, err = NewCheckpointer(writeDB)
+		if err != nil {
+			return nil, err
+		}
+	}
+	insertWriter, copyWriter := sql.NewWriter(writeDB), sql.NewCopyWriter(writeDB)
+	transferMode, err := public_blocks.NewTransferModeFromString(string(conf.TransferMode))
+	if err != nil {
+		return nil, err
+	}
+	defaultWriteMode, err := sql.NewWriteModeFromString(string(conf.WriteMode))
+	if err != nil {
+		return nil, err
+	}
+	var blocksWriter interfaces.Writer = insertWriter
+	if defaultWriteMode == sql.WriteModeCopy {
+		blocksWriter = copyWriter
+	}
+	tables := RegisteredTables()
+	writers := make(map[TableName]interfaces.Writer, len(tables))
+	writePgStrs := make(map[TableName]sql.WritePgStr, len(tables))
+	for _, tableName := range tables {
+		if spec, _ := LookupTableSpec(tableName); spec.WriteQuery == "" {
+			continue
+		}
+		mode, err := sql.NewWriteModeFromString(string(conf.TableWriteMode(tableName)))
+		if err != nil {
+			return nil, err
+		}
+		if mode == sql.WriteModeCopy {
+			writers[tableName] = copyWriter
+		} else {
+			writers[tableName] = insertWriter
+		}
+		policy, err := sql.NewConflictPolicyFromString(string(conf.TableConflictPolicy(tableName)))
+		if err != nil {
+			return nil, err
+		}
+		writePgStrs[tableName], err = NewTableWritePgStr(tableName, policy)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &Service{
 		reader:             NewReader(readDB),
-		writer:             sql.NewWriter(writeDB),
+		writers:            writers,
 		oldDB:              readDB,
 		newDB:              writeDB,
+		checkpointer:       checkpointer,
+		validator:          validator,
+		writePgStrs:        writePgStrs,
+		canonicalizer:      canonicalizer,
+		nonCanonicalReport: nonCanonicalReport,
 		closeChan:          make(chan struct{}),
 		numWorkersPerTable: numWorkers,
+
+		transferRetries:      conf.TransferRetries,
+		transferRetryBackoff: conf.TransferRetryBackoff,
+		transferMode:         transferMode,
+		blocksWriter:         blocksWriter,
 	}, nil
 }
 
 // TransformToCSV satisfies Migrator
 // TransformToCSV spins up a goroutine to process the block ranges provided through the blockRanges work chan for the specified tables
 // TransformToCSV returns a channel for emitting read gaps and failed write ranges, a channel for signaling completion
-// of the process, a quitChan for closing the single process, and a channel for writing out errors
-func (s *Service) TransformToCSV(csvWriter csv.Writer, wg *sync.WaitGroup, tableName TableName,
-	blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan struct{}, chan error) {
-	quitChan := make(chan struct{})
+// of the process, and a channel for writing out errors
+// The process completes once blockRanges is closed and drained, or once ctx is done; ranges already started are finished
+func (s *Service) TransformToCSV(ctx context.Context, csvWriter csv.Writer, wg *sync.WaitGroup, tableName TableName,
+	blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error) {
 	doneChan := make(chan struct{})
-	transformer := NewTableTransformer(tableName)
-	readPgStr := tableReaderStrMappings[tableName]
-	writeCSVStr := csvWriterStrMappings[tableName]
+	pipeline, pipelineErr := s.newPipeline(tableName)
+	spec, _ := LookupTableSpec(tableName)
+	writeCSVStr := spec.CSVWriteQuery
 	readGapChan := make(chan [2]uint64)
 	writeGapChan := make(chan [2]uint64)
 	errChan := make(chan error)
 	innerWg := new(sync.WaitGroup)
+	process := metrics.ProcessCSV
 
 	for workerNum := 1; workerNum <= s.numWorkersPerTable; workerNum++ {
 		innerWg.Add(1)
@@ -98,59 +243,93 @@ func (s *Service) TransformToCSV(csvWriter csv.Writer, wg *sync.WaitGroup, table
 			logrus.Infof("starting migration worker %d for table %s", workerNum, tableName)
 			defer innerWg.Done()
 			for {
+				// check for cancellation first, so that no new range is started once the context is done
 				select {
-				case rng := <-blockRanges:
+				case <-ctx.Done():
+					logrus.Infof("quitting migration worker %d for table %s: %v", workerNum, tableName, ctx.Err())
+					return
+				case <-s.closeChan:
+					logrus.Infof("quitting migration worker %d for table %s", workerNum, tableName)
+					return
+				default:
+				}
+				select {
+				case rng, ok := <-blockRanges:
+					if !ok {
+						logrus.Infof("block ranges exhausted, quitting migration worker %d for table %s", workerNum, tableName)
+						return
+					}
 					logrus.Debugf("table %s worker %d received block range (%d, %d)", tableName, workerNum, rng[0], rng[1])
-					oldModels, err := NewTableReadModels(tableName)
-					if err != nil {
-						errChan <- fmt.Errorf("table %s worker %d unable to create tabel models for range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+					if err := pipelineErr; err != nil {
+						errChan <- fmt.Errorf("table %s worker %d unable to create table pipeline for range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+						metrics.RangeFailed(process, string(tableName), metrics.StageRead)
+						s.report(tableName, rng, GapRead, workerNum, err)
 						readGapChan <- rng
 						continue
 					}
-					if err := s.reader.Read(rng, readPgStr, oldModels); err != nil {
+					readStart := time.Now()
+					oldModels, err := pipeline.read(s.reader, s.canonicalizer, rng)
+					if err != nil {
 						errChan <- fmt.Errorf("table %s worker %d read error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+						metrics.RangeFailed(process, string(tableName), metrics.StageRead)
+						s.report(tableName, rng, GapRead, workerNum, err)
 						readGapChan <- rng
 						continue
 					}
-					numReadRecords := reflect.Indirect(reflect.ValueOf(oldModels)).Len()
+					metrics.ObserveStage(process, string(tableName), metrics.StageRead, readStart)
+					numReadRecords := oldModels.len()
+					metrics.RowsRead(process, string(tableName), numReadRecords)
 					if numReadRecords == 0 {
-						if tableName == EthHeaders || tableName == EthState || tableName == EthAccounts {
+						if spec.GapChecked {
 							// all other tables can, at least in theory, be empty within a range
 							// e.g. a block that has no txs or uncles will only
 							// have a header and an updated state account for the miner's reward
+							metrics.RangeFailed(process, string(tableName), metrics.StageRead)
+							s.report(tableName, rng, GapRead, workerNum, errEmptyRange)
 							readGapChan <- rng
 						} else {
 							logrus.Infof("table %s worker %d finished range (%d, %d)- no read records found in range", tableName, workerNum, rng[0], rng[1])
+							metrics.RangeCompleted(process, string(tableName), rng)
+							s.observe(tableName, rng, 0, readStart)
 						}
 						continue
 					}
 					logrus.Debugf("table %s worker %d block range (%d, %d) read models count: %d", tableName, workerNum, rng[0], rng[1], numReadRecords)
-					newModels, gaps, err := transformer.Transform(oldModels, rng)
+					transformStart := time.Now()
+					newModels, gaps, duplicates, err := oldModels.transform(rng)
 					if err != nil {
 						errChan <- fmt.Errorf("table %s worker %d transform error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+						metrics.RangeFailed(process, string(tableName), metrics.StageTransform)
+						s.report(tableName, rng, GapTransform, workerNum, err)
 						writeGapChan <- rng
 						continue
 					}
-					logrus.Debugf("table %s worker %d block range (%d, %d) write models count: %d", tableName, workerNum, rng[0], rng[1], reflect.ValueOf(newModels).Len())
-					if err := csvWriter.Write(writeCSVStr, newModels); err != nil {
+					metrics.ObserveStage(process, string(tableName), metrics.StageTransform, transformStart)
+					logrus.Debugf("table %s worker %d block range (%d, %d) write models count: %d", tableName, workerNum, rng[0], rng[1], newModels.len())
+					writeStart := time.Now()
+					if err := csvWriter.Write(writeCSVStr, newModels.models()); err != nil {
 						errChan <- fmt.Errorf("table %s worker %d write error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+						metrics.RangeFailed(process, string(tableName), metrics.StageWrite)
+						s.report(tableName, rng, GapWrite, workerNum, err)
 						writeGapChan <- rng
 						continue
 					}
+					metrics.ObserveStage(process, string(tableName), metrics.StageWrite, writeStart)
+					metrics.RowsWritten(process, string(tableName), newModels.len())
 					for _, gap := range gaps {
+						s.report(tableName, gap, GapRead, workerNum, nil)
 						readGapChan <- gap
 					}
+					s.reportDuplicates(tableName, rng, workerNum, duplicates)
+					metrics.RangeCompleted(process, string(tableName), rng)
+					s.observe(tableName, rng, numReadRecords, readStart)
 					logrus.Infof("table %s worker %d finished range (%d, %d)- %d records processed", tableName, workerNum, rng[0], rng[1], numReadRecords)
+				case <-ctx.Done():
+					logrus.Infof("quitting migration worker %d for table %s: %v", workerNum, tableName, ctx.Err())
+					return
 				case <-s.closeChan:
 					logrus.Infof("quitting migration worker %d for table %s", workerNum, tableName)
 					return
-				default:
-					select {
-					case <-quitChan:
-						logrus.Infof("quitting migration worker %d for table %s", workerNum, tableName)
-						return
-					default:
-					}
 				}
 			}
 		}(workerNum, tableName)
@@ -163,24 +342,28 @@ func (s *Service) TransformToCSV(csvWriter csv.Writer, wg *sync.WaitGroup, table
 		close(doneChan)
 	}()
 
-	return readGapChan, writeGapChan, doneChan, quitChan, errChan
+	return readGapChan, writeGapChan, doneChan, errChan
 }
 
 // Migrate satisfies Migrator
 // Migrate spins up a goroutine to process the block ranges provided through the blockRanges work chan for the specified tables
 // Migrate returns a channel for emitting read gaps and failed write ranges, a channel for signaling
-// completion of the process, a quitChan for closing the single process, and a channel for writing out errors
-func (s *Service) Migrate(wg *sync.WaitGroup, tableName TableName, blockRanges <-chan [2]uint64) (chan [2]uint64,
-	chan [2]uint64, chan struct{}, chan struct{}, chan error) {
-	quitChan := make(chan struct{})
+// completion of the process, and a channel for writing out errors
+// The process completes once blockRanges is closed and drained, or once ctx is done; ranges already started are finished
+// Each range is written within a single transaction, so a range emitted as a write gap has had none of its records written
+// Once the process completes, the ranges of the table it never settled are failed in the Scheduler in use, if any
+func (s *Service) Migrate(ctx context.Context, wg *sync.WaitGroup, tableName TableName, blockRanges <-chan [2]uint64) (chan [2]uint64,
+	chan [2]uint64, chan struct{}, chan error) {
 	doneChan := make(chan struct{})
-	transformer := NewTableTransformer(tableName)
-	readPgStr := tableReaderStrMappings[tableName]
-	writePgStr := tableWriterStrMappings[tableName]
+	spec, _ := LookupTableSpec(tableName)
+	pipeline, pipelineErr := s.newPipeline(tableName)
+	writePgStr := s.writePgStrs[tableName]
+	writer := s.writers[tableName]
 	readGapChan := make(chan [2]uint64)
 	writeGapChan := make(chan [2]uint64)
 	errChan := make(chan error)
 	innerWg := new(sync.WaitGroup)
+	process := metrics.ProcessMigrate
 
 	for workerNum := 1; workerNum <= s.numWorkersPerTable; workerNum++ {
 		innerWg.Add(1)
@@ -188,59 +371,134 @@ func (s *Service) Migrate(wg *sync.WaitGroup, tableName TableName, blockRanges <
 			logrus.Infof("starting migration worker %d for table %s", workerNum, tableName)
 			defer innerWg.Done()
 			for {
+				// check for cancellation first, so that no new range is started once the context is done
+				select {
+				case <-ctx.Done():
+					logrus.Infof("quitting migration worker %d for table %s: %v", workerNum, tableName, ctx.Err())
+					return
+				case <-s.closeChan:
+					logrus.Infof("quitting migration worker %d for table %s", workerNum, tableName)
+					return
+				default:
+				}
 				select {
-				case rng := <-blockRanges:
+				case rng, ok := <-blockRanges:
+					if !ok {
+						logrus.Infof("block ranges exhausted, quitting migration worker %d for table %s", workerNum, tableName)
+						return
+					}
 					logrus.Debugf("table %s worker %d received block range (%d, %d)", tableName, workerNum, rng[0], rng[1])
-					oldModels, err := NewTableReadModels(tableName)
-					if err != nil {
-						errChan <- fmt.Errorf("table %s worker %d unable to create tabel models for range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+					if err := s.scheduler.Wait(ctx, s.closeChan, tableName, rng); err != nil {
+						if ctx.Err() != nil || errors.Is(err, errSchedulerQuit) {
+							logrus.Infof("quitting migration worker %d for table %s before range (%d, %d): %v", workerNum, tableName, rng[0], rng[1], err)
+							return
+						}
+						errChan <- fmt.Errorf("table %s worker %d skipped range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+						s.scheduler.Settle(tableName, rng, err)
+						s.report(tableName, rng, GapWrite, workerNum, err)
+						writeGapChan <- rng
+						continue
+					}
+					if err := pipelineErr; err != nil {
+						errChan <- fmt.Errorf("table %s worker %d unable to create table pipeline for range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+						metrics.RangeFailed(process, string(tableName), metrics.StageRead)
+						s.dryRun.failed(tableName, metrics.StageRead, 0)
+						s.scheduler.Settle(tableName, rng, err)
+						s.report(tableName, rng, GapRead, workerNum, err)
 						readGapChan <- rng
 						continue
 					}
-					if err := s.reader.Read(rng, readPgStr, oldModels); err != nil {
+					readStart := time.Now()
+					oldModels, err := pipeline.read(s.reader, s.canonicalizer, rng)
+					if err != nil {
 						errChan <- fmt.Errorf("table %s worker %d read error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+						metrics.RangeFailed(process, string(tableName), metrics.StageRead)
+						s.dryRun.failed(tableName, metrics.StageRead, 0)
+						s.scheduler.Settle(tableName, rng, err)
+						s.report(tableName, rng, GapRead, workerNum, err)
 						readGapChan <- rng
 						continue
 					}
-					numReadRecords := reflect.Indirect(reflect.ValueOf(oldModels)).Len()
+					metrics.ObserveStage(process, string(tableName), metrics.StageRead, readStart)
+					numReadRecords := oldModels.len()
+					metrics.RowsRead(process, string(tableName), numReadRecords)
 					if numReadRecords == 0 {
-						if tableName == EthHeaders || tableName == EthState || tableName == EthAccounts {
+						if spec.GapChecked {
 							// all other tables can, at least in theory, be empty within a range
 							// e.g. a block that has no txs or uncles will only
 							// have a header and an updated state account for the miner's reward
+							metrics.RangeFailed(process, string(tableName), metrics.StageRead)
+							s.dryRun.completed(tableName, 0, 0, [][2]uint64{rng}, 0, 0)
+							s.scheduler.Settle(tableName, rng, errEmptyRange)
+							s.report(tableName, rng, GapRead, workerNum, errEmptyRange)
 							readGapChan <- rng
 						} else {
 							logrus.Infof("table %s worker %d finished range (%d, %d)- no read records found in range", tableName, workerNum, rng[0], rng[1])
+							metrics.RangeCompleted(process, string(tableName), rng)
+							s.observe(tableName, rng, 0, readStart)
+							s.dryRun.completed(tableName, 0, 0, nil, 0, 0)
+							s.commit(errChan, tableName, workerNum, rng)
 						}
 						continue
 					}
 					logrus.Debugf("table %s worker %d block range (%d, %d) read models count: %d", tableName, workerNum, rng[0], rng[1], numReadRecords)
-					newModels, gaps, err := transformer.Transform(oldModels, rng)
+					transformStart := time.Now()
+					newModels, gaps, duplicates, err := oldModels.transform(rng)
 					if err != nil {
 						errChan <- fmt.Errorf("table %s worker %d transform error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+						metrics.RangeFailed(process, string(tableName), metrics.StageTransform)
+						s.dryRun.failed(tableName, metrics.StageTransform, numReadRecords)
+						s.scheduler.Settle(tableName, rng, err)
+						s.report(tableName, rng, GapTransform, workerNum, err)
 						writeGapChan <- rng
 						continue
 					}
-					logrus.Debugf("table %s worker %d block range (%d, %d) write models count: %d", tableName, workerNum, rng[0], rng[1], reflect.ValueOf(newModels).Len())
-					if err := s.writer.Write(writePgStr, newModels); err != nil {
-						errChan <- fmt.Errorf("table %s worker %d write error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
-						writeGapChan <- rng
-						continue
+					metrics.ObserveStage(process, string(tableName), metrics.StageTransform, transformStart)
+					if s.validator != nil {
+						validateStart := time.Now()
+						if err := s.validator.Validate(newModels.models()); err != nil {
+							errChan <- fmt.Errorf("table %s worker %d validate error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+							metrics.RangeFailed(process, string(tableName), metrics.StageValidate)
+							s.dryRun.failed(tableName, metrics.StageValidate, numReadRecords)
+							s.scheduler.Settle(tableName, rng, err)
+							s.report(tableName, rng, GapValidate, workerNum, err)
+							writeGapChan <- rng
+							continue
+						}
+						metrics.ObserveStage(process, string(tableName), metrics.StageValidate, validateStart)
+					}
+					logrus.Debugf("table %s worker %d block range (%d, %d) write models count: %d", tableName, workerNum, rng[0], rng[1], newModels.len())
+					if s.dryRun != nil {
+						// a dry run never writes, it only records what would have been written
+						s.recordDryRun(errChan, tableName, workerNum, rng, numReadRecords, newModels, gaps, duplicates)
+					} else {
+						writeStart := time.Now()
+						if err := s.write(writer, tableName, writePgStr, newModels.models(), rng); err != nil {
+							errChan <- fmt.Errorf("table %s worker %d write error (%v) in range (%d, %d)", tableName, workerNum, err, rng[0], rng[1])
+							metrics.RangeFailed(process, string(tableName), metrics.StageWrite)
+							s.scheduler.Settle(tableName, rng, err)
+							s.report(tableName, rng, GapWrite, workerNum, err)
+							writeGapChan <- rng
+							continue
+						}
+						metrics.ObserveStage(process, string(tableName), metrics.StageWrite, writeStart)
+						metrics.RowsWritten(process, string(tableName), newModels.len())
+						s.scheduler.Settle(tableName, rng, nil)
 					}
 					for _, gap := range gaps {
+						s.report(tableName, gap, GapRead, workerNum, nil)
 						readGapChan <- gap
 					}
+					s.reportDuplicates(tableName, rng, workerNum, duplicates)
+					metrics.RangeCompleted(process, string(tableName), rng)
+					s.observe(tableName, rng, numReadRecords, readStart)
 					logrus.Infof("table %s worker %d finished range (%d, %d)- %d records processed", tableName, workerNum, rng[0], rng[1], numReadRecords)
+				case <-ctx.Done():
+					logrus.Infof("quitting migration worker %d for table %s: %v", workerNum, tableName, ctx.Err())
+					return
 				case <-s.closeChan:
 					logrus.Infof("quitting migration worker %d for table %s", workerNum, tableName)
 					return
-				default:
-					select {
-					case <-quitChan:
-						logrus.Infof("quitting migration worker %d for table %s", workerNum, tableName)
-						return
-					default:
-					}
 				}
 			}
 		}(workerNum, tableName)
@@ -249,26 +507,173 @@ func (s *Service) Migrate(wg *sync.WaitGroup, tableName TableName, blockRanges <
 	wg.Add(1)
 	go func() {
 		innerWg.Wait()
+		// the ranges of the table that were never migrated would otherwise hold up the dependent tables for good
+		s.scheduler.SettleRemaining(tableName, errUnsettled)
 		wg.Done()
 		close(doneChan)
 	}()
 
-	return readGapChan, writeGapChan, doneChan, quitChan, errChan
+	return readGapChan, writeGapChan, doneChan, errChan
+}
+
+// newPipeline returns the tablePipeline for the table
+// Transformers that resolve IPLDs beyond their read models fetch them from the DB the models are read from
+func (s *Service) newPipeline(tableName TableName) (tablePipeline, error) {
+	return newTablePipeline(tableName, public_blocks.NewFetcher(s.oldDB))
+}
+
+// write writes the models of the block range with the writer, checkpointing the range within the same transaction
+// if checkpointing is enabled, so a range is checkpointed if and only if its rows are committed
+// Writers that can not run a hook within their transaction have the range checkpointed once the write has committed
+func (s *Service) write(writer interfaces.Writer, tableName TableName, pgStr sql.WritePgStr, models interface{}, rng [2]uint64) error {
+	hook := s.checkpointHook(tableName, rng)
+	if hookedWriter, ok := writer.(interfaces.HookedWriter); ok {
+		return hookedWriter.WriteWithHook(pgStr, models, hook)
+	}
+	if err := writer.Write(pgStr, models); err != nil {
+		return err
+	}
+	if hook == nil {
+		return nil
+	}
+	return s.checkpointer.Commit(tableName, rng)
+}
+
+// checkpointHook returns the util.TxHook that checkpoints the block range for the table, or nil if checkpointing is not enabled
+func (s *Service) checkpointHook(tableName TableName, rng [2]uint64) util.TxHook {
+	if s.checkpointer == nil {
+		return nil
+	}
+	return s.checkpointer.Hook(tableName, rng)
+}
+
+// commit records a block range that had nothing to write as committed for the table, if checkpointing is enabled
+// and releases the ranges of any dependent tables scheduled to wait on it
+func (s *Service) commit(errChan chan error, tableName TableName, workerNum int, rng [2]uint64) {
+	s.scheduler.Settle(tableName, rng, nil)
+	if s.checkpointer == nil {
+		return
+	}
+	if err := s.checkpointer.Commit(tableName, rng); err != nil {
+		errChan <- fmt.Errorf("table %s worker %d unable to checkpoint range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+	}
+}
+
+// recordDryRun records a read and transformed range of a dry run, in place of writing and committing it
+func (s *Service) recordDryRun(errChan chan error, tableName TableName, workerNum int, rng [2]uint64, rowsRead int,
+	newModels writeBatch, gaps [][2]uint64, duplicates []uint64) {
+	mismatches, err := countMhKeyMismatches(s.oldDB.Mapper, newModels.models())
+	if err != nil {
+		errChan <- fmt.Errorf("table %s worker %d unable to check mh_keys in range (%d, %d): %v", tableName, workerNum, rng[0], rng[1], err)
+	}
+	if mismatches > 0 {
+		logrus.Warnf("table %s worker %d found %d mh_keys that do not match their cid in range (%d, %d)", tableName, workerNum, mismatches, rng[0], rng[1])
+	}
+	s.dryRun.completed(tableName, rowsRead, newModels.len(), gaps, len(duplicates), mismatches)
+	s.scheduler.Settle(tableName, rng, nil)
+}
+
+// CommittedRanges satisfies Migrator
+// CommittedRanges returns the block ranges that have been checkpointed as committed for the provided table
+func (s *Service) CommittedRanges(tableName TableName) ([][2]uint64, error) {
+	if s.checkpointer == nil {
+		return nil, errors.New("checkpointing is not enabled for this Migrator")
+	}
+	return s.checkpointer.Committed(tableName)
+}
+
+// DetectRanges satisfies Migrator
+// DetectRanges returns the block ranges within the bounds that the provided table has rows at in the old DB, but not yet
+// in the new DB
+// a read-only Migrator has no new DB to go by, so it returns every block range the table has rows at in the old DB
+func (s *Service) DetectRanges(tableName TableName, bounds [2]uint64) ([][2]uint64, error) {
+	return DetectMissingRanges(s.oldDB, s.newDB, tableName, bounds)
+}
+
+// SampleRanges satisfies Migrator
+// SampleRanges samples the row density of the provided table across the block ranges into the AdaptiveSegmenter,
+// reading through the old DB of the Migrator
+func (s *Service) SampleRanges(segmenter *AdaptiveSegmenter, tableName TableName, ranges [][2]uint64) error {
+	for _, rng := range ranges {
+		if err := segmenter.Sample(s.oldDB, tableName, rng); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DryRunReport satisfies Migrator
+// DryRunReport returns the report of a dry run Migrator, or nil if the Migrator is not a dry run
+func (s *Service) DryRunReport() *DryRunReport {
+	return s.dryRun
+}
+
+// UseReportWriter satisfies Migrator
+// UseReportWriter writes an entry out to the provided ReportWriter for every block range that fails or is found missing
+// it has to be called before Migrate, TransformToCSV, or Transfer
+func (s *Service) UseReportWriter(reportWriter *ReportWriter) {
+	s.reportWriter = reportWriter
+}
+
+// UseScheduler satisfies Migrator
+// UseScheduler makes subsequent calls to Migrate wait on the provided Scheduler before migrating each block range
+// it has to be called before Migrate
+func (s *Service) UseScheduler(scheduler *Scheduler) {
+	s.scheduler = scheduler
+}
+
+// UseRangeObserver satisfies Migrator
+// UseRangeObserver reports the rows read and the processing time of every completed block range to the provided RangeObserver
+// it has to be called before Migrate or TransformToCSV
+func (s *Service) UseRangeObserver(observer RangeObserver) {
+	s.observer = observer
+}
+
+// observe reports a completed block range to the RangeObserver in use, if any
+func (s *Service) observe(tableName TableName, rng [2]uint64, rows int, start time.Time) {
+	if s.observer != nil {
+		s.observer.Observe(tableName, rng, rows, time.Since(start))
+	}
 }
 
 // Transfer for transferring public.blocks to a new DB page-by-page
-// Transfer assumes the targeted postgres_fdw is already in the db
+// in the fdw transfer mode Transfer assumes the targeted postgres_fdw is already in the db, while in the direct
+// transfer mode the pages of public.blocks are read from the old DB and written with the default write mode,
+// in which case fdwTableName is ignored
+// the page segments are transferred by the configured number of workers, and each segment that is transferred is
+// checkpointed under PublicBlocksCheckpoint in either transfer mode, so that segments already checkpointed are skipped when the transfer is restarted
+// a segment that fails is retried with an exponential backoff, up to the configured number of retries
 // returns a chan for logging failed transfer page ranges, a chan for the errors that caused them,
 // a chan for signalling success, and any error during initialization
-func (s *Service) Transfer(wg *sync.WaitGroup, fdwTableName string, segmentSize, segmentOffset, maxPage uint64) (chan [2]uint64,
+// The transfer stops before the next segment once ctx is done
+func (s *Service) Transfer(ctx context.Context, wg *sync.WaitGroup, fdwTableName string, segmentSize, segmentOffset, maxPage uint64) (chan [2]uint64,
 	chan struct{}, chan error, error) {
 	db := s.newDB
+	if db == nil {
+		return nil, nil, nil, errReadOnly
+	}
 	if fdwTableName == "" {
 		fdwTableName = public_blocks.DefaultV2FDWTableName
 	}
+	maxPageDB := db
+	if s.transferMode == public_blocks.TransferModeDirect {
+		// the pages are read from the old DB itself, so there is no foreign table to go through
+		fdwTableName, maxPageDB = public_blocks.V2TableName, s.oldDB
+	}
+	tableName := PublicBlocksCheckpoint
+	// each page range is checkpointed within the transaction it is transferred in
+	transferPages := func(firstPage, lastPage uint64) error {
+		return public_blocks.TransferPages(db, fdwTableName, firstPage, lastPage,
+			s.checkpointHook(tableName, [2]uint64{firstPage, lastPage}))
+	}
+	if s.transferMode == public_blocks.TransferModeDirect {
+		transferPages = func(firstPage, lastPage uint64) error {
+			return s.transferPagesDirect(tableName, firstPage, lastPage)
+		}
+	}
 	var err error
 	if maxPage == 0 {
-		maxPage, err = public_blocks.GetMaxPage(db, fdwTableName)
+		maxPage, err = public_blocks.GetMaxPage(maxPageDB, fdwTableName)
 		if err != nil {
 			return nil, nil, nil, err
 		}
@@ -277,47 +682,155 @@ func (s *Service) Transfer(wg *sync.WaitGroup, fdwTableName string, segmentSize,
 		logrus.Infof("using pre-configured max page number for table %s: %d", fdwTableName, maxPage)
 	}
 
-	doneChan := make(chan struct{})
 	segments := public_blocks.GetPageSegments(maxPage, segmentSize)
 	if segmentOffset < uint64(len(segments)) {
 		segments = segments[segmentOffset:]
 	}
+	if s.checkpointer != nil {
+		committed, err := s.checkpointer.Committed(tableName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		numSegments := len(segments)
+		segments = SubtractRanges(segments, committed)
+		logrus.Infof("%d page ranges of table %s already checkpointed as transferred, %d page ranges left out of %d segments",
+			len(committed), fdwTableName, len(segments), numSegments)
+	}
+	transferFailChan, doneChan, errChan := s.transferSegments(ctx, wg, fdwTableName, transferPages, segments)
+	return transferFailChan, doneChan, errChan, nil
+}
+
+// transferSegments spins up the configured number of workers to transfer the page segments through transferPages
+// a segment that still fails once its retries have run out is reported as a transfer gap
+// returns a chan for the failed page ranges, a chan for signalling completion, and a chan for the errors
+func (s *Service) transferSegments(ctx context.Context, wg *sync.WaitGroup, fdwTableName string,
+	transferPages func(firstPage, lastPage uint64) error, segments [][2]uint64) (chan [2]uint64, chan struct{}, chan error) {
+	tableName := PublicBlocksCheckpoint
+	doneChan := make(chan struct{})
 	errChan := make(chan error)
 	transferFailChan := make(chan [2]uint64)
+	segmentChan := make(chan [2]uint64)
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer close(doneChan)
+		defer close(segmentChan)
 		for i, segment := range segments {
-			segNum := uint64(i) + segmentOffset
 			select {
+			case <-ctx.Done():
+				logrus.Infof("quitting transfer process for table %s: %v", fdwTableName, ctx.Err())
+				logrus.Infof("was about to transfer %s page range (%d, %d), %d page ranges left untransferred",
+					fdwTableName, segment[0], segment[1], len(segments)-i)
+				return
 			case <-s.closeChan:
 				logrus.Infof("quitting transfer process for table %s", fdwTableName)
-				logrus.Infof("was about to transfer %s segment #%d page range (%d, %d)", fdwTableName, segNum,
-					segment[0], segment[1])
+				logrus.Infof("was about to transfer %s page range (%d, %d), %d page ranges left untransferred",
+					fdwTableName, segment[0], segment[1], len(segments)-i)
 				return
-			default:
-			}
-			logrus.Infof("transfering %s segment #%d page range (%d, %d) from old DB to new DB", fdwTableName,
-				segNum, segment[0], segment[1])
-			if err := public_blocks.TransferPages(db, fdwTableName, segment[0], segment[1]); err != nil {
-				errChan <- fmt.Errorf("failed to transfer %s segment #%d page range (%d, %d): %v", fdwTableName,
-					segNum, segment[0], segment[1], err)
-				transferFailChan <- segment
+			case segmentChan <- segment:
 			}
 		}
 	}()
-	return transferFailChan, doneChan, errChan, nil
+
+	innerWg := new(sync.WaitGroup)
+	for workerNum := 1; workerNum <= s.numWorkersPerTable; workerNum++ {
+		wg.Add(1)
+		innerWg.Add(1)
+		go func(workerNum int) {
+			defer wg.Done()
+			defer innerWg.Done()
+			for segment := range segmentChan {
+				logrus.Infof("worker %d transfering %s page range (%d, %d) from old DB to new DB", workerNum,
+					fdwTableName, segment[0], segment[1])
+				segmentStart := time.Now()
+				if err := s.transferSegment(ctx, transferPages, fdwTableName, workerNum, segment); err != nil {
+					errChan <- fmt.Errorf("worker %d failed to transfer %s page range (%d, %d): %v", workerNum, fdwTableName,
+						segment[0], segment[1], err)
+					metrics.TransferSegmentFailed(fdwTableName)
+					s.report(tableName, segment, GapTransfer, workerNum, err)
+					transferFailChan <- segment
+					continue
+				}
+				metrics.TransferSegment(fdwTableName, segment[1]-segment[0]+1, time.Since(segmentStart))
+			}
+		}(workerNum)
+	}
+	go func() {
+		innerWg.Wait()
+		close(doneChan)
+	}()
+	return transferFailChan, doneChan, errChan
+}
+
+// transferSegment transfers the page range, retrying it with an exponential backoff up to the configured number of retries
+// it gives up without retrying once ctx is done or the Migrator is closed, returning the last error
+func (s *Service) transferSegment(ctx context.Context, transferPages func(firstPage, lastPage uint64) error,
+	fdwTableName string, workerNum int, segment [2]uint64) error {
+	backoff := s.transferRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := transferPages(segment[0], segment[1])
+		if err == nil || attempt > s.transferRetries {
+			return err
+		}
+		logrus.Warnf("worker %d failed to transfer %s page range (%d, %d), retry %d of %d in %s: %v", workerNum,
+			fdwTableName, segment[0], segment[1], attempt, s.transferRetries, backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-s.closeChan:
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > maxTransferRetryBackoff {
+			backoff = maxTransferRetryBackoff
+		}
+	}
+}
+
+// transferPagesDirect reads the public.blocks rows stored in the pages from the old DB, and writes them to the new DB
+// within a single transaction, skipping the rows whose key is already there
+// the page range is checkpointed under the table name within the same transaction
+func (s *Service) transferPagesDirect(tableName TableName, firstPage, lastPage uint64) error {
+	models, err := public_blocks.ReadPages(s.oldDB, public_blocks.V2TableName, firstPage, lastPage)
+	if err != nil {
+		return err
+	}
+	pages := [2]uint64{firstPage, lastPage}
+	if len(models) == 0 {
+		if s.checkpointer == nil {
+			return nil
+		}
+		return s.checkpointer.Commit(tableName, pages)
+	}
+	return s.write(s.blocksWriter, tableName, sql.PgWriteIPLDsStr, models, pages)
 }
 
 // Close satisfied io.Closer
 // Close shuts down the Migrator, it quits all Migrate goroutines that are currently running
-// whereas closing the chan returned by Migrate only closes the goroutines spun up by that method call
+// whereas cancelling the context passed to Migrate only quits the goroutines spun up by that method call
 func (s *Service) Close() error {
 	close(s.closeChan)
-	if err := s.reader.Close(); err != nil {
-		return err
+	var errs []error
+	if s.nonCanonicalReport != nil {
+		errs = append(errs, s.nonCanonicalReport.Close())
+	}
+	errs = append(errs, s.reader.Close())
+	if s.newDB != nil {
+		// every table writer shares the new DB, so it is closed once here rather than through each writer
+		errs = append(errs, s.newDB.Close())
 	}
-	return s.writer.Close()
+	return errors.Join(errs...)
 }
+
+var errReadOnly = errors.New("the Migrator is read-only and has no connection to the new DB")
+
+// errEmptyRange is the error a range settles with when a table that is checked for gaps has no records within it
+var errEmptyRange = errors.New("no records found in range")
+
+// readOnlyWriter is the interfaces.Writer for read-only Migrators, it fails every write
+type readOnlyWriter struct{}
+
+func (readOnlyWriter) Write(sql.WritePgStr, interface{}) error { return errReadOnly }
+
+func (readOnlyWriter) Close() error { return nil }
@@ -18,6 +18,9 @@ package migration_tools_test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/core/types"
@@ -26,10 +29,15 @@ import (
 	"github.com/ethereum/go-ethereum/statediff/indexer/database/sql/postgres"
 	"github.com/ethereum/go-ethereum/statediff/indexer/interfaces"
 	"github.com/ethereum/go-ethereum/statediff/indexer/node"
+	"github.com/jmoiron/sqlx"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/csv"
+	"github.com/vulcanize/migration-tools/pkg/eth_headers"
+	"github.com/vulcanize/migration-tools/pkg/eth_state"
+	"github.com/vulcanize/migration-tools/pkg/public_nodes"
 )
 
 func writeV2SQL(sqlDB sql.Database) {
@@ -88,7 +96,7 @@ var _ = Describe("Migration Service", Serial, func() {
 		It("throws no errors on empty range", Serial, Label("test"), func() {
 			wg := new(sync.WaitGroup)
 			blockRangeChan := make(chan [2]uint64)
-			readGaps, writeGaps, doneChan, quitChan, errChan := migrator.Migrate(wg, migration_tools.EthLogsRepair, blockRangeChan)
+			readGaps, writeGaps, doneChan, errChan := migrator.Migrate(context.Background(), wg, migration_tools.EthLogsRepair, blockRangeChan)
 			rng := [2]uint64{migration_tools.BlockNumber.Uint64(), migration_tools.BlockNumber.Uint64() + 1000}
 			blockRangeChan <- rng
 
@@ -110,7 +118,7 @@ var _ = Describe("Migration Service", Serial, func() {
 					}
 				}
 			}()
-			close(quitChan)
+			close(blockRangeChan)
 			wg.Wait()
 			Expect(readGap).To(Equal([2]uint64{0, 0}))
 			Expect(writeGap).To(Equal([2]uint64{0, 0}))
@@ -120,7 +128,7 @@ var _ = Describe("Migration Service", Serial, func() {
 		It("throws no errors on empty range", Serial, Label("test"), func() {
 			wg := new(sync.WaitGroup)
 			blockRangeChan := make(chan [2]uint64)
-			readGaps, writeGaps, doneChan, quitChan, errChan := migrator.Migrate(wg, migration_tools.EthLogsRepair, blockRangeChan)
+			readGaps, writeGaps, doneChan, errChan := migrator.Migrate(context.Background(), wg, migration_tools.EthLogsRepair, blockRangeChan)
 			rng := [2]uint64{0, 1000}
 			blockRangeChan <- rng
 
@@ -142,7 +150,7 @@ var _ = Describe("Migration Service", Serial, func() {
 					}
 				}
 			}()
-			close(quitChan)
+			close(blockRangeChan)
 			wg.Wait()
 			Expect(readGap).To(Equal([2]uint64{0, 0}))
 			Expect(writeGap).To(Equal([2]uint64{0, 0}))
@@ -244,7 +252,7 @@ var _ = Describe("Migration Service", Serial, func() {
 
 			wg := new(sync.WaitGroup)
 			blockRangeChan := make(chan [2]uint64)
-			readGaps, writeGaps, doneChan, quitChan, errChan := migrator.Migrate(wg, migration_tools.EthLogsRepair, blockRangeChan)
+			readGaps, writeGaps, doneChan, errChan := migrator.Migrate(context.Background(), wg, migration_tools.EthLogsRepair, blockRangeChan)
 			rng := [2]uint64{migration_tools.BlockNumber.Uint64(), migration_tools.BlockNumber.Uint64() + 1000}
 			blockRangeChan <- rng
 
@@ -267,7 +275,7 @@ var _ = Describe("Migration Service", Serial, func() {
 				}
 			}()
 
-			close(quitChan)
+			close(blockRangeChan)
 			wg.Wait()
 			Expect(readGap).To(Equal([2]uint64{0, 0}))
 			Expect(writeGap).To(Equal([2]uint64{0, 0}))
@@ -304,8 +312,248 @@ var _ = Describe("Migration Service", Serial, func() {
 			}
 		})
 	})
+
+	Describe("state_cids migration", Serial, func() {
+		var (
+			v2DB     *sqlx.DB
+			conf     *migration_tools.Config
+			expected []eth_state.StateModelV3
+			rng      = [2]uint64{migration_tools.BlockNumber.Uint64(), migration_tools.BlockNumber.Uint64()}
+		)
+		BeforeEach(func() {
+			driver, err := postgres.NewSQLXDriver(context.Background(), v3DBConfig, node.Info{})
+			Expect(err).ToNot(HaveOccurred())
+			sqlDB = postgres.NewPostgresDB(driver)
+			prepDatabase(sqlDB)
+			writeV2SQL(sqlDB)
+			sqlxDB, err = sqlx.Connect("postgres", v3DBConfig.DbConnectionString())
+			Expect(err).ToNot(HaveOccurred())
+			v2DB, err = sqlx.Connect("postgres", v2DBConfig.DbConnectionString())
+			Expect(err).ToNot(HaveOccurred())
+			prepV2SQLXDB(v2DB)
+			expected = writeV2StateFixture(sqlxDB, v2DB)
+			conf = &migration_tools.Config{
+				ReadDB:          v2DBConfig,
+				WriteDB:         v3DBConfig,
+				WorkersPerTable: 1,
+			}
+			migrator, err = migration_tools.NewMigrator(context.Background(), conf)
+			Expect(err).ToNot(HaveOccurred())
+		})
+		AfterEach(func() {
+			migrator.Close()
+			tearDownV2SQLXDB(v2DB)
+			Expect(v2DB.Close()).To(Succeed())
+			Expect(sqlxDB.Close()).To(Succeed())
+			tearDown()
+		})
+
+		It("migrates every state node of a block", Serial, Label("test"), func() {
+			readGaps, writeGaps, errs := processRange(migrator.Migrate, migration_tools.EthState, rng)
+			Expect(errs).To(BeEmpty())
+			Expect(readGaps).To(BeEmpty())
+			Expect(writeGaps).To(BeEmpty())
+
+			var migrated []eth_state.StateModelV3
+			Expect(sqlxDB.Select(&migrated, pgReadV3StateStr)).To(Succeed())
+			Expect(migrated).To(ConsistOf(expected))
+		})
+
+		It("reports the heights without state nodes as read gaps", Serial, Label("test"), func() {
+			gappedRng := [2]uint64{rng[0] - 2, rng[1] + 3}
+			readGaps, writeGaps, errs := processRange(migrator.Migrate, migration_tools.EthState, gappedRng)
+			Expect(errs).To(BeEmpty())
+			Expect(writeGaps).To(BeEmpty())
+			Expect(readGaps).To(ConsistOf([2]uint64{rng[0] - 2, rng[0] - 1}, [2]uint64{rng[1] + 1, rng[1] + 3}))
+
+			var migrated []eth_state.StateModelV3
+			Expect(sqlxDB.Select(&migrated, pgReadV3StateStr)).To(Succeed())
+			Expect(migrated).To(ConsistOf(expected))
+		})
+
+		It("dry runs the state nodes of a block without writing them", Serial, Label("test"), func() {
+			dryRunner, err := migration_tools.NewMigrator(context.Background(), &migration_tools.Config{
+				ReadDB:          v2DBConfig,
+				WorkersPerTable: 1,
+				DryRun:          true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			defer dryRunner.Close()
+			gappedRng := [2]uint64{rng[0], rng[1] + 3}
+			readGaps, writeGaps, errs := processRange(dryRunner.Migrate, migration_tools.EthState, gappedRng)
+			Expect(errs).To(BeEmpty())
+			Expect(writeGaps).To(BeEmpty())
+			Expect(readGaps).To(ConsistOf([2]uint64{rng[1] + 1, rng[1] + 3}))
+
+			var migrated []eth_state.StateModelV3
+			Expect(sqlxDB.Select(&migrated, pgReadV3StateStr)).To(Succeed())
+			Expect(migrated).To(BeEmpty())
+
+			Expect(dryRunner.DryRunReport().Stats()).To(Equal([]migration_tools.DryRunStats{{
+				TableName:       migration_tools.EthState,
+				Ranges:          1,
+				RowsRead:        len(expected),
+				RowsTransformed: len(expected),
+				Gaps:            1,
+				GapBlocks:       3,
+			}}))
+			Expect(migrator.DryRunReport()).To(BeNil())
+		})
+
+		It("verifies the migrated state nodes against the old database", Serial, Label("test"), func() {
+			_, _, errs := processRange(migrator.Migrate, migration_tools.EthState, rng)
+			Expect(errs).To(BeEmpty())
+
+			verifier, err := migration_tools.NewVerifier(context.Background(), conf)
+			Expect(err).ToNot(HaveOccurred())
+			defer verifier.Close()
+			result, err := verifier.Verify(migration_tools.EthState, rng)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.V2Count).To(Equal(len(expected)))
+			Expect(result.Match()).To(BeTrue())
+		})
+
+		It("exports the state nodes of a block to csv", Serial, Label("test"), func() {
+			outputDir, err := os.MkdirTemp("", "state_cids_csv")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(outputDir)
+			csvWriter, err := migration_tools.NewTableCSVWriter(migration_tools.EthState, outputDir, 0, 0)
+			Expect(err).ToNot(HaveOccurred())
+			export := func(ctx context.Context, wg *sync.WaitGroup, tableName migration_tools.TableName,
+				blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error) {
+				return migrator.TransformToCSV(ctx, csvWriter, wg, tableName, blockRanges)
+			}
+			readGaps, writeGaps, errs := processRange(export, migration_tools.EthState, rng)
+			Expect(errs).To(BeEmpty())
+			Expect(readGaps).To(BeEmpty())
+			Expect(writeGaps).To(BeEmpty())
+			Expect(csvWriter.Close()).To(Succeed())
+
+			files, err := filepath.Glob(filepath.Join(outputDir, "*.csv"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+			data, err := os.ReadFile(files[0])
+			Expect(err).ToNot(HaveOccurred())
+			lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			Expect(lines).To(HaveLen(len(expected) + 1))
+			Expect(lines[0]).To(Equal(string(csv.CSVWriteEthStateStr)))
+		})
+	})
+})
+
+var _ = Describe("Migrate workers", func() {
+	// migrate starts the idle workers of a Migrator on the provided block ranges
+	migrate := func(ctx context.Context, blockRanges <-chan [2]uint64) (*sync.WaitGroup, chan struct{}) {
+		idleMigrator := migration_tools.NewIdleMigrator(3)
+		wg := new(sync.WaitGroup)
+		_, _, doneChan, _ := idleMigrator.Migrate(ctx, wg, migration_tools.EthState, blockRanges)
+		return wg, doneChan
+	}
+
+	It("quits the idle workers and closes the done chan once the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		wg, doneChan := migrate(ctx, make(chan [2]uint64))
+		Consistently(doneChan).ShouldNot(BeClosed())
+		cancel()
+		Eventually(doneChan).Should(BeClosed())
+		wg.Wait()
+	})
+
+	It("completes once the block ranges chan is closed, without being quit", func() {
+		blockRanges := make(chan [2]uint64)
+		wg, doneChan := migrate(context.Background(), blockRanges)
+		Consistently(doneChan).ShouldNot(BeClosed())
+		close(blockRanges)
+		Eventually(doneChan).Should(BeClosed())
+		wg.Wait()
+	})
 })
 
+const pgReadV3StateStr = `SELECT header_id, state_path, state_leaf_key, node_type, cid, mh_key, diff FROM eth.state_cids`
+
+// writeV2StateFixture copies the header and state nodes the indexer wrote to the v3 DB over to the v2 DB, in the v2 schema
+// it then removes the state nodes from the v3 DB so that they can be migrated back, and returns them
+func writeV2StateFixture(v3DB, v2DB *sqlx.DB) []eth_state.StateModelV3 {
+	var stateNodes []eth_state.StateModelV3
+	Expect(v3DB.Select(&stateNodes, pgReadV3StateStr)).To(Succeed())
+	Expect(stateNodes).ToNot(BeEmpty())
+	var blocks []struct {
+		Key  string `db:"key"`
+		Data []byte `db:"data"`
+	}
+	Expect(v3DB.Select(&blocks, `SELECT key, data FROM public.blocks`)).To(Succeed())
+	var nodeModel public_nodes.NodeModel
+	Expect(v3DB.Get(&nodeModel, `SELECT client_name, genesis_block, network_id, node_id, chain_id FROM public.nodes LIMIT 1`)).To(Succeed())
+	var header eth_headers.HeaderModelV3
+	Expect(v3DB.Get(&header, `SELECT block_number, block_hash, parent_hash, cid, td, reward, state_root, tx_root, receipt_root,
+		uncle_root, bloom, timestamp, mh_key FROM eth.header_cids WHERE block_number = $1`, migration_tools.BlockNumber.Uint64())).To(Succeed())
+
+	tx, err := v2DB.Beginx()
+	Expect(err).ToNot(HaveOccurred())
+	for _, block := range blocks {
+		_, err = tx.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`, block.Key, block.Data)
+		Expect(err).ToNot(HaveOccurred())
+	}
+	var nodeID int64
+	Expect(tx.Get(&nodeID, `INSERT INTO public.nodes (client_name, genesis_block, network_id, node_id, chain_id)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`, nodeModel.ClientName, nodeModel.GenesisBlock, nodeModel.NetworkID,
+		nodeModel.NodeID, nodeModel.ChainID)).To(Succeed())
+	var headerID int64
+	Expect(tx.Get(&headerID, `INSERT INTO eth.header_cids (block_number, block_hash, parent_hash, cid, mh_key, td, node_id,
+		reward, state_root, tx_root, receipt_root, uncle_root, bloom, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`, header.BlockNumber, header.BlockHash,
+		header.ParentHash, header.CID, header.MhKey, header.TotalDifficulty, nodeID, header.Reward, header.StateRoot,
+		header.TxRoot, header.RctRoot, header.UncleRoot, header.Bloom, header.Timestamp)).To(Succeed())
+	for _, stateNode := range stateNodes {
+		_, err = tx.Exec(`INSERT INTO eth.state_cids (header_id, state_leaf_key, cid, mh_key, state_path, node_type, diff)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`, headerID, stateNode.StateKey, stateNode.CID, stateNode.MhKey, stateNode.Path,
+			stateNode.NodeType, stateNode.Diff)
+		Expect(err).ToNot(HaveOccurred())
+	}
+	Expect(tx.Commit()).To(Succeed())
+
+	_, err = v3DB.Exec(`DELETE FROM eth.storage_cids`)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = v3DB.Exec(`DELETE FROM eth.state_accounts`)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = v3DB.Exec(`DELETE FROM eth.state_cids`)
+	Expect(err).ToNot(HaveOccurred())
+	return stateNodes
+}
+
+// processRange sends a single range for the table through the provided process,
+// returning every read gap, write gap, and error it emits
+func processRange(process func(ctx context.Context, wg *sync.WaitGroup, tableName migration_tools.TableName,
+	blockRanges <-chan [2]uint64) (chan [2]uint64, chan [2]uint64, chan struct{}, chan error),
+	tableName migration_tools.TableName, rng [2]uint64) ([][2]uint64, [][2]uint64, []error) {
+	wg := new(sync.WaitGroup)
+	blockRangeChan := make(chan [2]uint64)
+	readGapChan, writeGapChan, doneChan, errChan := process(context.Background(), wg, tableName, blockRangeChan)
+
+	var readGaps, writeGaps [][2]uint64
+	var errs []error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case r := <-readGapChan:
+				readGaps = append(readGaps, r)
+			case w := <-writeGapChan:
+				writeGaps = append(writeGaps, w)
+			case err := <-errChan:
+				errs = append(errs, err)
+			case <-doneChan:
+				return
+			}
+		}
+	}()
+	blockRangeChan <- rng
+	close(blockRangeChan)
+	wg.Wait()
+	return readGaps, writeGaps, errs
+}
+
 func prepDatabase(db sql.Database) {
 	tx, err := db.Begin(context.Background())
 	Expect(err).ToNot(HaveOccurred())
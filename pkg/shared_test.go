@@ -56,6 +56,59 @@ var v3DBConfig = postgres.Config{
 	Password:     "",
 }
 
+var v2DBConfig = postgres.Config{
+	Hostname:     "localhost",
+	Port:         5432,
+	DatabaseName: "vulcanize_testing_v2",
+	Username:     "postgres",
+	Password:     "",
+}
+
+// v2SchemaStmts create the subset of the v2 schema that the v2 to v3 specs read from, if it is not already in place
+var v2SchemaStmts = []string{
+	`CREATE SCHEMA IF NOT EXISTS eth`,
+	`CREATE TABLE IF NOT EXISTS public.blocks (
+		key TEXT UNIQUE NOT NULL,
+		data BYTEA NOT NULL)`,
+	`CREATE TABLE IF NOT EXISTS public.nodes (
+		id SERIAL PRIMARY KEY,
+		client_name VARCHAR,
+		genesis_block VARCHAR(66),
+		network_id VARCHAR,
+		node_id VARCHAR(128),
+		chain_id INTEGER DEFAULT 1,
+		CONSTRAINT node_uc UNIQUE (genesis_block, network_id, node_id, chain_id))`,
+	`CREATE TABLE IF NOT EXISTS eth.header_cids (
+		id SERIAL PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		block_hash VARCHAR(66) NOT NULL,
+		parent_hash VARCHAR(66) NOT NULL,
+		cid TEXT NOT NULL,
+		mh_key TEXT NOT NULL REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+		td NUMERIC NOT NULL,
+		node_id INTEGER NOT NULL REFERENCES public.nodes (id) ON DELETE CASCADE,
+		reward NUMERIC NOT NULL,
+		state_root VARCHAR(66) NOT NULL,
+		tx_root VARCHAR(66) NOT NULL,
+		receipt_root VARCHAR(66) NOT NULL,
+		uncle_root VARCHAR(66) NOT NULL,
+		bloom BYTEA NOT NULL,
+		timestamp NUMERIC NOT NULL,
+		times_validated INTEGER NOT NULL DEFAULT 1,
+		base_fee BIGINT,
+		UNIQUE (block_number, block_hash))`,
+	`CREATE TABLE IF NOT EXISTS eth.state_cids (
+		id BIGSERIAL PRIMARY KEY,
+		header_id INTEGER NOT NULL REFERENCES eth.header_cids (id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+		state_leaf_key VARCHAR(66),
+		cid TEXT NOT NULL,
+		mh_key TEXT NOT NULL REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+		state_path BYTEA,
+		node_type INTEGER NOT NULL,
+		diff BOOLEAN NOT NULL DEFAULT FALSE,
+		UNIQUE (header_id, state_path))`,
+}
+
 const writeNodeStr = `INSERT INTO public.nodes (client_name, genesis_block, network_id, node_id, chain_id)
 						VALUES ($1, $2, $3, $4, $5)`
 
@@ -193,3 +246,42 @@ func tearDownSQLXDB(db *sqlx.DB) {
 	err = tx.Commit()
 	Expect(err).ToNot(HaveOccurred())
 }
+
+func prepV2SQLXDB(db *sqlx.DB) {
+	for _, stmt := range v2SchemaStmts {
+		_, err := db.Exec(stmt)
+		Expect(err).ToNot(HaveOccurred())
+	}
+	tearDownV2SQLXDB(db)
+}
+
+// writeV2Header writes a bare v2 header with the provided hash and parent hash at the provided height
+func writeV2Header(db *sqlx.DB, blockNumber uint64, blockHash, parentHash string, timesValidated int) {
+	_, err := db.Exec(`INSERT INTO public.nodes (client_name, genesis_block, network_id, node_id)
+		VALUES ('', '', '', '') ON CONFLICT DO NOTHING`)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = db.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		"mhKey"+blockHash, []byte{})
+	Expect(err).ToNot(HaveOccurred())
+	_, err = db.Exec(`INSERT INTO eth.header_cids (block_number, block_hash, parent_hash, cid, mh_key, td, node_id,
+		reward, state_root, tx_root, receipt_root, uncle_root, bloom, timestamp, times_validated)
+		SELECT $1, $2, $3, $4, $5, 1, nodes.id, 0, '', '', '', '', '', 0, $6 FROM public.nodes LIMIT 1`,
+		blockNumber, blockHash, parentHash, "cid"+blockHash, "mhKey"+blockHash, timesValidated)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func tearDownV2SQLXDB(db *sqlx.DB) {
+	tx, err := db.Begin()
+	Expect(err).ToNot(HaveOccurred())
+
+	_, err = tx.Exec(`DELETE FROM eth.state_cids`)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = tx.Exec(`DELETE FROM eth.header_cids`)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = tx.Exec(`DELETE FROM public.blocks`)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = tx.Exec(`DELETE FROM public.nodes`)
+	Expect(err).ToNot(HaveOccurred())
+	err = tx.Commit()
+	Expect(err).ToNot(HaveOccurred())
+}
@@ -0,0 +1,34 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+const blockRangeClause = "block_number BETWEEN $1 AND $2"
+
+// ExcludingBlocks returns the read statement with the rows of the blocks whose hashes are passed as a third, array,
+// argument left out; it is only supported for statements that filter eth.header_cids by block range
+func (s ReadPgStr) ExcludingBlocks() (ReadPgStr, error) {
+	if !strings.Contains(string(s), "eth.header_cids") || strings.Count(string(s), blockRangeClause) != 1 {
+		return "", fmt.Errorf("statement does not filter eth.header_cids by block range: %s", s)
+	}
+	return ReadPgStr(strings.Replace(string(s), blockRangeClause,
+		blockRangeClause+" AND NOT (header_cids.block_hash = ANY($3))", 1)), nil
+}
@@ -0,0 +1,152 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ConflictPolicy determines how a write handles records that conflict with existing records on the table's primary key
+type ConflictPolicy string
+
+const (
+	ConflictError     ConflictPolicy = "error"
+	ConflictDoNothing ConflictPolicy = "do-nothing"
+	ConflictUpdate    ConflictPolicy = "update"
+)
+
+// NewConflictPolicyFromString returns the ConflictPolicy from the provided string
+func NewConflictPolicyFromString(policyStr string) (ConflictPolicy, error) {
+	switch strings.ToLower(policyStr) {
+	case "", "error":
+		return ConflictError, nil
+	case "do-nothing", "do_nothing", "nothing", "ignore":
+		return ConflictDoNothing, nil
+	case "update", "do-update", "do_update", "upsert":
+		return ConflictUpdate, nil
+	default:
+		return "", fmt.Errorf("unrecognized conflict policy: %s", policyStr)
+	}
+}
+
+// ConflictKey holds the primary key columns of a v3 table, these are the target of the table's ON CONFLICT clause
+type ConflictKey []string
+
+// v3 primary keys
+var (
+	NodesConflictKey              = ConflictKey{"node_id"}
+	EthHeadersConflictKey         = ConflictKey{"block_hash"}
+	EthUnclesConflictKey          = ConflictKey{"block_hash"}
+	EthTransactionsConflictKey    = ConflictKey{"tx_hash"}
+	AccessListElementsConflictKey = ConflictKey{"tx_id", "index"}
+	EthReceiptsConflictKey        = ConflictKey{"tx_id"}
+	EthLogsConflictKey            = ConflictKey{"rct_id", "index"}
+	EthStateConflictKey           = ConflictKey{"header_id", "state_path"}
+	EthAccountsConflictKey        = ConflictKey{"header_id", "state_path"}
+	EthStorageConflictKey         = ConflictKey{"header_id", "state_path", "storage_path"}
+	IPLDsConflictKey              = ConflictKey{"key"}
+)
+
+var insertStmtRegexp = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+([\w.]+)\s*\(([^)]*)\)`)
+
+// Table returns the name of the table the write statement inserts into
+func (s WritePgStr) Table() string {
+	matches := insertStmtRegexp.FindStringSubmatch(string(s))
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+// Columns returns the columns the write statement inserts, in order
+func (s WritePgStr) Columns() []string {
+	matches := insertStmtRegexp.FindStringSubmatch(string(s))
+	if matches == nil {
+		return nil
+	}
+	columns := strings.Split(matches[2], ",")
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+	}
+	return columns
+}
+
+// HasConflictClause returns whether the write statement already handles conflicts itself
+func (s WritePgStr) HasConflictClause() bool {
+	return strings.Contains(strings.ToUpper(string(s)), "ON CONFLICT")
+}
+
+// ConflictClause returns the ON CONFLICT clause of the write statement, or an empty string if it has none
+func (s WritePgStr) ConflictClause() string {
+	idx := strings.Index(strings.ToUpper(string(s)), "ON CONFLICT")
+	if idx < 0 {
+		return ""
+	}
+	return strings.TrimSpace(string(s)[idx:])
+}
+
+// WithConflictPolicy returns the write statement with the ON CONFLICT clause for the provided policy and key appended
+// statements that already contain an ON CONFLICT clause are returned unchanged
+func (s WritePgStr) WithConflictPolicy(policy ConflictPolicy, key ConflictKey) (WritePgStr, error) {
+	if s.HasConflictClause() {
+		return s, nil
+	}
+	clause, err := ConflictClause(policy, key, s.Columns())
+	if err != nil {
+		return "", err
+	}
+	if clause == "" {
+		return s, nil
+	}
+	return s + WritePgStr("\n"+clause), nil
+}
+
+// ConflictClause returns the ON CONFLICT clause for the provided policy, key, and inserted columns
+// for the update policy, every inserted column that is not part of the key is overwritten with the excluded value
+func ConflictClause(policy ConflictPolicy, key ConflictKey, columns []string) (string, error) {
+	switch policy {
+	case "", ConflictError:
+		return "", nil
+	case ConflictDoNothing, ConflictUpdate:
+	default:
+		return "", fmt.Errorf("unrecognized conflict policy: %s", policy)
+	}
+	if len(key) == 0 {
+		return "", fmt.Errorf("conflict policy %s requires a conflict key", policy)
+	}
+	target := fmt.Sprintf("ON CONFLICT (%s)", strings.Join(key, ", "))
+	if policy == ConflictDoNothing {
+		return target + " DO NOTHING", nil
+	}
+	keyColumns := make(map[string]bool, len(key))
+	for _, column := range key {
+		keyColumns[column] = true
+	}
+	updates := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if keyColumns[column] {
+			continue
+		}
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	if len(updates) == 0 {
+		return target + " DO NOTHING", nil
+	}
+	return target + " DO UPDATE SET " + strings.Join(updates, ", "), nil
+}
@@ -0,0 +1,188 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/lib/pq"
+
+	"github.com/vulcanize/migration-tools/pkg/util"
+)
+
+// WriteMode determines how a range of v3 models is written to the new DB
+type WriteMode string
+
+const (
+	WriteModeInsert WriteMode = "insert"
+	WriteModeCopy   WriteMode = "copy"
+)
+
+// NewWriteModeFromString returns the WriteMode from the provided string
+func NewWriteModeFromString(modeStr string) (WriteMode, error) {
+	switch strings.ToLower(modeStr) {
+	case "", "insert":
+		return WriteModeInsert, nil
+	case "copy":
+		return WriteModeCopy, nil
+	default:
+		return "", fmt.Errorf("unrecognized write mode: %s", modeStr)
+	}
+}
+
+const copyStagingTableName = "migration_tools_copy_staging"
+
+// CopyWriter struct for writing v3 DB models using COPY FROM STDIN
+type CopyWriter struct {
+	db *sqlx.DB
+}
+
+// NewCopyWriter returns a new CopyWriter
+func NewCopyWriter(db *sqlx.DB) *CopyWriter {
+	return &CopyWriter{db: db}
+}
+
+// Write satisfies interfaces.Writer for v3 database
+// Write streams the provided models into the table and columns of the INSERT statement using COPY FROM STDIN
+// if the statement carries an ON CONFLICT clause, the models are copied into a temporary staging table
+// and moved into the target table with an INSERT ... SELECT that applies the same clause
+// as with Writer, all the models are written within a single transaction
+func (w *CopyWriter) Write(pgStr WritePgStr, models interface{}) error {
+	return w.WriteWithHook(pgStr, models, nil)
+}
+
+// WriteWithHook satisfies interfaces.HookedWriter
+// WriteWithHook is Write, but runs the hook within the transaction before it is committed
+func (w *CopyWriter) WriteWithHook(pgStr WritePgStr, models interface{}, hook util.TxHook) (err error) {
+	table, columns := pgStr.Table(), pgStr.Columns()
+	if table == "" || len(columns) == 0 {
+		return fmt.Errorf("unable to derive the table and columns to copy into from statement: %s", pgStr)
+	}
+	rows, err := w.copyRows(models, columns)
+	if err != nil {
+		return err
+	}
+	tx, err := w.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			util.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			util.Rollback(tx)
+		}
+	}()
+	var copyStr string
+	if pgStr.HasConflictClause() {
+		createStagingStr := fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP",
+			copyStagingTableName, table)
+		if _, err = tx.Exec(createStagingStr); err != nil {
+			return err
+		}
+		copyStr = pq.CopyIn(copyStagingTableName, columns...)
+	} else {
+		schema, name := splitTableName(table)
+		copyStr = pq.CopyInSchema(schema, name, columns...)
+	}
+	if err = copyInTx(tx, copyStr, rows); err != nil {
+		return err
+	}
+	if pgStr.HasConflictClause() {
+		columnList := strings.Join(columns, ", ")
+		moveStr := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s %s",
+			table, columnList, columnList, copyStagingTableName, pgStr.ConflictClause())
+		if _, err = tx.Exec(moveStr); err != nil {
+			return err
+		}
+	}
+	if hook != nil {
+		if err = hook(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyInTx streams the rows through the prepared COPY statement
+func copyInTx(tx *sqlx.Tx, copyStr string, rows [][]interface{}) error {
+	stmt, err := tx.Prepare(copyStr)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	// the final Exec without arguments flushes the buffered rows and completes the COPY
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+// copyRows extracts the values of the provided columns from each model, using the same db tag mapping as NamedQuery
+func (w *CopyWriter) copyRows(models interface{}, columns []string) ([][]interface{}, error) {
+	modelsVal := reflect.Indirect(reflect.ValueOf(models))
+	if modelsVal.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("expected a slice of models, got %T", models)
+	}
+	elemType := reflectx.Deref(modelsVal.Type().Elem())
+	traversals := w.db.Mapper.TraversalsByName(elemType, columns)
+	for i, traversal := range traversals {
+		if len(traversal) == 0 {
+			return nil, fmt.Errorf("could not find column %s in model type %s", columns[i], elemType)
+		}
+	}
+	rows := make([][]interface{}, modelsVal.Len())
+	for i := range rows {
+		modelVal := reflect.Indirect(modelsVal.Index(i))
+		row := make([]interface{}, len(columns))
+		for j, traversal := range traversals {
+			value, err := driver.DefaultParameterConverter.ConvertValue(
+				reflectx.FieldByIndexesReadOnly(modelVal, traversal).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("unable to convert column %s of model %d: %v", columns[j], i, err)
+			}
+			row[j] = value
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// splitTableName splits a schema qualified table name into its schema and table
+func splitTableName(table string) (string, string) {
+	if sep := strings.Index(table, "."); sep >= 0 {
+		return table[:sep], table[sep+1:]
+	}
+	return "public", table
+}
+
+// Close satisfies io.Closer
+func (w *CopyWriter) Close() error {
+	return w.db.Close()
+}
@@ -33,6 +33,18 @@ const (
 										WHERE log_cids.leaf_mh_key = public.blocks.key
 										)
 									AND block_number BETWEEN $1 AND $2`
+	PgReadBrokenTransactionsStr ReadPgStr = `SELECT eth.header_cids.tx_root, transaction_cids.header_id, transaction_cids.tx_hash,
+									transaction_cids.cid, transaction_cids.dst, transaction_cids.src, transaction_cids.index,
+									transaction_cids.mh_key, transaction_cids.tx_data, transaction_cids.tx_type,
+									transaction_cids.value
+									FROM eth.transaction_cids
+									INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+									WHERE NOT EXISTS (
+										SELECT
+										FROM public.blocks
+										WHERE transaction_cids.mh_key = public.blocks.key
+										)
+									AND block_number BETWEEN $1 AND $2`
 	PgReadNodesStr ReadPgStr = `SELECT client_name, genesis_block, network_id, node_id, nodes_chain_id
 						FROM public.nodes`
 
@@ -56,8 +68,8 @@ const (
 	PgReadEthStateStr ReadPgStr = `SELECT eth.header_cids.block_number, eth.header_cids.block_hash, eth.state_cids.*
 						FROM eth.state_cids
 						INNER JOIN eth.header_cids ON (state_cids.header_id = header_cids.id)
-						WHERE block_number = $1
-						ORDER BY block_number ASC`
+						WHERE block_number BETWEEN $1 AND $2
+						ORDER BY block_number ASC, state_cids.state_path ASC`
 
 	PgReadEthReceiptsStr ReadPgStr = `SELECT eth.transaction_cids.tx_hash, eth.receipt_cids.*
 							FROM eth.receipt_cids
@@ -78,6 +90,11 @@ const (
 							WHERE block_number BETWEEN $1 AND $2
 							ORDER BY block_number ASC`
 
+	PgReadHeaderLinksStr ReadPgStr = `SELECT block_number, block_hash, parent_hash, times_validated
+							FROM eth.header_cids
+							WHERE block_number BETWEEN $1 AND $2
+							ORDER BY block_number DESC, times_validated DESC, block_hash ASC`
+
 	PgReadEthAccountsStr ReadPgStr = `SELECT eth.header_cids.block_number, eth.header_cids.block_hash, eth.state_cids.state_path, eth.state_accounts.*
 							FROM eth.state_accounts
 							INNER JOIN eth.state_cids ON (state_accounts.state_id = state_cids.id)
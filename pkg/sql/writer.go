@@ -18,6 +18,8 @@ package sql
 
 import (
 	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/migration-tools/pkg/util"
 )
 
 // Writer struct for writing v3 DB public.nodes models
@@ -31,12 +33,39 @@ func NewWriter(db *sqlx.DB) *Writer {
 }
 
 // Write satisfies interfaces.Writer for v3 database
+// Write inserts all the provided models within a single transaction, so that either all or none of them are written
 func (w *Writer) Write(pgStr WritePgStr, models interface{}) error {
-	rows, err := w.db.NamedQuery(string(pgStr), models)
+	return w.WriteWithHook(pgStr, models, nil)
+}
+
+// WriteWithHook satisfies interfaces.HookedWriter
+// WriteWithHook is Write, but runs the hook within the transaction before it is committed
+func (w *Writer) WriteWithHook(pgStr WritePgStr, models interface{}, hook util.TxHook) (err error) {
+	tx, err := w.db.Beginx()
 	if err != nil {
 		return err
 	}
-	return rows.Close()
+	defer func() {
+		if p := recover(); p != nil {
+			util.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			util.Rollback(tx)
+		}
+	}()
+	rows, err := tx.NamedQuery(string(pgStr), models)
+	if err != nil {
+		return err
+	}
+	if err = rows.Close(); err != nil {
+		return err
+	}
+	if hook != nil {
+		if err = hook(tx); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // Close satisfies io.Closer
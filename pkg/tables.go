@@ -18,12 +18,13 @@ package migration_tools
 
 import (
 	"fmt"
-	"strings"
+	"io"
 
 	"github.com/vulcanize/migration-tools/pkg/csv"
 	"github.com/vulcanize/migration-tools/pkg/sql"
 
 	"github.com/vulcanize/migration-tools/pkg/eth_logs/repair"
+	transactions_repair "github.com/vulcanize/migration-tools/pkg/eth_transactions/repair"
 
 	"github.com/vulcanize/migration-tools/pkg/eth_access_lists"
 	"github.com/vulcanize/migration-tools/pkg/eth_accounts"
@@ -35,6 +36,7 @@ import (
 	"github.com/vulcanize/migration-tools/pkg/eth_transactions"
 	"github.com/vulcanize/migration-tools/pkg/eth_uncles"
 	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
 	"github.com/vulcanize/migration-tools/pkg/public_nodes"
 )
 
@@ -50,136 +52,261 @@ const (
 	EthReceipts           TableName = "receipt_cids"
 	EthLogs               TableName = "log_cids"
 	EthLogsRepair         TableName = "log_cids_repair"
+	EthTransactionsRepair TableName = "transaction_cids_repair"
 	EthState              TableName = "state_cids"
 	EthAccounts           TableName = "state_accounts"
 	EthStorage            TableName = "storage_cids"
 	Unknown               TableName = "unknown"
 )
 
-// NewTableNameFromString returns the TableName from the provided string
+// NewTableNameFromString returns the TableName of the registered table with the provided name or alias
 func NewTableNameFromString(tableNameStr string) (TableName, error) {
-	switch strings.ToLower(tableNameStr) {
-	case "public.nodes", "nodes":
-		return PublicNodes, nil
-	case "eth.header_cids", "header_cids", "headers":
-		return EthHeaders, nil
-	case "eth.uncle_cids", "uncle_cids", "uncles":
-		return EthUncles, nil
-	case "eth.transaction_cids", "transaction_cids", "transactions", "txs", "trxs":
-		return EthTransactions, nil
-	case "eth.access_list_elements", "access_list_elements", "access_list":
-		return EthAccessListElements, nil
-	case "eth.receipt_cids", "receipt_cids", "receipts", "rcts":
-		return EthReceipts, nil
-	case "eth.log_cids", "log_cids", "logs":
-		return EthLogs, nil
-	case "eth.state_cids", "state_cids", "state":
-		return EthState, nil
-	case "eth.state_accounts", "state_accounts", "accounts":
-		return EthAccounts, nil
-	case "eth.storage_cids", "storage_cids", "storage":
-		return EthStorage, nil
-	case "eth.log_cids.repair", "log_repair", "log_cids_repair":
-		return EthLogsRepair, nil
-	default:
-		return Unknown, fmt.Errorf("unrecognized table name: %s", tableNameStr)
+	if tableName, ok := lookupTableAlias(tableNameStr); ok {
+		return tableName, nil
 	}
+	return Unknown, fmt.Errorf("unrecognized table name: %s", tableNameStr)
 }
 
 // NewTableTransformerSet inits and returns a set of Transformers for the provided tables
 func NewTableTransformerSet(tables []TableName) map[TableName]interfaces.Transformer {
 	tableReaderSet := make(map[TableName]interfaces.Transformer, len(tables))
 	for _, tableName := range tables {
-		tableReaderSet[tableName] = tableTransformerConstructorMappings[tableName]()
+		tableReaderSet[tableName] = NewTableTransformer(tableName)
 	}
 	return tableReaderSet
 }
 
 // NewTableTransformer inits and returns a Transformers for the provided tables
 func NewTableTransformer(table TableName) interfaces.Transformer {
-	return tableTransformerConstructorMappings[table]()
+	spec, _ := LookupTableSpec(table)
+	return spec.Transformer()
 }
 
 // NewTableReadModels returns an allocation for the read DB models of the provided table
 func NewTableReadModels(tableName TableName) (interface{}, error) {
-	switch tableName {
-	case PublicNodes:
-		return new([]public_nodes.NodeModel), nil
-	case EthHeaders:
-		return new([]eth_headers.HeaderModelV2WithMeta), nil
-	case EthUncles:
-		return new([]eth_uncles.UncleModelV2WithMeta), nil
-	case EthTransactions:
-		return new([]eth_transactions.TransactionModelV2WithMeta), nil
-	case EthAccessListElements:
-		return new([]eth_access_lists.AccessListElementModelV2WithMeta), nil
-	case EthReceipts:
-		return new([]eth_receipts.ReceiptModelV2WithMeta), nil
-	case EthLogs:
-		return new([]eth_logs.LogModelV2WithMeta), nil
-	case EthLogsRepair:
-		return new([]eth_logs.LogModelV3), nil
-	case EthAccounts:
-		return new([]eth_accounts.AccountModelV2WithMeta), nil
-	case EthStorage:
-		return new([]eth_storage.StorageModelV2WithMeta), nil
-	default:
+	spec, ok := LookupTableSpec(tableName)
+	if !ok {
 		return nil, fmt.Errorf("unsupported table name: %s", tableName)
 	}
+	return spec.NewReadModels(), nil
 }
 
-var tableTransformerConstructorMappings = map[TableName]interfaces.TransformerConstructor{
-	PublicNodes:           public_nodes.NewTransformer,
-	EthHeaders:            eth_headers.NewTransformer,
-	EthUncles:             eth_uncles.NewTransformer,
-	EthTransactions:       eth_transactions.NewTransformer,
-	EthAccessListElements: eth_access_lists.NewTransformer,
-	EthReceipts:           eth_receipts.NewTransformer,
-	EthLogs:               eth_logs.NewTransformer,
-	EthLogsRepair:         repair.NewTransformer,
-	EthState:              eth_state.NewTransformer,
-	EthAccounts:           eth_accounts.NewTransformer,
-	EthStorage:            eth_storage.NewTransformer,
+// NewTableWritePgStr returns the write statement for the provided table, with the ON CONFLICT clause for the provided policy
+func NewTableWritePgStr(tableName TableName, policy sql.ConflictPolicy) (sql.WritePgStr, error) {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok || spec.WriteQuery == "" {
+		return "", fmt.Errorf("unsupported table name: %s", tableName)
+	}
+	return spec.WriteQuery.WithConflictPolicy(policy, spec.ConflictKey)
 }
 
-var tableReaderStrMappings = map[TableName]sql.ReadPgStr{
-	PublicNodes:           sql.PgReadNodesStr,
-	EthHeaders:            sql.PgReadEthHeadersStr,
-	EthUncles:             sql.PgReadEthUnclesStr,
-	EthTransactions:       sql.PgReadEthTransactionsStr,
-	EthAccessListElements: sql.PgReadAccessListElementsStr,
-	EthReceipts:           sql.PgReadEthReceiptsStr,
-	EthLogs:               sql.PgReadEthLogsStr,
-	EthLogsRepair:         sql.PgReadBrokenLogsStr,
-	EthState:              sql.PgReadEthStateStr,
-	EthAccounts:           sql.PgReadEthAccountsStr,
-	EthStorage:            sql.PgReadEthStorageStr,
+// NewTableCSVWriter returns a csv.Writer for the provided table that writes rotating csv files into the provided directory
+func NewTableCSVWriter(tableName TableName, dir string, maxBytes, maxRanges uint64) (*csv.RotatingWriter, error) {
+	spec, ok := LookupTableSpec(tableName)
+	if !ok || spec.CSVWriter == nil {
+		return nil, fmt.Errorf("unsupported table name: %s", tableName)
+	}
+	return csv.NewRotatingWriter(dir, string(tableName), spec.CSVWriter, maxBytes, maxRanges)
 }
 
-var tableWriterStrMappings = map[TableName]sql.WritePgStr{
-	PublicNodes:           sql.PgWriteNodesStr,
-	EthHeaders:            sql.PgWriteEthHeadersStr,
-	EthUncles:             sql.PgWriteEthUnclesStr,
-	EthTransactions:       sql.PgWriteEthTransactionsStr,
-	EthAccessListElements: sql.PgWriteAccessListElementsStr,
-	EthReceipts:           sql.PgWriteEthReceiptsStr,
-	EthLogs:               sql.PgWriteEthLogsStr,
-	EthLogsRepair:         sql.PgWriteIPLDsStr,
-	EthState:              sql.PgWriteEthStateStr,
-	EthAccounts:           sql.PgWriteEthAccountsStr,
-	EthStorage:            sql.PgWriteEthStorageStr,
+func init() {
+	for _, spec := range builtinTableSpecs {
+		MustRegisterTable(spec)
+	}
 }
 
-var csvWriterStrMappings = map[TableName]csv.WriteCSVStr{
-	PublicNodes:           csv.CSVWriteNodesStr,
-	EthHeaders:            csv.CSVWriteEthHeadersStr,
-	EthUncles:             csv.CSVWriteEthUnclesStr,
-	EthTransactions:       csv.CSVWriteEthTransactionsStr,
-	EthAccessListElements: csv.CSVWriteAccessListElementsStr,
-	EthReceipts:           csv.CSVWriteEthReceiptsStr,
-	EthLogs:               csv.CSVWriteEthLogsStr,
-	EthLogsRepair:         csv.CSVWriteIPLDsStr,
-	EthState:              csv.CSVWriteEthStateStr,
-	EthAccounts:           csv.CSVWriteEthAccountsStr,
-	EthStorage:            csv.CSVWriteEthStorageStr,
+// newIPLDsCSVWriter is the csv.WriterConstructor of the IPLD repairs, which write to public.blocks
+func newIPLDsCSVWriter(dst io.WriteCloser) csv.Writer { return public_blocks.NewWriter(dst) }
+
+var builtinTableSpecs = []TableSpec{
+	NewTypedTableSpec(TableSpec{
+		Name:          PublicNodes,
+		Aliases:       []string{"public.nodes"},
+		ReadQuery:     sql.PgReadNodesStr,
+		WriteQuery:    sql.PgWriteNodesStr,
+		ConflictKey:   sql.NodesConflictKey,
+		CSVWriteQuery: csv.CSVWriteNodesStr,
+		CSVWriter:     func(dst io.WriteCloser) csv.Writer { return public_nodes.NewWriter(dst) },
+		// public nodes are not segmented by block height at all, so they are processed with the ranges of the v2 headers
+		Unsampled:    true,
+		HeightFilter: `TRUE`,
+	}, public_nodes.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:                 EthHeaders,
+		Aliases:              []string{"eth.header_cids", "headers"},
+		ReadQuery:            sql.PgReadEthHeadersStr,
+		WriteQuery:           sql.PgWriteEthHeadersStr,
+		ConflictKey:          sql.EthHeadersConflictKey,
+		CSVWriteQuery:        csv.CSVWriteEthHeadersStr,
+		CSVWriter:            func(dst io.WriteCloser) csv.Writer { return eth_headers.NewWriter(dst) },
+		GapChecked:           true,
+		Dependencies:         []TableName{PublicNodes}, // header_cids.node_id -> nodes.node_id
+		CanonicalFiltered:    true,
+		HeightFilter:         `TRUE`,
+		MigratedHeightFilter: `TRUE`,
+	}, eth_headers.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthUncles,
+		Aliases:           []string{"eth.uncle_cids", "uncles"},
+		ReadQuery:         sql.PgReadEthUnclesStr,
+		WriteQuery:        sql.PgWriteEthUnclesStr,
+		ConflictKey:       sql.EthUnclesConflictKey,
+		CSVWriteQuery:     csv.CSVWriteEthUnclesStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_uncles.NewWriter(dst) },
+		Dependencies:      []TableName{EthHeaders}, // uncle_cids.header_id -> header_cids.block_hash
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.uncle_cids
+				WHERE uncle_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.uncle_cids
+				WHERE uncle_cids.header_id = header_cids.block_hash)`,
+	}, eth_uncles.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthTransactions,
+		Aliases:           []string{"eth.transaction_cids", "transactions", "txs", "trxs"},
+		ReadQuery:         sql.PgReadEthTransactionsStr,
+		WriteQuery:        sql.PgWriteEthTransactionsStr,
+		ConflictKey:       sql.EthTransactionsConflictKey,
+		CSVWriteQuery:     csv.CSVWriteEthTransactionsStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_transactions.NewWriter(dst) },
+		Dependencies:      []TableName{EthHeaders}, // transaction_cids.header_id -> header_cids.block_hash
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.transaction_cids
+				WHERE transaction_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.transaction_cids
+				WHERE transaction_cids.header_id = header_cids.block_hash)`,
+	}, eth_transactions.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthAccessListElements,
+		Aliases:           []string{"eth.access_list_elements", "access_list"},
+		ReadQuery:         sql.PgReadAccessListElementsStr,
+		WriteQuery:        sql.PgWriteAccessListElementsStr,
+		ConflictKey:       sql.AccessListElementsConflictKey,
+		CSVWriteQuery:     csv.CSVWriteAccessListElementsStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_access_lists.NewWriter(dst) },
+		Dependencies:      []TableName{EthTransactions}, // access_list_elements.tx_id -> transaction_cids.tx_hash
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.access_list_elements
+				INNER JOIN eth.transaction_cids ON (access_list_elements.tx_id = transaction_cids.id)
+				WHERE transaction_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.access_list_elements
+				INNER JOIN eth.transaction_cids ON (access_list_elements.tx_id = transaction_cids.tx_hash)
+				WHERE transaction_cids.header_id = header_cids.block_hash)`,
+	}, eth_access_lists.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthReceipts,
+		Aliases:           []string{"eth.receipt_cids", "receipts", "rcts"},
+		ReadQuery:         sql.PgReadEthReceiptsStr,
+		WriteQuery:        sql.PgWriteEthReceiptsStr,
+		ConflictKey:       sql.EthReceiptsConflictKey,
+		CSVWriteQuery:     csv.CSVWriteEthReceiptsStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_receipts.NewWriter(dst) },
+		Dependencies:      []TableName{EthTransactions}, // receipt_cids.tx_id -> transaction_cids.tx_hash
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.receipt_cids
+				INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.id)
+				WHERE transaction_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.receipt_cids
+				INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.tx_hash)
+				WHERE transaction_cids.header_id = header_cids.block_hash)`,
+	}, eth_receipts.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthLogs,
+		Aliases:           []string{"eth.log_cids", "logs"},
+		ReadQuery:         sql.PgReadEthLogsStr,
+		WriteQuery:        sql.PgWriteEthLogsStr,
+		ConflictKey:       sql.EthLogsConflictKey,
+		CSVWriteQuery:     csv.CSVWriteEthLogsStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_logs.NewWriter(dst) },
+		Dependencies:      []TableName{EthReceipts}, // log_cids.rct_id -> receipt_cids.tx_id
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.log_cids
+				INNER JOIN eth.receipt_cids ON (log_cids.receipt_id = receipt_cids.id)
+				INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.id)
+				WHERE transaction_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.log_cids
+				INNER JOIN eth.transaction_cids ON (log_cids.rct_id = transaction_cids.tx_hash)
+				WHERE transaction_cids.header_id = header_cids.block_hash)`,
+	}, eth_logs.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:          EthState,
+		Aliases:       []string{"eth.state_cids", "state"},
+		ReadQuery:     sql.PgReadEthStateStr,
+		WriteQuery:    sql.PgWriteEthStateStr,
+		ConflictKey:   sql.EthStateConflictKey,
+		CSVWriteQuery: csv.CSVWriteEthStateStr,
+		CSVWriter:     func(dst io.WriteCloser) csv.Writer { return eth_state.NewWriter(dst) },
+		// every block has a state node for the miner's reward at least
+		GapChecked:        true,
+		Dependencies:      []TableName{EthHeaders}, // state_cids.header_id -> header_cids.block_hash
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.state_cids
+				WHERE state_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.state_cids
+				WHERE state_cids.header_id = header_cids.block_hash)`,
+	}, eth_state.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthAccounts,
+		Aliases:           []string{"eth.state_accounts", "accounts"},
+		ReadQuery:         sql.PgReadEthAccountsStr,
+		WriteQuery:        sql.PgWriteEthAccountsStr,
+		ConflictKey:       sql.EthAccountsConflictKey,
+		CSVWriteQuery:     csv.CSVWriteEthAccountsStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_accounts.NewWriter(dst) },
+		GapChecked:        true,
+		Dependencies:      []TableName{EthState}, // state_accounts (header_id, state_path) -> state_cids
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.state_accounts
+				INNER JOIN eth.state_cids ON (state_accounts.state_id = state_cids.id)
+				WHERE state_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.state_accounts
+				WHERE state_accounts.header_id = header_cids.block_hash)`,
+	}, eth_accounts.NewTypedTransformer),
+	NewTypedTableSpec(TableSpec{
+		Name:              EthStorage,
+		Aliases:           []string{"eth.storage_cids", "storage"},
+		ReadQuery:         sql.PgReadEthStorageStr,
+		WriteQuery:        sql.PgWriteEthStorageStr,
+		ConflictKey:       sql.EthStorageConflictKey,
+		CSVWriteQuery:     csv.CSVWriteEthStorageStr,
+		CSVWriter:         func(dst io.WriteCloser) csv.Writer { return eth_storage.NewWriter(dst) },
+		Dependencies:      []TableName{EthState}, // storage_cids (header_id, state_path) -> state_cids
+		CanonicalFiltered: true,
+		HeightFilter: `EXISTS (SELECT FROM eth.storage_cids
+				INNER JOIN eth.state_cids ON (storage_cids.state_id = state_cids.id)
+				WHERE state_cids.header_id = header_cids.id)`,
+		MigratedHeightFilter: `EXISTS (SELECT FROM eth.storage_cids
+				WHERE storage_cids.header_id = header_cids.block_hash)`,
+	}, eth_storage.NewTypedTransformer),
+	// the IPLD repairs read the broken v3 rows back out of the new DB, so they follow the tables they repair,
+	// and are not segmented by the row volume of the old DB
+	NewTypedTableSpec(TableSpec{
+		Name:          EthLogsRepair,
+		Aliases:       []string{"eth.log_cids.repair", "log_repair"},
+		ReadQuery:     sql.PgReadBrokenLogsStr,
+		WriteQuery:    sql.PgWriteIPLDsStr,
+		ConflictKey:   sql.IPLDsConflictKey,
+		CSVWriteQuery: csv.CSVWriteIPLDsStr,
+		CSVWriter:     newIPLDsCSVWriter,
+		Dependencies:  []TableName{EthLogs},
+		Unsampled:     true,
+		// the log repair reads the v3 logs it repairs, so every range they are found at is processed
+		HeightFilter: `EXISTS (SELECT FROM eth.log_cids
+				INNER JOIN eth.transaction_cids ON (log_cids.rct_id = transaction_cids.tx_hash)
+				WHERE transaction_cids.header_id = header_cids.block_hash)`,
+	}, repair.NewTypedTransformer),
+	// the transaction repair reads the v3 rows it repairs as well, but only the ranges with missing IPLDs are processed
+	NewTypedTableSpec(TableSpec{
+		Name:          EthTransactionsRepair,
+		Aliases:       []string{"eth.transaction_cids.repair", "transaction_repair", "tx_repair"},
+		ReadQuery:     sql.PgReadBrokenTransactionsStr,
+		WriteQuery:    sql.PgWriteIPLDsStr,
+		ConflictKey:   sql.IPLDsConflictKey,
+		CSVWriteQuery: csv.CSVWriteIPLDsStr,
+		CSVWriter:     newIPLDsCSVWriter,
+		Dependencies:  []TableName{EthTransactions},
+		Unsampled:     true,
+		HeightFilter: `EXISTS (SELECT FROM eth.transaction_cids
+				WHERE transaction_cids.header_id = header_cids.block_hash
+				AND NOT EXISTS (SELECT FROM public.blocks WHERE transaction_cids.mh_key = blocks.key))`,
+	}, transactions_repair.NewTypedTransformer),
 }
@@ -0,0 +1,210 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/statediff/indexer/database/sql/postgres"
+	"github.com/ethereum/go-ethereum/statediff/indexer/node"
+	"github.com/jmoiron/sqlx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+)
+
+var _ = Describe("public_blocks.GetPageSegments", func() {
+	It("covers every page up to and including the max page", func() {
+		Expect(public_blocks.GetPageSegments(2500, 1000)).To(Equal([][2]uint64{{0, 999}, {1000, 1999}, {2000, 2500}}))
+		Expect(public_blocks.GetPageSegments(2000, 1000)).To(Equal([][2]uint64{{0, 999}, {1000, 1999}, {2000, 2000}}))
+		Expect(public_blocks.GetPageSegments(1999, 1000)).To(Equal([][2]uint64{{0, 999}, {1000, 1999}}))
+		Expect(public_blocks.GetPageSegments(0, 1000)).To(Equal([][2]uint64{{0, 0}}))
+	})
+
+	It("leaves out the page ranges already checkpointed as transferred", func() {
+		segments := public_blocks.GetPageSegments(2999, 1000)
+		committed := [][2]uint64{{0, 999}, {1500, 1999}}
+		Expect(migration_tools.SubtractRanges(segments, committed)).To(Equal([][2]uint64{{1000, 1499}, {2000, 2999}}))
+	})
+})
+
+var _ = Describe("public.blocks transfer retries", func() {
+	var (
+		attempts map[[2]uint64]int
+		lock     sync.Mutex
+		report   *bytes.Buffer
+	)
+	BeforeEach(func() {
+		attempts = make(map[[2]uint64]int)
+		report = new(bytes.Buffer)
+	})
+
+	// failingTransfer returns a transferPages stub that fails the page range until it has been attempted failures times
+	failingTransfer := func(failing [2]uint64, failures int) func(firstPage, lastPage uint64) error {
+		return func(firstPage, lastPage uint64) error {
+			lock.Lock()
+			defer lock.Unlock()
+			pages := [2]uint64{firstPage, lastPage}
+			attempts[pages]++
+			if pages == failing && attempts[pages] <= failures {
+				return errors.New("connection reset")
+			}
+			return nil
+		}
+	}
+
+	transfer := func(transferPages func(firstPage, lastPage uint64) error, retries int) ([][2]uint64, []error) {
+		wg := new(sync.WaitGroup)
+		gapChan, doneChan, errChan := migration_tools.TransferSegments(context.Background(), wg, transferPages,
+			public_blocks.GetPageSegments(5, 2), retries, time.Millisecond, migration_tools.NewReportWriter(report))
+		var gaps [][2]uint64
+		var errs []error
+		for {
+			select {
+			case gap := <-gapChan:
+				gaps = append(gaps, gap)
+			case err := <-errChan:
+				errs = append(errs, err)
+			case <-doneChan:
+				wg.Wait()
+				return gaps, errs
+			}
+		}
+	}
+
+	It("retries a failed page range until it is transferred, without reporting it as a gap", func() {
+		gaps, errs := transfer(failingTransfer([2]uint64{2, 3}, 2), 3)
+		Expect(errs).To(BeEmpty())
+		Expect(gaps).To(BeEmpty())
+		Expect(attempts).To(Equal(map[[2]uint64]int{{0, 1}: 1, {2, 3}: 3, {4, 5}: 1}))
+		Expect(report.String()).To(BeEmpty())
+	})
+
+	It("reports a page range as a gap once its retries run out", func() {
+		gaps, errs := transfer(failingTransfer([2]uint64{2, 3}, 3), 2)
+		Expect(errs).To(HaveLen(1))
+		Expect(gaps).To(Equal([][2]uint64{{2, 3}}))
+		Expect(attempts).To(Equal(map[[2]uint64]int{{0, 1}: 1, {2, 3}: 3, {4, 5}: 1}))
+		Expect(report.String()).To(ContainSubstring(`"range":[2,3],"kind":"transfer","error":"connection reset"`))
+	})
+})
+
+var _ = Describe("Direct public.blocks transfer", Serial, func() {
+	var (
+		v2DB     *sqlx.DB
+		expected []public_blocks.IPLDModel
+	)
+	BeforeEach(func() {
+		driver, err := postgres.NewSQLXDriver(context.Background(), v3DBConfig, node.Info{})
+		Expect(err).ToNot(HaveOccurred())
+		sqlDB = postgres.NewPostgresDB(driver)
+		prepDatabase(sqlDB)
+		sqlxDB, err = sqlx.Connect("postgres", v3DBConfig.DbConnectionString())
+		Expect(err).ToNot(HaveOccurred())
+		v2DB, err = sqlx.Connect("postgres", v2DBConfig.DbConnectionString())
+		Expect(err).ToNot(HaveOccurred())
+		prepV2SQLXDB(v2DB)
+
+		expected = nil
+		for i := 0; i < 3; i++ {
+			model := public_blocks.IPLDModel{Key: "/blocks/" + randomHash().Hex(), Data: randomBytes()}
+			_, err = v2DB.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2)`, model.Key, model.Data)
+			Expect(err).ToNot(HaveOccurred())
+			expected = append(expected, model)
+		}
+		// a key that is already in the new DB is left as it is
+		_, err = sqlxDB.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2)`, expected[0].Key, expected[0].Data)
+		Expect(err).ToNot(HaveOccurred())
+
+		migrator, err = migration_tools.NewMigrator(context.Background(), &migration_tools.Config{
+			ReadDB:          v2DBConfig,
+			WriteDB:         v3DBConfig,
+			WorkersPerTable: 2,
+			Checkpoint:      true,
+			TransferMode:    public_blocks.TransferModeDirect,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		// the checkpoint table is created by the Migrator
+		_, err = sqlxDB.Exec(`DELETE FROM migration_tools.progress`)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		migrator.Close()
+		_, err := sqlxDB.Exec(`DELETE FROM migration_tools.progress`)
+		Expect(err).ToNot(HaveOccurred())
+		tearDownV2SQLXDB(v2DB)
+		Expect(v2DB.Close()).To(Succeed())
+		Expect(sqlxDB.Close()).To(Succeed())
+		tearDown()
+	})
+
+	transfer := func() ([][2]uint64, []error) {
+		wg := new(sync.WaitGroup)
+		gapChan, doneChan, errChan, err := migrator.Transfer(context.Background(), wg, "", 1, 0, 0)
+		Expect(err).ToNot(HaveOccurred())
+		var gaps [][2]uint64
+		var errs []error
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case gap := <-gapChan:
+					gaps = append(gaps, gap)
+				case err := <-errChan:
+					errs = append(errs, err)
+				case <-doneChan:
+					return
+				}
+			}
+		}()
+		wg.Wait()
+		return gaps, errs
+	}
+
+	It("reads the pages from the old DB and writes them to the new DB, checkpointing each one", Label("test"), func() {
+		gaps, errs := transfer()
+		Expect(errs).To(BeEmpty())
+		Expect(gaps).To(BeEmpty())
+
+		var transferred []public_blocks.IPLDModel
+		Expect(sqlxDB.Select(&transferred, `SELECT key, data FROM public.blocks`)).To(Succeed())
+		Expect(transferred).To(ConsistOf(expected))
+
+		checkpointer, err := migration_tools.NewCheckpointer(sqlxDB)
+		Expect(err).ToNot(HaveOccurred())
+		committed, err := checkpointer.Committed(migration_tools.PublicBlocksCheckpoint)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(committed).To(Equal([][2]uint64{{0, 0}}))
+
+		// a restarted transfer skips the checkpointed pages
+		_, err = sqlxDB.Exec(`DELETE FROM public.blocks`)
+		Expect(err).ToNot(HaveOccurred())
+		gaps, errs = transfer()
+		Expect(errs).To(BeEmpty())
+		Expect(gaps).To(BeEmpty())
+		transferred = nil
+		Expect(sqlxDB.Select(&transferred, `SELECT key, data FROM public.blocks`)).To(Succeed())
+		Expect(transferred).To(BeEmpty())
+	})
+})
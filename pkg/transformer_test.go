@@ -0,0 +1,272 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/eth_accounts"
+	"github.com/vulcanize/migration-tools/pkg/eth_headers"
+	"github.com/vulcanize/migration-tools/pkg/eth_state"
+	"github.com/vulcanize/migration-tools/pkg/eth_transactions"
+	transactions_repair "github.com/vulcanize/migration-tools/pkg/eth_transactions/repair"
+	"github.com/vulcanize/migration-tools/pkg/eth_uncles"
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/typed"
+)
+
+var _ = Describe("eth_state.Transformer", func() {
+	stateNode := func(blockNumber string, path byte) eth_state.StateModelV2WithMeta {
+		return eth_state.StateModelV2WithMeta{
+			BlockHash:   "mockHash" + blockNumber,
+			BlockNumber: blockNumber,
+			StateModelV2: eth_state.StateModelV2{
+				Path: []byte{path},
+				CID:  "mockCID",
+			},
+		}
+	}
+
+	It("allocates read models for the table", func() {
+		models, err := migration_tools.NewTableReadModels(migration_tools.EthState)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(models).To(BeAssignableToTypeOf(new([]eth_state.StateModelV2WithMeta)))
+	})
+
+	It("transforms every state node of a block without reporting gaps", func() {
+		v2Models := []eth_state.StateModelV2WithMeta{
+			stateNode("10", 0), stateNode("10", 1), stateNode("10", 2),
+			stateNode("11", 0),
+			stateNode("12", 0), stateNode("12", 1),
+		}
+		v3Models, gaps, err := eth_state.NewTransformer().Transform(&v2Models, [2]uint64{10, 12})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(BeEmpty())
+		Expect(v3Models).To(HaveLen(6))
+		Expect(v3Models.([]eth_state.StateModelV3)[3].HeaderID).To(Equal("mockHash11"))
+	})
+
+	It("reports the heights without state nodes as gaps", func() {
+		v2Models := []eth_state.StateModelV2WithMeta{
+			stateNode("12", 0), stateNode("12", 1),
+			stateNode("15", 0),
+		}
+		_, gaps, err := eth_state.NewTransformer().Transform(&v2Models, [2]uint64{10, 17})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(Equal([][2]uint64{{10, 11}, {13, 14}, {16, 17}}))
+	})
+})
+
+var _ = Describe("eth_headers.Transformer", func() {
+	header := func(blockNumber int64, blockHash string) eth_headers.HeaderModelV2WithMeta {
+		headerRLP, err := rlp.EncodeToBytes(&types.Header{Number: big.NewInt(blockNumber), Difficulty: big.NewInt(1)})
+		Expect(err).ToNot(HaveOccurred())
+		return eth_headers.HeaderModelV2WithMeta{
+			IPLD: headerRLP,
+			HeaderModelV2: eth_headers.HeaderModelV2{
+				BlockNumber: big.NewInt(blockNumber).String(),
+				BlockHash:   blockHash,
+			},
+		}
+	}
+
+	It("transforms every header at a reorged height without erroring out the range", func() {
+		v2Models := []eth_headers.HeaderModelV2WithMeta{
+			header(10, "mockHash10"),
+			header(11, "mockHash11a"), header(11, "mockHash11b"),
+			header(12, "mockHash12"),
+		}
+		v3Models, gaps, err := eth_headers.NewTransformer().Transform(&v2Models, [2]uint64{10, 14})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(Equal([][2]uint64{{13, 14}}))
+		Expect(v3Models).To(HaveLen(4))
+	})
+
+	It("returns the reorged heights as duplicates", func() {
+		v2Models := []eth_headers.HeaderModelV2WithMeta{
+			header(10, "mockHash10"),
+			header(11, "mockHash11a"), header(11, "mockHash11b"),
+			header(12, "mockHash12a"), header(12, "mockHash12b"), header(12, "mockHash12c"),
+		}
+		transformer := eth_headers.NewTransformer().(interfaces.DuplicateFindingTransformer)
+		v3Models, gaps, duplicates, err := transformer.TransformWithDuplicates(&v2Models, [2]uint64{10, 12})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(BeEmpty())
+		Expect(duplicates).To(Equal([]uint64{11, 12}))
+		Expect(v3Models).To(HaveLen(6))
+	})
+})
+
+var _ = Describe("eth_accounts.Transformer", func() {
+	account := func(blockNumber string, path byte) eth_accounts.AccountModelV2WithMeta {
+		return eth_accounts.AccountModelV2WithMeta{
+			BlockHash:   "mockHash" + blockNumber,
+			BlockNumber: blockNumber,
+			StatePath:   []byte{path},
+		}
+	}
+
+	It("reports gaps by block height when blocks have more than one account", func() {
+		v2Models := []eth_accounts.AccountModelV2WithMeta{
+			account("10", 0), account("10", 1),
+			account("12", 0), account("12", 1), account("12", 2),
+		}
+		v3Models, gaps, err := eth_accounts.NewTransformer().Transform(&v2Models, [2]uint64{10, 12})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(Equal([][2]uint64{{11, 11}}))
+		Expect(v3Models).To(HaveLen(5))
+	})
+})
+
+var _ = Describe("typed.Transformer", func() {
+	v2Models := []eth_state.StateModelV2WithMeta{{BlockHash: "mockHash10", BlockNumber: "10"}}
+
+	It("transforms typed models without type assertions", func() {
+		v3Models, gaps, err := eth_state.NewTypedTransformer().Transform(v2Models, [2]uint64{10, 10})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(BeEmpty())
+		Expect(v3Models).To(Equal([]eth_state.StateModelV3{{HeaderID: "mockHash10"}}))
+	})
+
+	It("errors out the range when adapted to models of another type", func() {
+		headers := []eth_headers.HeaderModelV2WithMeta{{}}
+		_, gaps, err := typed.Adapt(eth_state.NewTypedTransformer()).Transform(&headers, [2]uint64{10, 10})
+		Expect(err).To(MatchError(ContainSubstring("expected models of type *[]eth_state.StateModelV2WithMeta")))
+		Expect(gaps).To(Equal([][2]uint64{{10, 10}}))
+	})
+
+	It("finds no duplicates when adapting a Transformer that does not look for them", func() {
+		uncles := []eth_uncles.UncleModelV2WithMeta{{HeaderHash: "mockHash10"}}
+		transformer := eth_uncles.NewTransformer().(interfaces.DuplicateFindingTransformer)
+		v3Models, _, duplicates, err := transformer.TransformWithDuplicates(&uncles, [2]uint64{10, 10})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(duplicates).To(BeNil())
+		Expect(v3Models).To(HaveLen(1))
+	})
+
+	It("only satisfies interfaces.FetchingTransformer when adapting a Transformer that uses a fetcher", func() {
+		_, ok := eth_state.NewTransformer().(interfaces.FetchingTransformer)
+		Expect(ok).To(BeFalse())
+		_, ok = transactions_repair.NewTransformer().(interfaces.FetchingTransformer)
+		Expect(ok).To(BeTrue())
+	})
+})
+
+// mapFetcher is an in-memory interfaces.IPLDFetcher
+type mapFetcher map[string][]byte
+
+func (f mapFetcher) FetchIPLDs(keys []string) (map[string][]byte, error) {
+	iplds := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		if data, ok := f[key]; ok {
+			iplds[key] = data
+		}
+	}
+	return iplds, nil
+}
+
+var _ = Describe("eth_transactions repair.Transformer", func() {
+	var (
+		txRoot  common.Hash
+		fetcher mapFetcher
+	)
+
+	BeforeEach(func() {
+		// the stack trie only takes keys in order, and rlp(0) sorts after rlp(1) through rlp(127)
+		nodes := memorydb.New()
+		txTrie := trie.NewStackTrie(nodes)
+		for _, index := range []int{1, 2, 3, 4, 0} {
+			trieKey, err := rlp.EncodeToBytes(uint64(index))
+			Expect(err).ToNot(HaveOccurred())
+			txRLP, err := migration_tools.MockTransactions[index].MarshalBinary()
+			Expect(err).ToNot(HaveOccurred())
+			txTrie.Update(trieKey, txRLP)
+		}
+		txRoot, err = txTrie.Commit()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(txRoot).To(Equal(migration_tools.MockBlock.TxHash()))
+
+		fetcher = make(mapFetcher)
+		it := nodes.NewIterator(nil, nil)
+		defer it.Release()
+		for it.Next() {
+			key, err := public_blocks.Keccak256Key(common.BytesToHash(it.Key()))
+			Expect(err).ToNot(HaveOccurred())
+			fetcher[key] = common.CopyBytes(it.Value())
+		}
+	})
+
+	brokenTx := func(index int) eth_transactions.TransactionModelV3WithRoot {
+		tx := migration_tools.MockTransactions[index]
+		return eth_transactions.TransactionModelV3WithRoot{
+			TxRoot: txRoot.Hex(),
+			TransactionModelV3: eth_transactions.TransactionModelV3{
+				TxHash: tx.Hash().Hex(),
+				Index:  int64(index),
+				MhKey:  keccak256ToMhKey(tx.Hash().Bytes()),
+			},
+		}
+	}
+
+	It("resolves the missing transaction IPLDs out of the transaction trie", func() {
+		transformer := transactions_repair.NewTransformer()
+		transformer.(interfaces.FetchingTransformer).UseFetcher(fetcher)
+		v3Models := []eth_transactions.TransactionModelV3WithRoot{brokenTx(0), brokenTx(3)}
+		iplds, gaps, err := transformer.Transform(&v3Models, [2]uint64{1, 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gaps).To(BeEmpty())
+		Expect(iplds).To(HaveLen(2))
+		for i, index := range []int{0, 3} {
+			txRLP, err := migration_tools.MockTransactions[index].MarshalBinary()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(iplds.([]public_blocks.IPLDModel)[i]).To(Equal(public_blocks.IPLDModel{
+				Key:  v3Models[i].MhKey,
+				Data: txRLP,
+			}))
+		}
+	})
+
+	It("errors out the range when the transaction does not match its record", func() {
+		transformer := transactions_repair.NewTransformer()
+		transformer.(interfaces.FetchingTransformer).UseFetcher(fetcher)
+		mismatched := brokenTx(1)
+		mismatched.Index = 2
+		v3Models := []eth_transactions.TransactionModelV3WithRoot{mismatched}
+		_, gaps, err := transformer.Transform(&v3Models, [2]uint64{1, 1})
+		Expect(err).To(HaveOccurred())
+		Expect(gaps).To(Equal([][2]uint64{{1, 1}}))
+	})
+
+	It("errors out the range when the transaction trie is missing from public.blocks", func() {
+		transformer := transactions_repair.NewTransformer()
+		transformer.(interfaces.FetchingTransformer).UseFetcher(make(mapFetcher))
+		v3Models := []eth_transactions.TransactionModelV3WithRoot{brokenTx(0)}
+		_, gaps, err := transformer.Transform(&v3Models, [2]uint64{1, 1})
+		Expect(err).To(HaveOccurred())
+		Expect(gaps).To(Equal([][2]uint64{{1, 1}}))
+	})
+})
@@ -0,0 +1,234 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/migration-tools/pkg/eth_logs"
+	"github.com/vulcanize/migration-tools/pkg/public_blocks"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+const (
+	pgReadTrieCheckHeadersStr sql.ReadPgStr = `SELECT block_number, block_hash, receipt_root
+									FROM eth.header_cids
+									WHERE block_number BETWEEN $1 AND $2`
+
+	pgReadTrieCheckReceiptsStr sql.ReadPgStr = `SELECT header_cids.block_hash, transaction_cids.index, receipt_cids.tx_id,
+									receipt_cids.leaf_mh_key, receipt_cids.log_root, blocks.data
+									FROM eth.receipt_cids
+									INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.tx_hash)
+									INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+									LEFT JOIN public.blocks ON (receipt_cids.leaf_mh_key = blocks.key)
+									WHERE block_number BETWEEN $1 AND $2
+									ORDER BY header_cids.block_hash ASC, transaction_cids.index ASC`
+
+	pgReadTrieCheckLogsStr sql.ReadPgStr = `SELECT eth.log_cids.*
+									FROM eth.log_cids
+									INNER JOIN eth.transaction_cids ON (log_cids.rct_id = transaction_cids.tx_hash)
+									INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+									WHERE block_number BETWEEN $1 AND $2
+									ORDER BY log_cids.rct_id ASC, log_cids.index ASC`
+)
+
+// TrieCheckHeaderModel is the db model for the receipt root of v3 eth.header_cids
+type TrieCheckHeaderModel struct {
+	BlockNumber uint64 `db:"block_number"`
+	BlockHash   string `db:"block_hash"`
+	RctRoot     string `db:"receipt_root"`
+}
+
+// TrieCheckReceiptModel is the db model for the v3 eth.receipt_cids, with its position in the block and
+// its receipt trie leaf node, if it is in public.blocks
+type TrieCheckReceiptModel struct {
+	BlockHash string `db:"block_hash"`
+	Index     int64  `db:"index"`
+	TxID      string `db:"tx_id"`
+	LeafMhKey string `db:"leaf_mh_key"`
+	LogRoot   string `db:"log_root"`
+	LeafNode  []byte `db:"data"`
+}
+
+// TrieMismatch is a block whose v3 rows do not hash to a trie root that the block commits to
+// TableName is eth.log_cids for the log trie of a receipt, and eth.receipt_cids for the receipt trie of a header
+type TrieMismatch struct {
+	TableName   TableName
+	BlockNumber uint64
+	BlockHash   string
+	TxHash      string
+	Root        string
+	DerivedRoot string
+	Reason      string
+}
+
+// String returns a description of the mismatch
+func (m TrieMismatch) String() string {
+	desc := fmt.Sprintf("%s mismatch at block %d (%s)", m.TableName, m.BlockNumber, m.BlockHash)
+	if m.TxHash != "" {
+		desc += fmt.Sprintf(" tx %s", m.TxHash)
+	}
+	if m.Reason != "" {
+		return desc + ": " + m.Reason
+	}
+	return desc + fmt.Sprintf(": root %s, derived root %s", m.Root, m.DerivedRoot)
+}
+
+// TrieChecker checks the log tries and receipt tries of the v3 DB block range by block range
+type TrieChecker struct {
+	db *sqlx.DB
+}
+
+// NewTrieChecker returns a new TrieChecker for the new DB of the given Config
+func NewTrieChecker(ctx context.Context, conf *Config) (*TrieChecker, error) {
+	db, err := NewDB(ctx, conf.WriteDB)
+	if err != nil {
+		return nil, err
+	}
+	return &TrieChecker{db: db}, nil
+}
+
+// DetectRanges returns the block ranges within the bounds that the provided table has rows at in the new DB
+func (c *TrieChecker) DetectRanges(tableName TableName, bounds [2]uint64) ([][2]uint64, error) {
+	return DetectMigratedRanges(c.db, tableName, bounds)
+}
+
+// Check returns the blocks of the provided range whose log or receipt tries do not match their roots
+// the log trie of every receipt is rebuilt from its eth.log_cids rows and compared to receipt_cids.log_root,
+// and the receipt trie of every header is rebuilt from the receipt trie leaf nodes of its eth.receipt_cids rows
+// and compared to header_cids.receipt_root
+// Check is safe for concurrent use, as the only shared state is the concurrent safe *sqlx.DB
+func (c *TrieChecker) Check(rng [2]uint64) ([]TrieMismatch, error) {
+	var headers []TrieCheckHeaderModel
+	if err := c.db.Select(&headers, string(pgReadTrieCheckHeadersStr), rng[0], rng[1]); err != nil {
+		return nil, fmt.Errorf("header read error: %v", err)
+	}
+	var receipts []TrieCheckReceiptModel
+	if err := c.db.Select(&receipts, string(pgReadTrieCheckReceiptsStr), rng[0], rng[1]); err != nil {
+		return nil, fmt.Errorf("receipt read error: %v", err)
+	}
+	var logs []eth_logs.LogModelV3
+	if err := c.db.Select(&logs, string(pgReadTrieCheckLogsStr), rng[0], rng[1]); err != nil {
+		return nil, fmt.Errorf("log read error: %v", err)
+	}
+	return CheckTries(headers, receipts, logs)
+}
+
+// CheckTries returns the mismatching log and receipt tries of the provided headers
+// receipts have to be ordered by their index within their block, and logs by their index within their receipt's block
+func CheckTries(headers []TrieCheckHeaderModel, receipts []TrieCheckReceiptModel, logs []eth_logs.LogModelV3) ([]TrieMismatch, error) {
+	logsByReceipt := make(map[string][]eth_logs.LogModelV3)
+	for _, log := range logs {
+		logsByReceipt[log.ReceiptID] = append(logsByReceipt[log.ReceiptID], log)
+	}
+	receiptsByBlock := make(map[string][]TrieCheckReceiptModel)
+	for _, receipt := range receipts {
+		receiptsByBlock[receipt.BlockHash] = append(receiptsByBlock[receipt.BlockHash], receipt)
+	}
+
+	var mismatches []TrieMismatch
+	for _, header := range headers {
+		blockReceipts := receiptsByBlock[header.BlockHash]
+		for _, receipt := range blockReceipts {
+			logRoot, err := deriveLogRoot(logsByReceipt[receipt.TxID])
+			if err != nil {
+				return nil, fmt.Errorf("unable to derive the log root of tx %s: %v", receipt.TxID, err)
+			}
+			if logRoot != common.HexToHash(receipt.LogRoot) {
+				mismatches = append(mismatches, TrieMismatch{
+					TableName:   EthLogs,
+					BlockNumber: header.BlockNumber,
+					BlockHash:   header.BlockHash,
+					TxHash:      receipt.TxID,
+					Root:        receipt.LogRoot,
+					DerivedRoot: logRoot.Hex(),
+				})
+			}
+		}
+
+		mismatch := TrieMismatch{
+			TableName:   EthReceipts,
+			BlockNumber: header.BlockNumber,
+			BlockHash:   header.BlockHash,
+			Root:        header.RctRoot,
+		}
+		rctRoot, err := deriveReceiptRoot(blockReceipts)
+		if err != nil {
+			mismatch.Reason = err.Error()
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		if rctRoot != common.HexToHash(header.RctRoot) {
+			mismatch.DerivedRoot = rctRoot.Hex()
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches, nil
+}
+
+// deriveLogRoot returns the root of the log trie of a receipt with the provided logs
+func deriveLogRoot(logs []eth_logs.LogModelV3) (common.Hash, error) {
+	values := make(rawDerivableList, len(logs))
+	for i, log := range logs {
+		logRLP, err := rlp.EncodeToBytes(eth_logs.NewLog(log))
+		if err != nil {
+			return common.Hash{}, err
+		}
+		values[i] = logRLP
+	}
+	return types.DeriveSha(values, trie.NewStackTrie(nil)), nil
+}
+
+// deriveReceiptRoot returns the root of the receipt trie of a block with the provided receipts
+// the receipts are taken out of their receipt trie leaf nodes, as eth.receipt_cids does not index all of their fields
+func deriveReceiptRoot(receipts []TrieCheckReceiptModel) (common.Hash, error) {
+	values := make(rawDerivableList, len(receipts))
+	for i, receipt := range receipts {
+		if receipt.Index != int64(i) {
+			return common.Hash{}, fmt.Errorf("receipt_cids has no receipt at index %d", i)
+		}
+		if receipt.LeafNode == nil {
+			return common.Hash{}, fmt.Errorf("receipt trie leaf node %s of tx %s is missing from public.blocks", receipt.LeafMhKey, receipt.TxID)
+		}
+		value, err := public_blocks.LeafValue(receipt.LeafNode)
+		if err != nil {
+			return common.Hash{}, fmt.Errorf("receipt trie leaf node %s of tx %s: %v", receipt.LeafMhKey, receipt.TxID, err)
+		}
+		values[i] = value
+	}
+	return types.DeriveSha(values, trie.NewStackTrie(nil)), nil
+}
+
+// rawDerivableList is a types.DerivableList of values that are already encoded
+type rawDerivableList [][]byte
+
+func (l rawDerivableList) Len() int { return len(l) }
+
+func (l rawDerivableList) EncodeIndex(i int, w *bytes.Buffer) { w.Write(l[i]) }
+
+// Close satisfies io.Closer
+func (c *TrieChecker) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,112 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"github.com/ethereum/go-ethereum/statediff/indexer/ipld"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/eth_logs"
+)
+
+var _ = Describe("Trie checks", func() {
+	var (
+		headers  []migration_tools.TrieCheckHeaderModel
+		receipts []migration_tools.TrieCheckReceiptModel
+		logs     []eth_logs.LogModelV3
+	)
+
+	BeforeEach(func() {
+		_, _, _, _, rctNodes, rctTrieNodes, _, _, rctLeafNodeCIDs, err := ipld.FromBlockAndReceipts(migration_tools.MockBlock, migration_tools.MockReceipts)
+		Expect(err).ToNot(HaveOccurred())
+		trieNodes := make(map[string][]byte, len(rctTrieNodes))
+		for _, node := range rctTrieNodes {
+			trieNodes[node.Cid().String()] = node.RawData()
+		}
+		blockHash := migration_tools.MockBlock.Hash().Hex()
+		headers = []migration_tools.TrieCheckHeaderModel{{
+			BlockNumber: migration_tools.MockBlock.NumberU64(),
+			BlockHash:   blockHash,
+			RctRoot:     migration_tools.MockBlock.ReceiptHash().Hex(),
+		}}
+		receipts, logs = nil, nil
+		for i, tx := range migration_tools.MockTransactions {
+			receipts = append(receipts, migration_tools.TrieCheckReceiptModel{
+				BlockHash: blockHash,
+				Index:     int64(i),
+				TxID:      tx.Hash().Hex(),
+				LeafMhKey: rctLeafNodeCIDs[i].String(),
+				LogRoot:   rctNodes[i].LogRoot.Hex(),
+				LeafNode:  trieNodes[rctLeafNodeCIDs[i].String()],
+			})
+			for _, log := range migration_tools.MockReceipts[i].Logs {
+				model := eth_logs.LogModelV3{
+					ReceiptID: tx.Hash().Hex(),
+					Address:   log.Address.Hex(),
+					Index:     int64(log.Index),
+					Data:      log.Data,
+				}
+				topics := []*string{&model.Topic0, &model.Topic1, &model.Topic2, &model.Topic3}
+				for j, topic := range log.Topics {
+					*topics[j] = topic.Hex()
+				}
+				logs = append(logs, model)
+			}
+		}
+		Expect(logs).ToNot(BeEmpty())
+	})
+
+	It("finds no mismatches when the rows match the tries", func() {
+		mismatches, err := migration_tools.CheckTries(headers, receipts, logs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mismatches).To(BeEmpty())
+	})
+
+	It("reports the receipt whose logs do not hash to its log root", func() {
+		logs[0].Data = []byte{1, 2, 3}
+		mismatches, err := migration_tools.CheckTries(headers, receipts, logs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mismatches).To(HaveLen(1))
+		Expect(mismatches[0].TableName).To(Equal(migration_tools.EthLogs))
+		Expect(mismatches[0].TxHash).To(Equal(logs[0].ReceiptID))
+		Expect(mismatches[0].DerivedRoot).ToNot(Equal(mismatches[0].Root))
+	})
+
+	It("reports the block whose receipts do not hash to its receipt root", func() {
+		receipts[1].LeafNode = receipts[2].LeafNode
+		mismatches, err := migration_tools.CheckTries(headers, receipts, logs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mismatches).To(HaveLen(1))
+		Expect(mismatches[0].TableName).To(Equal(migration_tools.EthReceipts))
+		Expect(mismatches[0].BlockNumber).To(Equal(migration_tools.MockBlock.NumberU64()))
+	})
+
+	It("reports the block whose receipt trie leaf nodes are missing or whose receipts are incomplete", func() {
+		receipts[3].LeafNode = nil
+		mismatches, err := migration_tools.CheckTries(headers, receipts, logs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mismatches).To(HaveLen(1))
+		Expect(mismatches[0].Reason).To(ContainSubstring("missing from public.blocks"))
+
+		mismatches, err = migration_tools.CheckTries(headers, receipts[1:], logs)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mismatches).To(HaveLen(1))
+		Expect(mismatches[0].Reason).To(ContainSubstring("no receipt at index 0"))
+	})
+})
@@ -0,0 +1,104 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package typed
+
+import (
+	"fmt"
+
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+)
+
+// Transformer interface for transforming v2 DB models of type In into v3 DB models of type Out for a specific table
+type Transformer[In, Out any] interface {
+	Transform(models []In, expectedRange [2]uint64) ([]Out, [][2]uint64, error)
+}
+
+// TransformerConstructor func sig for constructing a Transformer for a specific table
+type TransformerConstructor[In, Out any] func() Transformer[In, Out]
+
+// DuplicateFinder is satisfied by Transformers that also return the heights at which their models belong to more
+// than one block hash (i.e. reorgs)
+type DuplicateFinder[In, Out any] interface {
+	Transformer[In, Out]
+	TransformWithDuplicates(models []In, expectedRange [2]uint64) ([]Out, [][2]uint64, []uint64, error)
+}
+
+// fetcherUser is satisfied by Transformers that need IPLDs beyond the models they are provided with
+type fetcherUser interface {
+	UseFetcher(fetcher interfaces.IPLDFetcher)
+}
+
+// Adapt returns an interfaces.Transformer that takes a *[]In, or an []In, and returns an []Out
+// the returned Transformer satisfies interfaces.FetchingTransformer if the provided one has a UseFetcher method
+// the returned Transformer satisfies interfaces.DuplicateFindingTransformer, finding no duplicates if the provided one
+// is not a DuplicateFinder
+func Adapt[In, Out any](transformer Transformer[In, Out]) interfaces.Transformer {
+	a := adapter[In, Out]{transformer: transformer}
+	if _, ok := transformer.(fetcherUser); ok {
+		return fetchingAdapter[In, Out]{a}
+	}
+	return a
+}
+
+// adapter satisfies interfaces.Transformer for a Transformer
+type adapter[In, Out any] struct {
+	transformer Transformer[In, Out]
+}
+
+// Transform satisfies interfaces.Transformer
+func (a adapter[In, Out]) Transform(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, error) {
+	out, gaps, _, err := a.TransformWithDuplicates(models, expectedRange)
+	return out, gaps, err
+}
+
+// TransformWithDuplicates satisfies interfaces.DuplicateFindingTransformer
+func (a adapter[In, Out]) TransformWithDuplicates(models interface{}, expectedRange [2]uint64) (interface{}, [][2]uint64, []uint64, error) {
+	var in []In
+	switch m := models.(type) {
+	case *[]In:
+		in = *m
+	case []In:
+		in = m
+	default:
+		return nil, [][2]uint64{expectedRange}, nil, fmt.Errorf("expected models of type %T, got %T", new([]In), models)
+	}
+	out, gaps, duplicates, err := TransformWithDuplicates(a.transformer, in, expectedRange)
+	if err != nil {
+		return nil, gaps, nil, err
+	}
+	return out, gaps, duplicates, nil
+}
+
+// TransformWithDuplicates transforms the models with the Transformer, returning the heights at which they belong to
+// more than one block hash if the Transformer is a DuplicateFinder, or none otherwise
+func TransformWithDuplicates[In, Out any](transformer Transformer[In, Out], models []In, expectedRange [2]uint64) ([]Out, [][2]uint64, []uint64, error) {
+	if finder, ok := transformer.(DuplicateFinder[In, Out]); ok {
+		return finder.TransformWithDuplicates(models, expectedRange)
+	}
+	out, gaps, err := transformer.Transform(models, expectedRange)
+	return out, gaps, nil, err
+}
+
+// fetchingAdapter satisfies interfaces.FetchingTransformer for a Transformer with a UseFetcher method
+type fetchingAdapter[In, Out any] struct {
+	adapter[In, Out]
+}
+
+// UseFetcher satisfies interfaces.FetchingTransformer
+func (a fetchingAdapter[In, Out]) UseFetcher(fetcher interfaces.IPLDFetcher) {
+	a.transformer.(fetcherUser).UseFetcher(fetcher)
+}
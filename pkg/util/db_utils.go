@@ -27,3 +27,7 @@ func Rollback(tx *sqlx.Tx) {
 		logrus.Error(err.Error())
 	}
 }
+
+// TxHook is run within a write transaction after the rows are written, and before the transaction is committed
+// an error returned by the hook rolls the whole transaction back
+type TxHook func(tx *sqlx.Tx) error
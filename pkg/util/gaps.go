@@ -0,0 +1,86 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package util
+
+import "fmt"
+
+// GapDetector finds the block heights within an expected range that have no rows
+// it tracks the distinct heights of the rows it is fed, so any number of rows can share a height,
+// and it records the heights at which rows belong to more than one block hash (i.e. reorgs)
+type GapDetector struct {
+	expectedRange [2]uint64
+	started       bool
+	current       uint64
+	next          uint64
+	hashes        map[string]struct{}
+	gaps          [][2]uint64
+	duplicates    []uint64
+}
+
+// NewGapDetector returns a new GapDetector for the provided range
+func NewGapDetector(expectedRange [2]uint64) *GapDetector {
+	return &GapDetector{
+		expectedRange: expectedRange,
+		next:          expectedRange[0],
+		gaps:          make([][2]uint64, 0),
+	}
+}
+
+// Add records a row at the provided height, belonging to the block with the provided hash
+// rows have to be added in ascending order of height
+func (d *GapDetector) Add(height uint64, blockHash string) error {
+	if height < d.expectedRange[0] || height > d.expectedRange[1] {
+		return fmt.Errorf("height %d is outside of the expected range (%d, %d)", height, d.expectedRange[0], d.expectedRange[1])
+	}
+	if d.started && height < d.current {
+		return fmt.Errorf("rows have to be ordered by height, got height %d after height %d", height, d.current)
+	}
+	if !d.started || d.current < height {
+		// if the expected height doesn't match the actual current block height, we have a gap between the two
+		if d.next < height {
+			d.gaps = append(d.gaps, [2]uint64{d.next, height - 1})
+		}
+		d.started = true
+		d.current = height
+		d.next = height + 1
+		d.hashes = map[string]struct{}{blockHash: {}}
+		return nil
+	}
+	if _, ok := d.hashes[blockHash]; !ok {
+		d.hashes[blockHash] = struct{}{}
+		if len(d.hashes) == 2 {
+			d.duplicates = append(d.duplicates, height)
+		}
+	}
+	return nil
+}
+
+// Gaps returns the ranges of heights within the expected range that no row has been added for
+func (d *GapDetector) Gaps() [][2]uint64 {
+	gaps := make([][2]uint64, len(d.gaps), len(d.gaps)+1)
+	copy(gaps, d.gaps)
+	// if the last added height isn't the last block in the range, we have a gap at the end of the range
+	if d.next <= d.expectedRange[1] {
+		gaps = append(gaps, [2]uint64{d.next, d.expectedRange[1]})
+	}
+	return gaps
+}
+
+// Duplicates returns the heights at which rows have been added for more than one block hash
+func (d *GapDetector) Duplicates() []uint64 {
+	return d.duplicates
+}
@@ -0,0 +1,203 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/multiformats/go-multihash"
+
+	"github.com/vulcanize/migration-tools/pkg/interfaces"
+)
+
+// IPLDMismatch is a cid and mh_key pair of a v3 model that does not reference a valid IPLD
+type IPLDMismatch struct {
+	Column string `json:"column"`
+	CID    string `json:"cid"`
+	MhKey  string `json:"mh_key"`
+	Reason string `json:"reason"`
+}
+
+// IPLDValidationError is the error returned for a range whose models reference invalid IPLDs
+type IPLDValidationError struct {
+	Mismatches []IPLDMismatch
+}
+
+// Error satisfies error
+func (e *IPLDValidationError) Error() string {
+	first := e.Mismatches[0]
+	return fmt.Sprintf("%d IPLD references failed validation, first %s %s (%s): %s",
+		len(e.Mismatches), first.Column, first.CID, first.MhKey, first.Reason)
+}
+
+// IPLDSource is the DB whose public.blocks IPLDs are validated against
+type IPLDSource string
+
+const (
+	// IPLDSourceOld validates against the v2 IPLDs the rows were migrated from
+	IPLDSourceOld IPLDSource = "old"
+	// IPLDSourceNew validates against the IPLDs already in the new DB, which the v3 rows reference through foreign keys
+	IPLDSourceNew IPLDSource = "new"
+)
+
+// NewIPLDSourceFromString returns the IPLDSource from the provided string
+func NewIPLDSourceFromString(sourceStr string) (IPLDSource, error) {
+	switch strings.ToLower(sourceStr) {
+	case "", "old":
+		return IPLDSourceOld, nil
+	case "new":
+		return IPLDSourceNew, nil
+	default:
+		return "", fmt.Errorf("unrecognized IPLD validation source: %s", sourceStr)
+	}
+}
+
+// IPLDValidator checks that the cid and mh_key pairs of v3 models reference IPLDs which exist and hash to their cid
+// Validate is a no-op on a nil IPLDValidator
+type IPLDValidator struct {
+	fetcher interfaces.IPLDFetcher
+	mapper  *reflectx.Mapper
+}
+
+// NewIPLDValidator returns a new IPLDValidator that fetches IPLDs from the provided fetcher
+// the mapper has to be the one the models are read and written with
+func NewIPLDValidator(fetcher interfaces.IPLDFetcher, mapper *reflectx.Mapper) *IPLDValidator {
+	return &IPLDValidator{fetcher: fetcher, mapper: mapper}
+}
+
+// Validate checks every cid and mh_key pair of the provided slice of v3 models
+// it returns an *IPLDValidationError if the mh_key is not derived from the cid, if the IPLD is missing,
+// if the cid is not a keccak-256 multihash, or if the IPLD does not hash to the cid
+func (v *IPLDValidator) Validate(models interface{}) error {
+	if v == nil {
+		return nil
+	}
+	refs, err := ipldReferences(v.mapper, models)
+	if err != nil {
+		return err
+	}
+	var mismatches []IPLDMismatch
+	digests := make(map[string][]byte, len(refs))
+	fetchKeys := make([]string, 0, len(refs))
+	checked := make([]ipldReference, 0, len(refs))
+	for _, ref := range refs {
+		c, err := cid.Decode(ref.CID)
+		if err != nil {
+			mismatches = append(mismatches, ref.mismatch(fmt.Sprintf("cid does not decode: %v", err)))
+			continue
+		}
+		if mhKeyFromCID(c) != ref.MhKey {
+			mismatches = append(mismatches, ref.mismatch("mh_key is not derived from the cid"))
+			continue
+		}
+		decoded, err := multihash.Decode(c.Hash())
+		if err != nil {
+			mismatches = append(mismatches, ref.mismatch(fmt.Sprintf("cid multihash does not decode: %v", err)))
+			continue
+		}
+		if decoded.Code != multihash.KECCAK_256 {
+			mismatches = append(mismatches, ref.mismatch(fmt.Sprintf("cid multihash is %s, not keccak-256", decoded.Name)))
+			continue
+		}
+		if _, ok := digests[ref.MhKey]; !ok {
+			digests[ref.MhKey] = decoded.Digest
+			fetchKeys = append(fetchKeys, ref.MhKey)
+		}
+		checked = append(checked, ref)
+	}
+	if len(fetchKeys) > 0 {
+		iplds, err := v.fetcher.FetchIPLDs(fetchKeys)
+		if err != nil {
+			return fmt.Errorf("unable to fetch %d IPLDs: %v", len(fetchKeys), err)
+		}
+		for _, ref := range checked {
+			data, ok := iplds[ref.MhKey]
+			if !ok {
+				mismatches = append(mismatches, ref.mismatch("IPLD is missing from public.blocks"))
+				continue
+			}
+			if hash := crypto.Keccak256(data); !bytes.Equal(hash, digests[ref.MhKey]) {
+				mismatches = append(mismatches, ref.mismatch(fmt.Sprintf("IPLD hashes to %x", hash)))
+			}
+		}
+	}
+	if len(mismatches) > 0 {
+		return &IPLDValidationError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// ipldReference is a cid and mh_key pair of a v3 model, along with the name of its cid column
+type ipldReference struct {
+	Column string
+	CID    string
+	MhKey  string
+}
+
+func (r ipldReference) mismatch(reason string) IPLDMismatch {
+	return IPLDMismatch{Column: r.Column, CID: r.CID, MhKey: r.MhKey, Reason: reason}
+}
+
+// ipldReferences returns the non-empty cid and mh_key pairs of the v3 models in the provided slice, in model order
+func ipldReferences(mapper *reflectx.Mapper, models interface{}) ([]ipldReference, error) {
+	modelsVal := reflect.Indirect(reflect.ValueOf(models))
+	if modelsVal.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("expected a slice of models, got %T", models)
+	}
+	elemType := reflectx.Deref(modelsVal.Type().Elem())
+	if elemType.Kind() != reflect.Struct {
+		return nil, nil
+	}
+	var columns []string
+	var pairs [][2][]int
+	for _, pairColumns := range mhKeyColumns {
+		traversals := mapper.TraversalsByName(elemType, pairColumns[:])
+		if len(traversals[0]) != 0 && len(traversals[1]) != 0 {
+			columns = append(columns, pairColumns[0])
+			pairs = append(pairs, [2][]int{traversals[0], traversals[1]})
+		}
+	}
+	var refs []ipldReference
+	for i := 0; i < modelsVal.Len(); i++ {
+		modelVal := reflect.Indirect(modelsVal.Index(i))
+		for j, pair := range pairs {
+			cidVal := reflectx.FieldByIndexesReadOnly(modelVal, pair[0])
+			mhKeyVal := reflectx.FieldByIndexesReadOnly(modelVal, pair[1])
+			if cidVal.Kind() != reflect.String || mhKeyVal.Kind() != reflect.String {
+				continue
+			}
+			if cidVal.String() == "" && mhKeyVal.String() == "" {
+				continue
+			}
+			refs = append(refs, ipldReference{Column: columns[j], CID: cidVal.String(), MhKey: mhKeyVal.String()})
+		}
+	}
+	return refs, nil
+}
+
+// mhKeyFromCID returns the public.blocks key of the IPLD with the provided cid
+func mhKeyFromCID(c cid.Cid) string {
+	return blockstore.BlockPrefix.String() + dshelp.MultihashToDsKey(c.Hash()).String()
+}
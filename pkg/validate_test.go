@@ -0,0 +1,115 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff/indexer/ipld"
+	"github.com/jmoiron/sqlx/reflectx"
+	"github.com/multiformats/go-multihash"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/eth_headers"
+)
+
+var _ = Describe("IPLDValidator", func() {
+	var (
+		headerRLP []byte
+		models    []eth_headers.HeaderModelV3
+		fetcher   mapFetcher
+		validator *migration_tools.IPLDValidator
+	)
+
+	BeforeEach(func() {
+		var err error
+		headerRLP, err = rlp.EncodeToBytes(&migration_tools.MockHeader)
+		Expect(err).ToNot(HaveOccurred())
+		c, err := ipld.RawdataToCid(ipld.MEthHeader, headerRLP, multihash.KECCAK_256)
+		Expect(err).ToNot(HaveOccurred())
+		mhKey := keccak256ToMhKey(migration_tools.MockHeader.Hash().Bytes())
+		models = []eth_headers.HeaderModelV3{{CID: c.String(), MhKey: mhKey}}
+		fetcher = mapFetcher{mhKey: headerRLP}
+		validator = migration_tools.NewIPLDValidator(fetcher, reflectx.NewMapperFunc("db", strings.ToLower))
+	})
+
+	It("accepts models whose IPLDs exist and hash to their cid", func() {
+		Expect(validator.Validate(&models)).To(Succeed())
+	})
+
+	It("is a no-op when validation is turned off", func() {
+		var off *migration_tools.IPLDValidator
+		Expect(off.Validate(&models)).To(Succeed())
+	})
+
+	It("reports IPLDs that are missing from public.blocks", func() {
+		delete(fetcher, models[0].MhKey)
+		err := validator.Validate(&models)
+		var validationErr *migration_tools.IPLDValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue())
+		Expect(validationErr.Mismatches).To(HaveLen(1))
+		Expect(validationErr.Mismatches[0].Column).To(Equal("cid"))
+		Expect(validationErr.Mismatches[0].Reason).To(ContainSubstring("missing from public.blocks"))
+	})
+
+	It("reports IPLDs that do not hash to their cid", func() {
+		fetcher[models[0].MhKey] = append([]byte{0x00}, headerRLP...)
+		err := validator.Validate(&models)
+		var validationErr *migration_tools.IPLDValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue())
+		Expect(validationErr.Mismatches).To(HaveLen(1))
+		Expect(validationErr.Mismatches[0].Reason).To(ContainSubstring("hashes to"))
+	})
+
+	It("reports mh_keys that are not derived from their cid", func() {
+		models[0].MhKey = keccak256ToMhKey(migration_tools.MockHeader.ParentHash.Bytes())
+		err := validator.Validate(&models)
+		var validationErr *migration_tools.IPLDValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue())
+		Expect(validationErr.Mismatches).To(HaveLen(1))
+		Expect(validationErr.Mismatches[0].Reason).To(ContainSubstring("not derived from the cid"))
+	})
+})
+
+var _ = Describe("IPLDSource", func() {
+	It("defaults to the old DB and rejects unknown sources", func() {
+		source, err := migration_tools.NewIPLDSourceFromString("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(source).To(Equal(migration_tools.IPLDSourceOld))
+		source, err = migration_tools.NewIPLDSourceFromString("New")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(source).To(Equal(migration_tools.IPLDSourceNew))
+		_, err = migration_tools.NewIPLDSourceFromString("v2")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("is checked before the Migrator connects to anything", func() {
+		_, err := migration_tools.NewMigrator(context.Background(), &migration_tools.Config{ValidateIPLDsSource: "v2"})
+		Expect(err).To(MatchError(ContainSubstring("unrecognized IPLD validation source")))
+		_, err = migration_tools.NewMigrator(context.Background(), &migration_tools.Config{
+			DryRun:              true,
+			ValidateIPLDs:       true,
+			ValidateIPLDsSource: migration_tools.IPLDSourceNew,
+		})
+		Expect(err).To(MatchError(ContainSubstring("validated against the new DB")))
+	})
+})
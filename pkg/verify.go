@@ -0,0 +1,293 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+
+	"github.com/vulcanize/migration-tools/pkg/sql"
+)
+
+// verifySpec holds the key columns hashed when verifying a table and the v3 query that selects them for a block range
+type verifySpec struct {
+	keyColumns []string
+	v3PgStr    sql.ReadPgStr
+}
+
+var tableVerifySpecMappings = map[TableName]verifySpec{
+	EthHeaders: {
+		keyColumns: []string{"block_hash", "cid", "mh_key"},
+		v3PgStr: `SELECT block_hash, cid, mh_key FROM eth.header_cids
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthUncles: {
+		keyColumns: []string{"block_hash", "cid", "mh_key"},
+		v3PgStr: `SELECT uncle_cids.block_hash, uncle_cids.cid, uncle_cids.mh_key FROM eth.uncle_cids
+					INNER JOIN eth.header_cids ON (uncle_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthTransactions: {
+		keyColumns: []string{"tx_hash", "cid", "mh_key"},
+		v3PgStr: `SELECT transaction_cids.tx_hash, transaction_cids.cid, transaction_cids.mh_key FROM eth.transaction_cids
+					INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthAccessListElements: {
+		keyColumns: []string{"tx_id", "index"},
+		v3PgStr: `SELECT access_list_elements.tx_id, access_list_elements.index FROM eth.access_list_elements
+					INNER JOIN eth.transaction_cids ON (access_list_elements.tx_id = transaction_cids.tx_hash)
+					INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthReceipts: {
+		keyColumns: []string{"tx_id", "leaf_cid", "leaf_mh_key"},
+		v3PgStr: `SELECT receipt_cids.tx_id, receipt_cids.leaf_cid, receipt_cids.leaf_mh_key FROM eth.receipt_cids
+					INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.tx_hash)
+					INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthLogs: {
+		keyColumns: []string{"rct_id", "index", "leaf_cid", "leaf_mh_key"},
+		v3PgStr: `SELECT log_cids.rct_id, log_cids.index, log_cids.leaf_cid, log_cids.leaf_mh_key FROM eth.log_cids
+					INNER JOIN eth.transaction_cids ON (log_cids.rct_id = transaction_cids.tx_hash)
+					INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthState: {
+		keyColumns: []string{"header_id", "state_path", "cid", "mh_key"},
+		v3PgStr: `SELECT state_cids.header_id, state_cids.state_path, state_cids.cid, state_cids.mh_key FROM eth.state_cids
+					INNER JOIN eth.header_cids ON (state_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthAccounts: {
+		keyColumns: []string{"header_id", "state_path"},
+		v3PgStr: `SELECT state_accounts.header_id, state_accounts.state_path FROM eth.state_accounts
+					INNER JOIN eth.header_cids ON (state_accounts.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+	EthStorage: {
+		keyColumns: []string{"header_id", "state_path", "storage_path", "cid", "mh_key"},
+		v3PgStr: `SELECT storage_cids.header_id, storage_cids.state_path, storage_cids.storage_path, storage_cids.cid,
+					storage_cids.mh_key FROM eth.storage_cids
+					INNER JOIN eth.header_cids ON (storage_cids.header_id = header_cids.block_hash)
+					WHERE block_number BETWEEN $1 AND $2`,
+	},
+}
+
+// VerifyResult holds the row counts and key hashes found in the v2 and v3 DBs for a table and block range
+type VerifyResult struct {
+	TableName TableName
+	Range     [2]uint64
+	V2Count   int
+	V3Count   int
+	V2Hash    string
+	V3Hash    string
+}
+
+// Match returns whether the v3 DB holds the same rows as the v2 DB for the range
+func (r VerifyResult) Match() bool {
+	return r.V2Count == r.V3Count && r.V2Hash == r.V3Hash
+}
+
+// Verifier compares the contents of the v2 and v3 DBs block range by block range
+type Verifier struct {
+	reader        *Reader
+	canonicalizer *Canonicalizer
+	oldDB, newDB  *sqlx.DB
+}
+
+// NewVerifier returns a new Verifier from the given Config
+func NewVerifier(ctx context.Context, conf *Config) (*Verifier, error) {
+	readDB, err := NewDB(ctx, conf.ReadDB)
+	if err != nil {
+		return nil, err
+	}
+	newDB, err := NewDB(ctx, conf.WriteDB)
+	if err != nil {
+		readDB.Close()
+		return nil, err
+	}
+	var canonicalizer *Canonicalizer
+	if conf.CanonicalOnly {
+		// the non-canonical blocks have already been reported during migration
+		canonicalizer = NewCanonicalizer(readDB, nil)
+	}
+	return &Verifier{
+		reader:        NewReader(readDB),
+		canonicalizer: canonicalizer,
+		oldDB:         readDB,
+		newDB:         newDB,
+	}, nil
+}
+
+// Verify compares the row counts and key hashes of the provided table for the provided block range
+// the v2 rows are read with the same query and transformed with the same Transformer as during migration,
+// and the key columns of the resulting v3 models are hashed in the same way as the key columns of the v3 rows
+// the hash is the sum of the sha256 digests of each row's key columns, so it is independent of row order
+// Verify is safe for concurrent use
+func (v *Verifier) Verify(tableName TableName, rng [2]uint64) (*VerifyResult, error) {
+	spec, ok := tableVerifySpecMappings[tableName]
+	if !ok {
+		return nil, fmt.Errorf("verification is not supported for table %s", tableName)
+	}
+	pipeline, err := newTablePipeline(tableName, nil)
+	if err != nil {
+		return nil, err
+	}
+	oldModels, err := pipeline.read(v.reader, v.canonicalizer, rng)
+	if err != nil {
+		return nil, fmt.Errorf("v2 read error: %v", err)
+	}
+	result := &VerifyResult{
+		TableName: tableName,
+		Range:     rng,
+		V2Count:   oldModels.len(),
+	}
+	v2Hash := newKeyHash()
+	if result.V2Count > 0 {
+		newModels, _, _, err := oldModels.transform(rng)
+		if err != nil {
+			return nil, fmt.Errorf("transform error: %v", err)
+		}
+		if err := v2Hash.addModels(v.newDB.Mapper, newModels.models(), spec.keyColumns); err != nil {
+			return nil, err
+		}
+	}
+	result.V2Hash = v2Hash.String()
+
+	rows, err := v.newDB.Query(string(spec.v3PgStr), rng[0], rng[1])
+	if err != nil {
+		return nil, fmt.Errorf("v3 read error: %v", err)
+	}
+	defer rows.Close()
+	v3Hash := newKeyHash()
+	for rows.Next() {
+		values := make([]interface{}, len(spec.keyColumns))
+		dests := make([]interface{}, len(values))
+		for i := range values {
+			dests[i] = &values[i]
+		}
+		if err := rows.Scan(dests...); err != nil {
+			return nil, fmt.Errorf("v3 read error: %v", err)
+		}
+		if err := v3Hash.add(values); err != nil {
+			return nil, err
+		}
+		result.V3Count++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("v3 read error: %v", err)
+	}
+	result.V3Hash = v3Hash.String()
+	return result, nil
+}
+
+// DetectRanges returns every block range within the bounds that the provided table has rows at in the old DB,
+// whether or not they have been migrated, so that the ranges missing from the new DB are verified as mismatching
+func (v *Verifier) DetectRanges(tableName TableName, bounds [2]uint64) ([][2]uint64, error) {
+	return DetectMissingRanges(v.oldDB, nil, tableName, bounds)
+}
+
+// Close satisfies io.Closer
+func (v *Verifier) Close() error {
+	if err := v.reader.Close(); err != nil {
+		return err
+	}
+	return v.newDB.Close()
+}
+
+// keyHashModulus bounds the keyHash sum to the size of a sha256 digest
+var keyHashModulus = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// keyHash is an order independent hash over a set of rows
+type keyHash struct {
+	sum *big.Int
+}
+
+func newKeyHash() *keyHash {
+	return &keyHash{sum: new(big.Int)}
+}
+
+// addModels adds the key columns of every model in the provided slice of v3 models to the hash
+func (h *keyHash) addModels(mapper *reflectx.Mapper, models interface{}, keyColumns []string) error {
+	modelsVal := reflect.Indirect(reflect.ValueOf(models))
+	if modelsVal.Kind() != reflect.Slice {
+		return fmt.Errorf("expected a slice of models, got %T", models)
+	}
+	elemType := reflectx.Deref(modelsVal.Type().Elem())
+	traversals := mapper.TraversalsByName(elemType, keyColumns)
+	for i, traversal := range traversals {
+		if len(traversal) == 0 {
+			return fmt.Errorf("could not find key column %s in model type %s", keyColumns[i], elemType)
+		}
+	}
+	values := make([]interface{}, len(keyColumns))
+	for i := 0; i < modelsVal.Len(); i++ {
+		modelVal := reflect.Indirect(modelsVal.Index(i))
+		for j, traversal := range traversals {
+			values[j] = reflectx.FieldByIndexesReadOnly(modelVal, traversal).Interface()
+		}
+		if err := h.add(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds a row of key column values to the hash
+func (h *keyHash) add(values []interface{}) error {
+	digest := sha256.New()
+	for _, value := range values {
+		value, err := driver.DefaultParameterConverter.ConvertValue(value)
+		if err != nil {
+			return err
+		}
+		var field string
+		switch value := value.(type) {
+		case nil:
+			field = "NULL"
+		case []byte:
+			// a nil byte slice is written as NULL, so it is hashed as one
+			if value == nil {
+				field = "NULL"
+				break
+			}
+			field = `\x` + hex.EncodeToString(value)
+		default:
+			field = fmt.Sprint(value)
+		}
+		digest.Write([]byte(field))
+		digest.Write([]byte{0})
+	}
+	h.sum.Add(h.sum, new(big.Int).SetBytes(digest.Sum(nil)))
+	h.sum.Mod(h.sum, keyHashModulus)
+	return nil
+}
+
+// String returns the hex encoding of the hash
+func (h *keyHash) String() string {
+	return fmt.Sprintf("%064x", h.sum)
+}
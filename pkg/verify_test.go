@@ -0,0 +1,99 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	migration_tools "github.com/vulcanize/migration-tools/pkg"
+	"github.com/vulcanize/migration-tools/pkg/eth_state"
+)
+
+var _ = Describe("Verifier", Serial, func() {
+	It("only matches results with equal counts and hashes", Serial, func() {
+		result := migration_tools.VerifyResult{V2Count: 2, V3Count: 2, V2Hash: "aa", V3Hash: "aa"}
+		Expect(result.Match()).To(BeTrue())
+		result.V3Hash = "ab"
+		Expect(result.Match()).To(BeFalse())
+		result.V3Hash, result.V3Count = "aa", 1
+		Expect(result.Match()).To(BeFalse())
+	})
+
+	It("rejects tables that are not segmented by block range", Serial, func() {
+		verifier := new(migration_tools.Verifier)
+		_, err := verifier.Verify(migration_tools.PublicNodes, [2]uint64{0, 10})
+		Expect(err).To(HaveOccurred())
+		_, err = verifier.Verify(migration_tools.EthLogsRepair, [2]uint64{0, 10})
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("key hash", func() {
+		stateKeyColumns := []string{"header_id", "state_path", "cid", "mh_key"}
+		models := []eth_state.StateModelV3{
+			{HeaderID: "0xaa", Path: []byte{0x01, 0x0a}, CID: "bagmacgza1", MhKey: "/blocks/1"},
+			{HeaderID: "0xaa", Path: nil, CID: "bagmacgza2", MhKey: "/blocks/2"},
+			{HeaderID: "0xbb", Path: []byte{}, CID: "bagmacgza3", MhKey: "/blocks/3"},
+		}
+		// rows are the key columns of the models as they are scanned from the v3 DB
+		rows := [][]interface{}{
+			{"0xaa", []byte{0x01, 0x0a}, "bagmacgza1", "/blocks/1"},
+			{"0xaa", nil, "bagmacgza2", "/blocks/2"},
+			{"0xbb", []byte{}, "bagmacgza3", "/blocks/3"},
+		}
+
+		It("does not depend on the order of the rows", func() {
+			hash, err := migration_tools.KeyHashOfRows(rows...)
+			Expect(err).ToNot(HaveOccurred())
+			reversed, err := migration_tools.KeyHashOfRows(rows[2], rows[1], rows[0])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reversed).To(Equal(hash))
+
+			fewer, err := migration_tools.KeyHashOfRows(rows[0], rows[1])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fewer).ToNot(Equal(hash))
+		})
+
+		It("hashes v3 models the same as their scanned rows, including byte and NULL columns", func() {
+			modelsHash, err := migration_tools.KeyHashOfModels(models, stateKeyColumns)
+			Expect(err).ToNot(HaveOccurred())
+			rowsHash, err := migration_tools.KeyHashOfRows(rows...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(modelsHash).To(Equal(rowsHash))
+
+			for i := range models {
+				modelHash, err := migration_tools.KeyHashOfModels(models[i:i+1], stateKeyColumns)
+				Expect(err).ToNot(HaveOccurred())
+				rowHash, err := migration_tools.KeyHashOfRows(rows[i])
+				Expect(err).ToNot(HaveOccurred())
+				Expect(modelHash).To(Equal(rowHash))
+			}
+
+			// an empty byte column is not NULL
+			nullPath, err := migration_tools.KeyHashOfRows([]interface{}{"0xbb", nil, "bagmacgza3", "/blocks/3"})
+			Expect(err).ToNot(HaveOccurred())
+			emptyPath, err := migration_tools.KeyHashOfModels(models[2:], stateKeyColumns)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(emptyPath).ToNot(Equal(nullPath))
+		})
+
+		It("rejects models without the key columns", func() {
+			_, err := migration_tools.KeyHashOfModels(models, []string{"block_hash"})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,182 @@
+// VulcanizeDB
+// Copyright © 2022 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migration_tools_test
+
+import (
+	"github.com/jmoiron/sqlx"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/migration-tools/pkg/public_nodes"
+	"github.com/vulcanize/migration-tools/pkg/sql"
+	"github.com/vulcanize/migration-tools/pkg/util"
+)
+
+// insertNodeHook returns a hook that writes the node with another statement within the transaction of a write
+func insertNodeHook(node public_nodes.NodeModel) util.TxHook {
+	return func(tx *sqlx.Tx) error {
+		_, err := tx.NamedExec(string(sql.PgWriteNodesStr), node)
+		return err
+	}
+}
+
+var _ = Describe("sql.WritePgStr", Serial, func() {
+	It("parses the table and columns of a write statement", Serial, func() {
+		Expect(sql.PgWriteEthStateStr.Table()).To(Equal("eth.state_cids"))
+		Expect(sql.PgWriteEthStateStr.Columns()).To(Equal([]string{"header_id", "state_path", "state_leaf_key",
+			"node_type", "cid", "mh_key", "diff"}))
+	})
+
+	It("leaves the statement untouched for the error policy", Serial, func() {
+		pgStr, err := sql.PgWriteEthStateStr.WithConflictPolicy(sql.ConflictError, sql.EthStateConflictKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pgStr).To(Equal(sql.PgWriteEthStateStr))
+	})
+
+	It("appends a DO NOTHING clause for the do-nothing policy", Serial, func() {
+		pgStr, err := sql.PgWriteEthLogsStr.WithConflictPolicy(sql.ConflictDoNothing, sql.EthLogsConflictKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(pgStr)).To(HaveSuffix("ON CONFLICT (rct_id, index) DO NOTHING"))
+	})
+
+	It("appends a DO UPDATE clause for every non-key column for the update policy", Serial, func() {
+		pgStr, err := sql.PgWriteEthAccountsStr.WithConflictPolicy(sql.ConflictUpdate, sql.EthAccountsConflictKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(pgStr)).To(HaveSuffix("ON CONFLICT (header_id, state_path) DO UPDATE SET balance = EXCLUDED.balance, " +
+			"nonce = EXCLUDED.nonce, code_hash = EXCLUDED.code_hash, storage_root = EXCLUDED.storage_root"))
+	})
+
+	It("leaves statements with their own conflict clause untouched", Serial, func() {
+		pgStr, err := sql.PgWriteIPLDsStr.WithConflictPolicy(sql.ConflictUpdate, sql.IPLDsConflictKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pgStr).To(Equal(sql.PgWriteIPLDsStr))
+	})
+
+	It("returns the conflict clause of a statement", Serial, func() {
+		Expect(sql.PgWriteIPLDsStr.ConflictClause()).To(Equal("ON CONFLICT (key) DO NOTHING"))
+		Expect(sql.PgWriteEthStateStr.ConflictClause()).To(BeEmpty())
+	})
+
+	It("rejects unrecognized policies", Serial, func() {
+		_, err := sql.NewConflictPolicyFromString("overwrite")
+		Expect(err).To(HaveOccurred())
+		_, err = sql.PgWriteEthHeadersStr.WithConflictPolicy("overwrite", sql.EthHeadersConflictKey)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("sql.Writer", Serial, Label("test"), func() {
+	var writer *sql.Writer
+	BeforeEach(func() {
+		sqlxDB, err = sqlx.Connect("postgres", v3DBConfig.DbConnectionString())
+		Expect(err).ToNot(HaveOccurred())
+		tearDownSQLXDB(sqlxDB)
+		writer = sql.NewWriter(sqlxDB)
+	})
+	AfterEach(func() {
+		tearDownSQLXDB(sqlxDB)
+		Expect(writer.Close()).To(Succeed())
+	})
+
+	It("writes all the models of a range", Serial, func() {
+		models := []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID2", ClientName: "mockName", ChainID: 1},
+		}
+		Expect(writer.Write(sql.PgWriteNodesStr, models)).To(Succeed())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(2))
+	})
+
+	It("writes none of the statements of a range when a later one fails", Serial, func() {
+		models := []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID2", ClientName: "mockName", ChainID: 1},
+		}
+		// the models are inserted by the first statement, and the second conflicts with one of them
+		Expect(writer.WriteWithHook(sql.PgWriteNodesStr, models, insertNodeHook(models[0]))).ToNot(Succeed())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(0))
+
+		third := public_nodes.NodeModel{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID3", ClientName: "mockName", ChainID: 1}
+		Expect(writer.WriteWithHook(sql.PgWriteNodesStr, models, insertNodeHook(third))).To(Succeed())
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(3))
+	})
+})
+
+var _ = Describe("sql.CopyWriter", Serial, Label("test"), func() {
+	var writer *sql.CopyWriter
+	BeforeEach(func() {
+		sqlxDB, err = sqlx.Connect("postgres", v3DBConfig.DbConnectionString())
+		Expect(err).ToNot(HaveOccurred())
+		tearDownSQLXDB(sqlxDB)
+		writer = sql.NewCopyWriter(sqlxDB)
+	})
+	AfterEach(func() {
+		tearDownSQLXDB(sqlxDB)
+		Expect(writer.Close()).To(Succeed())
+	})
+
+	It("copies all the models of a range", Serial, func() {
+		models := []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID2", ClientName: "mockName", ChainID: 1},
+		}
+		Expect(writer.Write(sql.PgWriteNodesStr, models)).To(Succeed())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(2))
+	})
+
+	It("copies none of the models of a range when any of them fail", Serial, func() {
+		models := []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+		}
+		Expect(writer.Write(sql.PgWriteNodesStr, models)).ToNot(Succeed())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(0))
+	})
+
+	It("copies none of the statements of a range when a later one fails", Serial, func() {
+		models := []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID2", ClientName: "mockName", ChainID: 1},
+		}
+		Expect(writer.WriteWithHook(sql.PgWriteNodesStr, models, insertNodeHook(models[1]))).ToNot(Succeed())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(0))
+	})
+
+	It("applies the conflict clause of the statement through a staging table", Serial, func() {
+		models := []public_nodes.NodeModel{
+			{GenesisBlock: "mockGenesisBlock", NetworkID: "1", NodeID: "mockNodeID1", ClientName: "mockName", ChainID: 1},
+		}
+		pgStr, err := sql.PgWriteNodesStr.WithConflictPolicy(sql.ConflictDoNothing, sql.NodesConflictKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Write(pgStr, models)).To(Succeed())
+		Expect(writer.Write(pgStr, models)).To(Succeed())
+		var count int
+		Expect(sqlxDB.Get(&count, `SELECT COUNT(*) FROM public.nodes`)).To(Succeed())
+		Expect(count).To(Equal(1))
+	})
+})